@@ -54,7 +54,7 @@ func main() {
 		fmt.Printf("⛏️  Mining Block #%d\n", i)
 
 		// 1. Determine Correct Algorithm (The Law)
-		correctAlgo := consensus.SelectAlgorithm(currentHeader.VRFSeed)
+		correctAlgo := consensus.SelectAlgorithm(currentHeader.VRFSeed, currentHeader.Height)
 		seedByte := currentHeader.VRFSeed[31]
 		fmt.Printf("   ⚖️  Protocol Rule: VRF[31]=%d %% 6 = %d → USE %s\n",
 			seedByte, seedByte%6, correctAlgo)
@@ -87,7 +87,7 @@ func main() {
 			} else {
 				// HONEST: Follows protocol
 				algoUsed = correctAlgo
-				_, root = consensus.StartRace(txs, currentHeader.VRFSeed)
+				_, root = consensus.StartRaceSimplified(txs, currentHeader.VRFSeed, correctAlgo)
 				honestRoot = root
 			}
 
@@ -163,8 +163,8 @@ func wrapTxs(txs []types.Transaction, seed [32]byte) []consensus.SortableTransac
 	data := make([]consensus.SortableTransaction, len(txs))
 	for i, tx := range txs {
 		data[i] = consensus.SortableTransaction{
-			Tx:  tx,
-			Key: consensus.MixHash(tx.ID, seed),
+			Tx:  &txs[i],
+			Key: utils.MixHash(tx.ID, seed),
 		}
 	}
 	return data
@@ -173,7 +173,7 @@ func wrapTxs(txs []types.Transaction, seed [32]byte) []consensus.SortableTransac
 func extractTxs(sorted []consensus.SortableTransaction) []types.Transaction {
 	result := make([]types.Transaction, len(sorted))
 	for i, st := range sorted {
-		result[i] = st.Tx
+		result[i] = *st.Tx
 	}
 	return result
 }
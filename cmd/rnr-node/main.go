@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	"encoding/json"
@@ -9,9 +10,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/blockchain"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/config"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
@@ -25,8 +28,11 @@ import (
 	// 5. Start GUI Dashboard (Disabled for Headless Build)
 	// dashboard.StartServer("8080", chain, nil)
 	"github.com/LICODX/PoSSR-RNRCORE/internal/p2p"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/rpc"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/slashing"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/storage"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/validator"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/wallet"
 )
@@ -166,18 +172,34 @@ func main() {
 	if cfg != nil {
 		shardCfg = cfg.Sharding
 	}
-	chain := blockchain.NewBlockchain(db, shardCfg)
+	chain, err := blockchain.NewBlockchain(db, shardCfg)
+	if err != nil {
+		fmt.Printf("Failed to load blockchain: %v\n", err)
+		return
+	}
+	if cfg != nil && cfg.Staking.UnbondingPeriod != 0 {
+		chain.GetStateManager().SetUnbondingPeriod(cfg.Staking.UnbondingPeriod)
+	}
 	tip := chain.GetTip()
 	fmt.Printf("⛓️  Current Tip: Block #%d\n", tip.Height)
 
 	// 3. Setup context
 	ctx := context.Background()
 
-	// Create a channel to stop mining if needed (e.g. new block received)
-	stopMining := make(chan struct{})
-
 	// 4. Start P2P Network
 	var node *p2p.GossipSubNode
+	raceAggregator := consensus.NewAggregator()            // Shard proofs for the blocks we mine
+	daStore := availability.NewStore(params.PruningWindow) // Erasure-coded shards we serve to samplers
+	for h := tip.Height; h > 0 && h+params.PruningWindow > tip.Height; h-- {
+		// Keep serving the bodies we still hold after a restart
+		if block, err := chain.GetBlock(h); err == nil {
+			daStore.Add(block)
+		}
+	}
+	raceAggregator.Eligible = func(slot uint8, nodeID [32]byte) bool {
+		// Our own proofs are bound by our PoW; other sorters need stake
+		return bytes.Equal(nodeID[:], nodeWallet.PublicKey) || chain.IsStakedSorter(nodeID)
+	}
 
 	if *useGossipSub {
 		var err error
@@ -261,7 +283,12 @@ func main() {
 
 			// SECURITY CHECK: Validate against State (Nonce & Balance)
 			// This prevents Replay Attacks and insufficient balance spam
-			if err := blockchain.ValidateTransactionAgainstState(tx, chain.GetStateManager()); err != nil {
+			// Scheduled transactions are checked at the first height they become valid.
+			height := chain.GetTip().Height + 1
+			if tx.ValidAfterHeight >= height {
+				height = tx.ValidAfterHeight + 1
+			}
+			if err := blockchain.ValidateTransactionAgainstState(tx, chain.GetStateManager(), height); err != nil {
 				fmt.Printf("⚠️ Invalid transaction rejected: %v\n", err)
 				return
 			}
@@ -269,8 +296,87 @@ func main() {
 			node.AddToMempool(tx)
 		})
 
+		// Sorting race: sort revealed shards we follow, collect proofs for blocks we mine
+		racing := make(map[int]bool)
+		for _, shardID := range listeningShards {
+			racing[shardID] = true
+		}
 		node.ListenForProofs(func(data []byte) {
-			fmt.Println("✅ Received proof from network")
+			var msg consensus.RaceMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				return
+			}
+
+			if msg.Proof != nil {
+				if err := raceAggregator.SubmitProof(msg.Proof.Height, msg.Proof); err == nil {
+					fmt.Printf("✅ Shard proof accepted: slot %d by %x\n", msg.Proof.SlotID, msg.Proof.NodeID[:4])
+				}
+				return
+			}
+			if shard := msg.Shard; shard != nil && shard.Proof != nil {
+				if err := raceAggregator.SubmitShard(shard.Proof.Height, shard.Proof, shard.Txs); err == nil {
+					fmt.Printf("✅ Shard accepted: slot %d by %x\n", shard.Proof.SlotID, shard.Proof.NodeID[:4])
+				}
+				return
+			}
+
+			// Aggregation round: sort our mempool's transactions of the slots we follow
+			if round := msg.Round; round != nil && nodeWallet != nil {
+				var self [32]byte
+				copy(self[:], nodeWallet.PublicKey)
+				if round.MinerPubKey == self {
+					return // Our own block
+				}
+				go func() {
+					txs := node.GetMempoolForHeight(round.Height)
+					for slot := range racing {
+						shard, err := consensus.SolveRound(round, uint8(slot), txs, nodeWallet.PrivateKey)
+						if err != nil {
+							fmt.Printf("⚠️ Ignoring aggregation round: %v\n", err)
+							return
+						}
+						data, _ := json.Marshal(&consensus.RaceMessage{Shard: shard})
+						if err := node.PublishProof(data); err != nil {
+							fmt.Printf("⚠️ Failed to publish shard: %v\n", err)
+						}
+					}
+				}()
+				return
+			}
+
+			task := msg.Task
+			if task == nil || !racing[int(task.SlotID)] || nodeWallet == nil {
+				return
+			}
+			var self [32]byte
+			copy(self[:], nodeWallet.PublicKey)
+			if task.Header.MinerPubKey == self {
+				return // Our own block
+			}
+			go func() {
+				proof, err := consensus.SolveRaceTask(task, nodeWallet.PrivateKey)
+				if err != nil {
+					fmt.Printf("⚠️ Ignoring race task: %v\n", err)
+					return
+				}
+				data, _ := json.Marshal(&consensus.RaceMessage{Proof: proof})
+				if err := node.PublishProof(data); err != nil {
+					fmt.Printf("⚠️ Failed to publish shard proof: %v\n", err)
+				}
+			}()
+		})
+
+		// Data availability: serve chunks of the blocks we hold, sample the shards we do not validate
+		node.SetSampleHandler(daStore.Serve)
+		chain.SetDataSampler(func(header *types.BlockHeader, shards []int) error {
+			return availability.SampleHeader(header, shards, params.DataSamplesPerShard, node.RequestSample)
+		})
+
+		// Fraud proofs let ShardNodes reject blocks with invalid shards they do not validate
+		node.ListenForFraudProofs(func(proof *types.FraudProof) {
+			if err := chain.HandleFraudProof(proof); err != nil {
+				fmt.Printf("⚠️ Fraud proof rejected: %v\n", err)
+			}
 		})
 
 	} else {
@@ -328,8 +434,31 @@ func main() {
 	dashboardPortStr := fmt.Sprintf("%d", *dashboardPort)
 	go dashboard.StartServer(dashboardPortStr, chain, node, nodeWallet) // Pass wallet
 
-	// 6. Consensus Loop (PoW Mining OR BFT Consensus)
+	// JSON-RPC API
+	rpc.NewServer(chain, chain.GetStateManager(), fmt.Sprintf("%d", *rpcPort)).Start()
+
+	// 6. Select Consensus Engine (PoW Mining OR BFT Consensus)
+	// --bft-mode overrides the engine configured in the config file
+	engineName := "pow"
+	difficulty := uint64(1000)
+	if cfg != nil {
+		if cfg.Consensus.Engine != "" {
+			engineName = cfg.Consensus.Engine
+		}
+		if cfg.Consensus.Difficulty != 0 {
+			difficulty = cfg.Consensus.Difficulty
+		}
+	}
 	if *bftMode {
+		engineName = "bft"
+	}
+
+	var engine consensus.Engine
+	var createCoinbase func(height uint64) []types.Transaction
+	var onBlockAdded func(block *types.Block) // Engine hook run after each block is added
+
+	switch engineName {
+	case "bft":
 		fmt.Println("🎯 BFT Consensus Mode Enabled")
 		fmt.Println("⚠️  Multi-validator mode requires multiple nodes running with --bft-mode")
 
@@ -343,10 +472,19 @@ func main() {
 			PubKey:      nodeWallet.PrivateKey.Public().(ed25519.PublicKey),
 		}
 
-		valSet := bft.NewValidatorSet([]*bft.Validator{genesisValidator})
+		// The genesis validator runs alone until an epoch boundary elects bonded validators
+		valMgr := validator.NewManager(params.MinValidatorStake, params.MaxValidators, params.EpochLength,
+			[]*bft.Validator{genesisValidator})
+		// Replay the sets elected since, so commits of every recent height verify after a restart
+		staking := chain.GetStateManager().GetStakingState()
+		if err := valMgr.LoadHistory(staking); err != nil {
+			fmt.Printf("Failed to load validator set history: %v\n", err)
+			return
+		}
+		valSet := valMgr.GetActiveSet()
 
-		// TODO: Load additional validators from config or network discovery
-		// For now, single validator (will work but no Byzantine tolerance)
+		// Added blocks must carry a commit certificate from the validators of their height
+		chain.SetValidatorsAt(valMgr.SetAt)
 
 		// Create BFT Engine
 		bftEngine := consensus.NewBFTEngine(
@@ -355,6 +493,8 @@ func main() {
 			validatorAddr,
 			nodeWallet.PrivateKey,
 		)
+		// Committed evidence is remembered as long as the chain would still execute it
+		bftEngine.EvidencePool = slashing.NewEvidencePool(chain.GetStateManager().UnbondingPeriod())
 
 		// Wire P2P handlers
 		bftEngine.BroadcastVote = func(vote *bft.Vote) error {
@@ -363,11 +503,69 @@ func main() {
 		bftEngine.BroadcastProposal = func(proposal *bft.Proposal) error {
 			return node.PublishProposal(proposal)
 		}
+		bftEngine.BroadcastBlockPart = func(part *bft.BlockPart) error {
+			return node.PublishBlockPart(part)
+		}
+		bftEngine.BroadcastEvidence = func(ev *types.DoubleSignEvidence) error {
+			return node.PublishEvidence(ev)
+		}
 
-		// Wire finality tracker
-		bftEngine.MarkFinalized = func(height uint64, hash [32]byte) error {
-			return chain.MarkBlockFinalized(height, hash)
+		// Consensus WAL: replayed on Initialize so a restart never signs a conflicting vote
+		wal, err := consensus.OpenWAL(filepath.Join(*datadir, "consensus.wal"))
+		if err != nil {
+			fmt.Printf("Failed to open consensus WAL: %v\n", err)
+			return
+		}
+		defer wal.Close()
+		bftEngine.WAL = wal
+
+		// Last signed (height, round, step): refuses conflicting signatures across restarts
+		signGuard, err := bft.LoadSignGuard(filepath.Join(*datadir, "priv_validator_state.json"), nodeWallet.PrivateKey)
+		if err != nil {
+			fmt.Printf("Failed to load validator sign state: %v\n", err)
+			return
+		}
+		bftEngine.SignGuard = signGuard
+
+		// Proposals are built on our tip and vetted against our state before prevoting
+		bftEngine.GetTip = chain.GetTip
+		bftEngine.LoadCommit = chain.GetCommit
+		bftEngine.CheckBlock = func(block types.Block) error {
+			if shardCfg.Role == "FullNode" {
+				// Serve the proposal's chunks while ShardNodes sample it
+				if err := daStore.Add(&block); err != nil {
+					fmt.Printf("⚠️ Failed to store proposal data for sampling: %v\n", err)
+				}
+			}
+			err := chain.CheckBlock(block)
+			if err != nil && shardCfg.Role == "FullNode" {
+				// Prove invalid shards to the ShardNodes that only trust their roots
+				for shardID := uint8(0); shardID < 10; shardID++ {
+					if proof := blockchain.FindShardFraud(block, shardID); proof != nil {
+						if pubErr := node.PublishFraudProof(proof); pubErr != nil {
+							fmt.Printf("⚠️ Failed to publish fraud proof: %v\n", pubErr)
+						}
+						continue
+					}
+					// A data commitment that does not match the shard is proven from the chunks samplers see
+					if block.Header.Version >= types.HeaderVersionDataAvailability &&
+						availability.VerifyShard(&block.Header, int(shardID), block.Shards[shardID].TxData) != nil {
+						go func(header types.BlockHeader, shardID uint8) {
+							if proof := availability.FindEncodingFraud(&header, shardID, node.RequestSample); proof != nil {
+								if pubErr := node.PublishFraudProof(proof); pubErr != nil {
+									fmt.Printf("⚠️ Failed to publish fraud proof: %v\n", pubErr)
+								}
+							}
+						}(block.Header, shardID)
+					}
+				}
+			}
+			return err
 		}
+		bftEngine.CheckEvidence = chain.GetStateManager().CheckEvidence
+
+		// Wire finality tracker
+		bftEngine.MarkFinalized = chain.MarkBlockFinalized
 
 		// Listen for incoming votes and proposals
 		node.ListenForVotes(func(vote *bft.Vote) {
@@ -376,122 +574,179 @@ func main() {
 		node.ListenForProposals(func(proposal *bft.Proposal) {
 			bftEngine.ProcessIncomingProposal(proposal)
 		})
-
-		fmt.Println("✅ BFT Engine initialized")
-		fmt.Println("🔄 Starting BFT consensus rounds...")
+		node.ListenForBlockParts(func(part *bft.BlockPart) {
+			bftEngine.ProcessIncomingBlockPart(part)
+		})
+		node.ListenForEvidence(func(ev *types.DoubleSignEvidence) {
+			bftEngine.ProcessIncomingEvidence(ev)
+		})
 
 		// Initialize Validator Reward Manager (10 shards)
 		rewardMgr := NewValidatorRewardManager(10)
 		rewardMgr.UpdateShardAssignment(valSet) // Initial assignment
 
-		// BFT Consensus Loop
-		for {
-			lastHeader := chain.GetTip()
-			height := lastHeader.Height + 1
-
-			// Get transactions from mempool
-			txs := node.GetMempoolShard()
-
-			// Create proportional coinbase transactions (one per validator based on shards)
+		// Create proportional coinbase transactions (one per validator based on shards)
+		createCoinbase = func(height uint64) []types.Transaction {
 			baseReward := economics.GetBlockReward(height)
-			coinbaseTxs := rewardMgr.CreateCoinbaseTransactions(height, uint64(baseReward), valSet)
-
-			// Combine coinbase + user transactions
-			consensusTxs := append(coinbaseTxs, txs...)
-
-			// Run BFT consensus round
-			fmt.Printf("\n[BFT] Height %d: Starting consensus round\n", height)
-			committedBlock, err := bftEngine.RunConsensusRound(height, consensusTxs)
+			return rewardMgr.CreateCoinbaseTransactions(height, uint64(baseReward), bftEngine.Validators)
+		}
 
-			if err != nil {
-				fmt.Printf("[BFT] Consensus failed: %v\n", err)
-				fmt.Println("[BFT] Retrying in 3 seconds...")
-				time.Sleep(3 * time.Second)
-				continue
+		// Load the validator set elected on-chain at the latest epoch boundary
+		syncValidators := func() {
+			epoch, err := staking.ActiveEpoch()
+			if err != nil || !valMgr.ApplyChainEpoch(epoch, chain.GetTip().Height+1) {
+				return
 			}
-
-			// Add block to chain
-			if err := chain.AddBlock(*committedBlock); err != nil {
-				fmt.Printf("[BFT] Failed to add block: %v\n", err)
-				continue
+			bftEngine.SetValidatorSet(valMgr.GetActiveSet())
+			rewardMgr.UpdateShardAssignment(bftEngine.Validators)
+			fmt.Printf("👥 Validator set from epoch %d: %d validators\n", epoch.Height, bftEngine.Validators.Size())
+		}
+		onBlockAdded = func(block *types.Block) {
+			// Executed evidence removes validators mid-epoch
+			bftEngine.EvidencePool.MarkCommitted(block.Evidence, block.Header.Height)
+			if block.Header.Height%params.EpochLength == 0 || len(block.Evidence) > 0 {
+				syncValidators()
 			}
-
-			fmt.Printf("[BFT] ✅ Block %d FINALIZED and added to chain\n", height)
-
-			// Broadcast block
-			node.PublishBlock(*committedBlock)
-
-			// Clear mempool
-			node.ClearMempool()
-
-			// Small delay before next round
-			time.Sleep(time.Duration(params.BlockTime) * time.Second)
 		}
 
-	} else {
+		adapter := consensus.NewBFTAdapter(bftEngine)
+		adapter.LoadCommit = chain.GetCommit
+		adapter.ValidatorsAt = valMgr.SetAt
+		engine = adapter
+
+	case "pow":
 		// Traditional PoW Mining Mode
 		fmt.Println("🏁 PoW Mining Mode (Single Node)")
 		fmt.Println("   Use --bft-mode for multi-validator consensus")
 
-		for {
-			lastHeader := chain.GetTip()
-			difficulty := uint64(1000)
+		var minerAddress [32]byte
+		copy(minerAddress[:], nodeWallet.PublicKey)
 
-			// Get transactions from P2P mempool
-			txs := node.GetMempoolShard()
+		// Create Coinbase Transactions (Block Reward)
+		// Shard rewards follow the sorting race winners recorded in the previous block
+		createCoinbase = func(height uint64) []types.Transaction {
+			// Calculate block reward using economics module (decaying over time)
+			baseReward := economics.GetBlockReward(height)
 
-			// Create Coinbase Transaction (Block Reward)
-			var minerAddress [32]byte
-			copy(minerAddress[:], nodeWallet.PublicKey)
+			winners := chain.GetTip().WinningNodes
+			assignment := economics.AssignWinners(winners[:])
+			if len(assignment.ValidatorShards) == 0 {
+				assignment = economics.AssignShards([][32]byte{minerAddress}, params.NumShards) // No race yet
+			}
+			rewards := economics.CalculateShardRewards(uint64(baseReward), assignment)
 
-			// Calculate block reward using economics module (decaying over time)
-			baseReward := economics.GetBlockReward(lastHeader.Height + 1)
-
-			coinbaseTx := types.Transaction{
-				ID:        [32]byte{1, 1, 1, 1, byte(lastHeader.Height)},
-				Sender:    [32]byte{}, // System
-				Receiver:  minerAddress,
-				Amount:    uint64(baseReward), // Full reward (all shards processed)
-				Nonce:     0,                  // System TX
-				Signature: [64]byte{},
+			receivers := make([][32]byte, 0, len(rewards))
+			for receiver := range rewards {
+				receivers = append(receivers, receiver)
 			}
+			sort.Slice(receivers, func(i, j int) bool { return bytes.Compare(receivers[i][:], receivers[j][:]) < 0 })
+
+			txs := make([]types.Transaction, 0, len(receivers))
+			for i, receiver := range receivers {
+				txs = append(txs, types.Transaction{
+					ID:        [32]byte{1, 1, 1, 1, byte(height - 1), byte(i)},
+					Sender:    [32]byte{}, // System
+					Receiver:  receiver,
+					Amount:    rewards[receiver],
+					Nonce:     0, // System TX
+					Signature: [64]byte{},
+				})
+			}
+			return txs
+		}
 
-			// Prepend Coinbase to transactions
-			minableTxs := append([]types.Transaction{coinbaseTx}, txs...)
+		publishRace := func(msg *consensus.RaceMessage) error {
+			if node == nil {
+				return nil
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			return node.PublishProof(data)
+		}
+		powEngine := consensus.NewPoWEngine(difficulty, nodeWallet.PrivateKey, chain.GetTip)
+		if cfg != nil && cfg.Consensus.Aggregate {
+			// Sorters build the shards from their own mempools
+			fmt.Println("🧩 Aggregating blocks from sorters' shards")
+			powEngine.Aggregation = &consensus.Aggregation{Aggregator: raceAggregator, Publish: publishRace}
+		} else {
+			powEngine.Race = &consensus.Race{
+				Aggregator: raceAggregator,
+				Window:     params.ShardRaceWindow * time.Second,
+				Publish:    publishRace,
+			}
+		}
+		engine = powEngine
 
-			var minerPubKey [32]byte
-			copy(minerPubKey[:], nodeWallet.PublicKey)
+	default:
+		fmt.Printf("Unknown consensus engine %q (expected \"pow\" or \"bft\")\n", engineName)
+		return
+	}
 
-			newBlock, err := consensus.MineBlock(minableTxs, lastHeader, difficulty, stopMining, minerPubKey, nodeWallet.PrivateKey)
+	if err := engine.Initialize(); err != nil {
+		fmt.Printf("Failed to initialize %s engine: %v\n", engineName, err)
+		return
+	}
+	defer engine.Stop()
 
-			if err != nil {
-				if err.Error() == "mining interrupted" {
-					fmt.Println("Mining interrupted! Restarting...")
-					continue
-				}
-				fmt.Println("Mining error:", err)
-				continue
-			}
+	fmt.Printf("✅ %s engine initialized\n", strings.ToUpper(engineName))
+	fmt.Println("🔄 Starting consensus rounds...")
 
-			fmt.Printf("[SUCCESS] Block Found! Nonce: %d | Hash: %x\n", newBlock.Header.Nonce, newBlock.Header.Hash)
+	// 7. Consensus Loop
+	for {
+		lastHeader := chain.GetTip()
+		height := lastHeader.Height + 1
 
-			// Add to local chain
-			if err := chain.AddBlock(*newBlock); err != nil {
-				fmt.Printf("Failed to add block: %v\n", err)
+		// Get transactions from mempool (only those valid at this height)
+		node.EvictExpired(height)
+		txs := node.GetMempoolForHeight(height)
+
+		// Combine coinbase + user transactions
+		consensusTxs := append(createCoinbase(height), txs...)
+
+		fmt.Printf("\n[%s] Height %d: Starting consensus round\n", strings.ToUpper(engineName), height)
+		newBlock, err := engine.RunConsensusRound(height, consensusTxs)
+		if err != nil {
+			if err.Error() == "mining interrupted" {
+				fmt.Println("Mining interrupted! Restarting...")
 				continue
 			}
+			fmt.Printf("Consensus failed: %v\n", err)
+			fmt.Println("Retrying in 3 seconds...")
+			time.Sleep(3 * time.Second)
+			continue
+		}
 
-			fmt.Printf("[OK] Block Accepted! Height: %d\n", newBlock.Header.Height)
+		if err := engine.ValidateBlockHeader(&newBlock.Header); err != nil {
+			fmt.Printf("Produced block rejected by engine: %v\n", err)
+			continue
+		}
 
-			// THROTTLE: Wait for BlockTime (6s) to ensure consistent heartbeat
-			fmt.Printf("[WAIT] Waiting %d seconds for next round...\n", params.BlockTime)
-			time.Sleep(time.Duration(params.BlockTime) * time.Second)
+		fmt.Printf("[SUCCESS] Block Found! Nonce: %d | Hash: %x\n", newBlock.Header.Nonce, newBlock.Header.Hash)
 
-			// Broadcast Block (Split into Header + Shards)
-			node.PublishBlock(*newBlock)
+		// Add to local chain
+		if err := chain.AddBlock(*newBlock); err != nil {
+			fmt.Printf("Failed to add block: %v\n", err)
+			continue
+		}
 
-			// Clear mempool (Simplified)
-			node.ClearMempool()
+		fmt.Printf("[OK] Block Accepted! Height: %d\n", newBlock.Header.Height)
+		if err := daStore.Add(newBlock); err != nil {
+			fmt.Printf("⚠️ Failed to store block data for sampling: %v\n", err)
+		}
+		if onBlockAdded != nil {
+			onBlockAdded(newBlock)
 		}
+
+		// Broadcast Block (Split into Header + Shards)
+		node.PublishBlock(*newBlock)
+
+		// Remove included transactions; scheduled ones stay until their window opens
+		node.RemoveFromMempool(txs)
+
+		// THROTTLE: Wait for BlockTime (6s) to ensure consistent heartbeat
+		fmt.Printf("[WAIT] Waiting %d seconds for next round...\n", params.BlockTime)
+		time.Sleep(time.Duration(params.BlockTime) * time.Second)
 	}
 }
@@ -3,6 +3,7 @@ module github.com/LICODX/PoSSR-RNRCORE
 go 1.25.5
 
 require (
+	filippo.io/edwards25519 v1.2.0
 	fyne.io/fyne/v2 v2.7.2
 	github.com/btcsuite/btcd/btcutil v1.1.6
 	github.com/libp2p/go-libp2p v0.46.0
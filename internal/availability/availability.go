@@ -0,0 +1,208 @@
+// Package availability erasure-codes shard bodies so that their availability can be sampled
+// Each shard body is split into k chunks and extended with k Reed-Solomon parity
+// chunks, so the shard can be rebuilt from any half of the chunks. A producer that
+// withholds a shard must hide more than half of its chunks, and every random
+// sample then fails with probability above 1/2. Validators of a shard re-encode
+// it when validating the block, so they reject commitments to a wrong extension,
+// and a shard whose available chunks do not decode to transactions matching its
+// shard root can be proven to ShardNodes with a FraudBadEncoding proof.
+package availability
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// ExtendedShard is a shard body extended with parity chunks
+type ExtendedShard struct {
+	DataChunks int
+	Chunks     [][]byte   // 2*DataChunks chunks of equal size, data first
+	Leaves     [][32]byte // Chunk hashes
+	Root       [32]byte   // Header.DataRoots entry
+}
+
+// chunkLeaf returns the Merkle leaf of a chunk
+func chunkLeaf(chunk []byte) [32]byte {
+	return sha256.Sum256(chunk)
+}
+
+// EncodeShard erasure-codes the body of a shard (nil for an empty shard)
+// The body is the JSON encoding of txs behind a 4-byte length, cut into at most
+// MaxDataChunks chunks of at least DataChunkSize bytes.
+func EncodeShard(txs []types.Transaction) (*ExtendedShard, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(txs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode shard body: %v", err)
+	}
+	data := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(data, uint32(len(body)))
+	copy(data[4:], body)
+
+	chunkSize := params.DataChunkSize
+	if min := (len(data) + params.MaxDataChunks - 1) / params.MaxDataChunks; min > chunkSize {
+		chunkSize = min
+	}
+	k := (len(data) + chunkSize - 1) / chunkSize
+
+	chunks := make([][]byte, k)
+	for i := range chunks {
+		chunks[i] = make([]byte, chunkSize)
+		copy(chunks[i], data[i*chunkSize:])
+	}
+	extended, err := utils.RSEncode(chunks, k)
+	if err != nil {
+		return nil, err
+	}
+
+	shard := &ExtendedShard{DataChunks: k, Chunks: extended, Leaves: make([][32]byte, len(extended))}
+	for i, chunk := range extended {
+		shard.Leaves[i] = chunkLeaf(chunk)
+	}
+	shard.Root = utils.CalculateMerkleRoot(shard.Leaves)
+	return shard, nil
+}
+
+// DecodeShard rebuilds a shard's transactions from any dataChunks of its chunks (nil = missing)
+func DecodeShard(chunks [][]byte, dataChunks int) ([]types.Transaction, error) {
+	if len(chunks) != 2*dataChunks {
+		return nil, fmt.Errorf("expected %d chunks, got %d", 2*dataChunks, len(chunks))
+	}
+	if err := utils.RSReconstruct(chunks, dataChunks); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, chunk := range chunks[:dataChunks] {
+		data = append(data, chunk...)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("shard body too short")
+	}
+	size := binary.BigEndian.Uint32(data)
+	if uint64(size) > uint64(len(data)-4) {
+		return nil, fmt.Errorf("shard body length %d exceeds its chunks", size)
+	}
+
+	var txs []types.Transaction
+	if err := json.Unmarshal(data[4:4+size], &txs); err != nil {
+		return nil, fmt.Errorf("failed to decode shard body: %v", err)
+	}
+	return txs, nil
+}
+
+// Commit sets the data commitments of block's header from its shards
+func Commit(block *types.Block) error {
+	for i, shard := range block.Shards {
+		extended, err := EncodeShard(shard.TxData)
+		if err != nil {
+			return fmt.Errorf("shard %d: %v", i, err)
+		}
+		block.Header.DataRoots[i], block.Header.DataChunks[i] = [32]byte{}, 0
+		if extended != nil {
+			block.Header.DataRoots[i] = extended.Root
+			block.Header.DataChunks[i] = uint16(extended.DataChunks)
+		}
+	}
+	return nil
+}
+
+// VerifyShard checks that header commits to the erasure coding of txs as shard shardID
+func VerifyShard(header *types.BlockHeader, shardID int, txs []types.Transaction) error {
+	extended, err := EncodeShard(txs)
+	if err != nil {
+		return err
+	}
+
+	var root [32]byte
+	var chunks uint16
+	if extended != nil {
+		root, chunks = extended.Root, uint16(extended.DataChunks)
+	}
+	if header.DataRoots[shardID] != root || header.DataChunks[shardID] != chunks {
+		return fmt.Errorf("shard %d data commitment mismatch", shardID)
+	}
+	return nil
+}
+
+// FindEncodingFraud fetches the chunks of a shard whose data commitment does not match its
+// transactions and returns a proof that they decode to something else
+// Returns nil if the shard is not fraudulent or too few chunks are available to decode it
+// (then sampling rejects the block instead).
+func FindEncodingFraud(header *types.BlockHeader, shardID uint8, fetch func(*SampleRequest) (*SampleResponse, error)) *types.FraudProof {
+	if shardID >= 10 {
+		return nil
+	}
+	k := int(header.DataChunks[shardID])
+	blockHash := types.HashBlockHeader(*header)
+
+	var chunks []types.FraudChunk
+	for i := 0; i < 2*k && len(chunks) < k; i++ {
+		req := &SampleRequest{BlockHash: blockHash, Height: header.Height, ShardID: shardID, Index: uint16(i)}
+		resp, err := fetch(req)
+		if err != nil || VerifySample(header, req, resp) != nil {
+			continue
+		}
+		chunks = append(chunks, types.FraudChunk{Index: i, Chunk: resp.Chunk, Path: resp.Path})
+	}
+	if k == 0 || len(chunks) < k {
+		return nil
+	}
+
+	proof := &types.FraudProof{Kind: types.FraudBadEncoding, Header: *header, ShardID: shardID, Chunks: chunks}
+	if VerifyEncodingFraud(proof) != nil {
+		return nil
+	}
+	return proof
+}
+
+// VerifyEncodingFraud checks that the chunks of a FraudBadEncoding proof are committed to by
+// its header and do not decode to transactions that hash to the shard root and re-encode to
+// the same commitment. Any half of an honest shard's chunks decodes to its transactions.
+func VerifyEncodingFraud(proof *types.FraudProof) error {
+	header := &proof.Header
+	k := int(header.DataChunks[proof.ShardID])
+	if k == 0 || len(proof.Chunks) != k {
+		return fmt.Errorf("shard %d needs %d chunks, proof has %d", proof.ShardID, k, len(proof.Chunks))
+	}
+
+	chunks := make([][]byte, 2*k)
+	for _, c := range proof.Chunks {
+		if c.Index < 0 || c.Index >= 2*k || chunks[c.Index] != nil {
+			return fmt.Errorf("invalid or repeated chunk index %d", c.Index)
+		}
+		req := &SampleRequest{ShardID: proof.ShardID, Index: uint16(c.Index)}
+		if err := VerifySample(header, req, &SampleResponse{Chunk: c.Chunk, Path: c.Path}); err != nil {
+			return err
+		}
+		chunks[c.Index] = append([]byte(nil), c.Chunk...)
+	}
+
+	txs, err := DecodeShard(chunks, k)
+	if err != nil {
+		return nil // The committed chunks are not the encoding of any shard
+	}
+	ids := make([][32]byte, len(txs))
+	for i, tx := range txs {
+		if types.HashTransaction(tx) != tx.ID {
+			return nil
+		}
+		ids[i] = tx.ID
+	}
+	if utils.CalculateMerkleRoot(ids) != header.ShardRoots[proof.ShardID] {
+		return nil
+	}
+	if VerifyShard(header, int(proof.ShardID), txs) != nil {
+		return nil
+	}
+	return fmt.Errorf("shard %d data decodes to its transactions", proof.ShardID)
+}
@@ -0,0 +1,191 @@
+package availability
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+func testShard(n int) []types.Transaction {
+	txs := make([]types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.Transaction{Sender: [32]byte{2}, Amount: uint64(i + 1), Payload: make([]byte, 100)}
+		txs[i].ID = types.HashTransaction(txs[i])
+	}
+	return txs
+}
+
+func TestErasureCodeRecoversFromHalf(t *testing.T) {
+	txs := testShard(40)
+	shard, err := EncodeShard(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := shard.DataChunks
+	if k < 2 || len(shard.Chunks) != 2*k {
+		t.Fatalf("expected a multi-chunk extended shard, got %d of %d chunks", k, len(shard.Chunks))
+	}
+
+	// Keep every other chunk: half data, half parity
+	chunks := make([][]byte, len(shard.Chunks))
+	for i := 1; i < len(chunks); i += 2 {
+		chunks[i] = append([]byte(nil), shard.Chunks[i]...)
+	}
+	decoded, err := DecodeShard(chunks, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(txs) || decoded[39].ID != txs[39].ID || decoded[7].Amount != txs[7].Amount {
+		t.Error("decoded shard differs from the original")
+	}
+
+	// One chunk short of half cannot be decoded
+	chunks = make([][]byte, len(shard.Chunks))
+	for i := 0; i < k-1; i++ {
+		chunks[i] = shard.Chunks[i]
+	}
+	if _, err := DecodeShard(chunks, k); err == nil {
+		t.Error("decoded a shard from fewer than k chunks")
+	}
+}
+
+func TestSampleHeader(t *testing.T) {
+	var block types.Block
+	block.Header.Version = types.HeaderVersionDataAvailability
+	block.Header.Height = 9
+	block.Shards[2].TxData = testShard(30)
+	block.Shards[7].TxData = testShard(3)
+	if err := Commit(&block); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyShard(&block.Header, 2, block.Shards[2].TxData); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyShard(&block.Header, 2, block.Shards[7].TxData); err == nil {
+		t.Error("shard data accepted under another shard's commitment")
+	}
+
+	store := NewStore(10)
+	if err := store.Add(&block); err != nil {
+		t.Fatal(err)
+	}
+	shards := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if err := SampleHeader(&block.Header, shards, 16, store.Serve); err != nil {
+		t.Fatalf("available block failed sampling: %v", err)
+	}
+
+	// A producer withholding half of shard 2 is caught
+	withheld := func(req *SampleRequest) (*SampleResponse, error) {
+		if req.ShardID == 2 && req.Index%2 == 0 {
+			return nil, fmt.Errorf("withheld")
+		}
+		return store.Serve(req)
+	}
+	if err := SampleHeader(&block.Header, shards, 16, withheld); err == nil {
+		t.Error("withheld shard passed sampling")
+	}
+
+	// So is a producer serving chunks that do not match the commitment
+	tampered := func(req *SampleRequest) (*SampleResponse, error) {
+		resp, err := store.Serve(req)
+		if err == nil {
+			resp = &SampleResponse{Chunk: append([]byte{resp.Chunk[0] ^ 0xff}, resp.Chunk[1:]...), Path: resp.Path}
+		}
+		return resp, err
+	}
+	if err := SampleHeader(&block.Header, []int{7}, 4, tampered); err == nil {
+		t.Error("tampered chunks passed sampling")
+	}
+
+	legacy := block.Header
+	legacy.Version = types.HeaderVersionECVRF
+	if err := SampleHeader(&legacy, shards, 1, store.Serve); err == nil {
+		t.Error("header without data commitments passed sampling")
+	}
+
+	// Heights before the fork are not sampled
+	defer func(height uint64) { types.DataAvailabilityActivationHeight = height }(types.DataAvailabilityActivationHeight)
+	types.DataAvailabilityActivationHeight = 10
+	if err := SampleHeader(&legacy, shards, 1, withheld); err != nil {
+		t.Errorf("pre-fork header was sampled: %v", err)
+	}
+}
+
+func TestEncodingFraudProof(t *testing.T) {
+	var block types.Block
+	block.Header.Version = types.HeaderVersionDataAvailability
+	block.Header.Height = 9
+	block.Shards[2].TxData = testShard(30)
+	ids := make([][32]byte, 30)
+	for i, tx := range block.Shards[2].TxData {
+		ids[i] = tx.ID
+	}
+	block.Header.ShardRoots[2] = utils.CalculateMerkleRoot(ids)
+	if err := Commit(&block); err != nil {
+		t.Fatal(err)
+	}
+
+	// An honest shard cannot be proven fraudulent
+	store := NewStore(10)
+	if err := store.Add(&block); err != nil {
+		t.Fatal(err)
+	}
+	if proof := FindEncodingFraud(&block.Header, 2, store.Serve); proof != nil {
+		t.Fatal("honest shard proven fraudulent")
+	}
+
+	// A producer committing to (and serving) other data than the shard's transactions is caught
+	forged := block
+	forged.Shards[2].TxData = testShard(12)
+	if err := Commit(&forged); err != nil {
+		t.Fatal(err)
+	}
+	forged.Header.ShardRoots = block.Header.ShardRoots
+	if err := store.Add(&forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := SampleHeader(&forged.Header, []int{2}, 16, store.Serve); err != nil {
+		t.Fatalf("forged data failed sampling: %v", err)
+	}
+	proof := FindEncodingFraud(&forged.Header, 2, store.Serve)
+	if proof == nil {
+		t.Fatal("forged data commitment not proven")
+	}
+	if err := VerifyEncodingFraud(proof); err != nil {
+		t.Errorf("fraud proof rejected: %v", err)
+	}
+
+	// The proof only holds for the header it was made for
+	proof.Header = block.Header
+	if err := VerifyEncodingFraud(proof); err == nil {
+		t.Error("fraud proof accepted for the honest header")
+	}
+}
+
+func TestStorePrunesWithBlockBodies(t *testing.T) {
+	store := NewStore(3)
+	var hashes [][32]byte
+	for height := uint64(1); height <= 5; height++ {
+		var block types.Block
+		block.Header.Version = types.HeaderVersionDataAvailability
+		block.Header.Height = height
+		block.Shards[0].TxData = testShard(1)
+		if err := Commit(&block); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(&block); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, types.HashBlockHeader(block.Header))
+	}
+
+	// Like storage.PruneOldBlocks at height 5: bodies 3..5 are kept
+	for i, hash := range hashes {
+		_, err := store.Serve(&SampleRequest{BlockHash: hash, Height: uint64(i + 1)})
+		if kept := i+1 > 2; kept != (err == nil) {
+			t.Errorf("height %d served: %v, expected %v", i+1, err == nil, kept)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package availability
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// SampleRequest asks a peer for one chunk of a shard
+type SampleRequest struct {
+	BlockHash [32]byte `json:"blockHash"` // HashBlockHeader of the sampled header (covers DataRoots)
+	Height    uint64   `json:"height"`
+	ShardID   uint8    `json:"shardId"`
+	Index     uint16   `json:"index"`
+}
+
+// SampleResponse is a chunk with its Merkle path to the shard's data root
+type SampleResponse struct {
+	Chunk []byte     `json:"chunk"`
+	Path  [][32]byte `json:"path"`
+}
+
+// VerifySample checks that resp is the chunk req asked for in the shard committed to by header
+func VerifySample(header *types.BlockHeader, req *SampleRequest, resp *SampleResponse) error {
+	if req.ShardID >= 10 {
+		return fmt.Errorf("invalid shard ID: %d", req.ShardID)
+	}
+	// Indices are bounded by the committed chunk count, so padding copies in the tree cannot be sampled
+	if int(req.Index) >= 2*int(header.DataChunks[req.ShardID]) {
+		return fmt.Errorf("chunk %d out of range for shard %d", req.Index, req.ShardID)
+	}
+	if !utils.VerifyMerkleProof(chunkLeaf(resp.Chunk), int(req.Index), resp.Path, header.DataRoots[req.ShardID]) {
+		return fmt.Errorf("chunk %d of shard %d does not match the data root", req.Index, req.ShardID)
+	}
+	return nil
+}
+
+// SampleHeader checks that the data of shards of header is available
+// It fetches samples chunks of every listed non-empty shard at random indices
+// (unpredictable to the serving peers) and fails unless all of them verify.
+// Blocks from before the data availability fork have nothing to sample.
+func SampleHeader(header *types.BlockHeader, shards []int, samples int, fetch func(*SampleRequest) (*SampleResponse, error)) error {
+	if header.Height < types.DataAvailabilityActivationHeight {
+		return nil
+	}
+	if header.Version < types.HeaderVersionDataAvailability {
+		return fmt.Errorf("header version %d does not commit to shard data", header.Version)
+	}
+
+	blockHash := types.HashBlockHeader(*header)
+	var requests []*SampleRequest
+	for _, shardID := range shards {
+		if shardID < 0 || shardID >= 10 {
+			return fmt.Errorf("invalid shard ID: %d", shardID)
+		}
+		chunks := int64(2 * int(header.DataChunks[shardID]))
+		if chunks == 0 {
+			if header.DataRoots[shardID] != ([32]byte{}) {
+				return fmt.Errorf("empty shard %d has a data root", shardID)
+			}
+			continue
+		}
+		for i := 0; i < samples; i++ {
+			index, err := rand.Int(rand.Reader, big.NewInt(chunks))
+			if err != nil {
+				return err
+			}
+			requests = append(requests, &SampleRequest{
+				BlockHash: blockHash,
+				Height:    header.Height,
+				ShardID:   uint8(shardID),
+				Index:     uint16(index.Uint64()),
+			})
+		}
+	}
+
+	errs := make(chan error, len(requests))
+	var wg sync.WaitGroup
+	for _, req := range requests {
+		wg.Add(1)
+		go func(req *SampleRequest) {
+			defer wg.Done()
+			resp, err := fetch(req)
+			if err == nil {
+				err = VerifySample(header, req, resp)
+			}
+			if err != nil {
+				errs <- fmt.Errorf("sample %d of shard %d failed: %v", req.Index, req.ShardID, err)
+			}
+		}(req)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs // nil when every sample succeeded
+}
+
+// storedBlock holds the extended shards of one block
+type storedBlock struct {
+	height uint64
+	shards [10]*ExtendedShard
+}
+
+// Store keeps the extended shards of recent blocks to answer sample requests
+// It serves the same heights a node keeps block bodies for (see storage.PruneOldBlocks).
+type Store struct {
+	blocks map[[32]byte]*storedBlock // HashBlockHeader -> shards
+	window uint64                    // Heights kept, up to and including the latest block
+	latest uint64
+	mu     sync.RWMutex
+}
+
+// NewStore creates a store serving the last window heights
+func NewStore(window uint64) *Store {
+	return &Store{
+		blocks: make(map[[32]byte]*storedBlock),
+		window: window,
+	}
+}
+
+// Add erasure-codes the shards of block and prunes blocks that left the window
+func (s *Store) Add(block *types.Block) error {
+	if block.Header.Version < types.HeaderVersionDataAvailability {
+		return nil // Not sampled
+	}
+	stored := &storedBlock{height: block.Header.Height}
+	for i, shard := range block.Shards {
+		extended, err := EncodeShard(shard.TxData)
+		if err != nil {
+			return fmt.Errorf("shard %d: %v", i, err)
+		}
+		stored.shards[i] = extended
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if block.Header.Height > s.latest {
+		s.latest = block.Header.Height
+	}
+	if block.Header.Height+s.window <= s.latest {
+		return nil // Already pruned
+	}
+	s.blocks[types.HashBlockHeader(block.Header)] = stored
+	for hash, b := range s.blocks {
+		if b.height+s.window <= s.latest {
+			delete(s.blocks, hash)
+		}
+	}
+	return nil
+}
+
+// Serve answers a sample request from a peer
+func (s *Store) Serve(req *SampleRequest) (*SampleResponse, error) {
+	if req.ShardID >= 10 {
+		return nil, fmt.Errorf("invalid shard ID: %d", req.ShardID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.blocks[req.BlockHash]
+	if !ok {
+		return nil, fmt.Errorf("block %x not available", req.BlockHash[:4])
+	}
+	shard := stored.shards[req.ShardID]
+	if shard == nil || int(req.Index) >= len(shard.Chunks) {
+		return nil, fmt.Errorf("chunk %d of shard %d not available", req.Index, req.ShardID)
+	}
+	return &SampleResponse{
+		Chunk: shard.Chunks[req.Index],
+		Path:  utils.CalculateMerkleProof(shard.Leaves, int(req.Index)),
+	}, nil
+}
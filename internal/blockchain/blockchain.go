@@ -6,10 +6,13 @@ import (
 	"sync"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/config"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/finality"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/state"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/storage"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 type Blockchain struct {
@@ -20,17 +23,43 @@ type Blockchain struct {
 	mu                sync.RWMutex
 	tip               types.BlockHeader
 	shardConfig       config.ShardConfig
+	fraudulent        map[[32]byte]bool // Full header hashes of blocks proven invalid by fraud proofs
+
+	// Samples the data of shards this node does not download (nil = trust headers)
+	sampleData func(header *types.BlockHeader, shards []int) error
+
+	// Validator set that must commit the block at a height (nil = no commit certificates, e.g. PoW)
+	validatorsAt func(height uint64) *bft.ValidatorSet
 }
 
 // NewBlockchain creates a new Blockchain instance
-func NewBlockchain(db *storage.Store, shardCfg config.ShardConfig) *Blockchain {
+// It fails if the finality or fraud records or the genesis block cannot be loaded or saved.
+func NewBlockchain(db *storage.Store, shardCfg config.ShardConfig) (*Blockchain, error) {
 	bc := &Blockchain{
 		store:           db,
 		stateManager:    state.NewManager(db.GetDB()),
 		shardConfig:     shardCfg,
+		fraudulent:      make(map[[32]byte]bool),
 		finalityTracker: finality.NewFinalityTracker(100), // Checkpoint every 100 blocks
 	}
 
+	// Reload finality so a restart cannot reorg finalized blocks
+	if err := bc.finalityTracker.SetStore(db); err != nil {
+		return nil, err
+	}
+	if height := bc.finalityTracker.GetFinalizedHeight(); height > 0 {
+		fmt.Printf("🔒 Finality restored at height %d\n", height)
+	}
+
+	// Blocks proven invalid stay rejected after a restart
+	hashes, err := db.LoadFraudulent()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		bc.fraudulent[hash] = true
+	}
+
 	// Initialize Contract Processor
 	// TEMP DISABLED: Circular import issue with vm package
 	// bc.contractProcessor = NewContractProcessor(
@@ -45,7 +74,7 @@ func NewBlockchain(db *storage.Store, shardCfg config.ShardConfig) *Blockchain {
 		var header types.BlockHeader
 		if err := json.Unmarshal(tipData, &header); err == nil {
 			bc.tip = header
-			return bc
+			return bc, nil
 		}
 	}
 
@@ -54,7 +83,7 @@ func NewBlockchain(db *storage.Store, shardCfg config.ShardConfig) *Blockchain {
 		// Initialize with Mainnet genesis by default
 		genesis := CreateGenesisBlock(true)
 		if err := db.SaveBlock(genesis); err != nil {
-			return nil
+			return nil, fmt.Errorf("failed to save genesis block: %v", err)
 		}
 		// CRITICAL: Set tip to genesis header after creation!
 		bc.tip = genesis.Header
@@ -63,7 +92,7 @@ func NewBlockchain(db *storage.Store, shardCfg config.ShardConfig) *Blockchain {
 		fmt.Printf("🌍 Genesis Block Created. Hash: %x\n", bc.tip.Hash)
 	}
 
-	return bc
+	return bc, nil
 }
 
 func (bc *Blockchain) GetTip() types.BlockHeader {
@@ -81,18 +110,130 @@ func (bc *Blockchain) GetBlockByHeight(height uint64) *types.BlockHeader {
 	return header
 }
 
+// GetBlock retrieves the block at height with its body, while the body is not pruned
+func (bc *Blockchain) GetBlock(height uint64) (*types.Block, error) {
+	return bc.store.GetBlock(height)
+}
+
+// GetCommit retrieves the BFT commit certificate of the block at height
+func (bc *Blockchain) GetCommit(height uint64) (*types.Commit, error) {
+	return bc.store.GetCommit(height)
+}
+
 // GetStateManager returns the state manager for external access
 func (bc *Blockchain) GetStateManager() *state.Manager {
 	return bc.stateManager
 }
 
+// SetDataSampler installs the data availability check run before blocks are accepted
+// (e.g. availability.SampleHeader over P2P). Only shards the node does not validate are sampled.
+func (bc *Blockchain) SetDataSampler(sample func(header *types.BlockHeader, shards []int) error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.sampleData = sample
+}
+
+// SetValidatorsAt makes every added block carry a commit certificate from the
+// validators validatorsAt returns for its height (e.g. validator.Manager.SetAt)
+func (bc *Blockchain) SetValidatorsAt(validatorsAt func(height uint64) *bft.ValidatorSet) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.validatorsAt = validatorsAt
+}
+
+// checkAvailability samples the shards of block this node does not validate
+// It runs without the chain lock, since sampling waits on the network.
+func (bc *Blockchain) checkAvailability(block types.Block) error {
+	bc.mu.RLock()
+	sample := bc.sampleData
+	bc.mu.RUnlock()
+	if sample == nil || block.Header.Height == 0 || bc.shardConfig.Role == "FullNode" {
+		return nil
+	}
+
+	validated := make(map[int]bool)
+	for _, id := range bc.shardConfig.ShardIDs {
+		validated[id] = true
+	}
+	var shards []int
+	for i := 0; i < 10; i++ {
+		if !validated[i] {
+			shards = append(shards, i)
+		}
+	}
+	if err := sample(&block.Header, shards); err != nil {
+		return fmt.Errorf("data availability check failed: %v", err)
+	}
+	return nil
+}
+
 // AddBlock validates and saves a block
 func (bc *Blockchain) AddBlock(block types.Block) error {
+	if err := bc.checkAvailability(block); err != nil {
+		return err
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	// BFT blocks are only added once committed, with the certificate to prove it
+	if bc.validatorsAt != nil && block.Header.Height > 0 {
+		if err := ValidateCommit(block, bc.validatorsAt(block.Header.Height)); err != nil {
+			return fmt.Errorf("block validation failed: %v", err)
+		}
+	}
+
+	if err := bc.applyBlock(block); err != nil {
+		return err
+	}
+
+	// 4. Save the block, its state changes and the new tip in one write
+	batch := new(leveldb.Batch)
+	bc.store.StageBlock(batch, block)
+	tipData, _ := json.Marshal(block.Header)
+	bc.store.StageTip(batch, tipData)
+	if err := bc.stateManager.CommitWith(batch); err != nil {
+		bc.stateManager.Discard()
+		return fmt.Errorf("failed to save block: %v", err)
+	}
+
+	// 5. Update Tip
+	bc.tip = block.Header
+
+	// 6. Prune Old Blocks (synchronously to avoid race)
+	if block.Header.Height > 25 {
+		bc.store.PruneOldBlocks(block.Header.Height)
+	}
+
+	fmt.Printf("⛓️  Block #%d added to chain.\n", block.Header.Height)
+	return nil
+}
+
+// CheckBlock fully validates block as the next block and dry-runs its transactions
+// against state without persisting anything (used to vet BFT proposals)
+func (bc *Blockchain) CheckBlock(block types.Block) error {
+	if err := bc.checkAvailability(block); err != nil {
+		return err
+	}
+
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	if err := bc.applyBlock(block); err != nil {
+		return err
+	}
+	bc.stateManager.Discard()
+	return nil
+}
+
+// applyBlock validates block against the tip and stages its state changes
+// On error nothing stays staged; on success the caller commits or discards.
+func (bc *Blockchain) applyBlock(block types.Block) error {
 	// 0. Check Finality (prevent reorgs of finalized blocks)
-	if !bc.finalityTracker.CanReorg(block.Header.Height) {
+	// BFT finalizes a block before it is added, so the finalized block itself is allowed.
+	// Its ID is recomputed: the stored Header.Hash is only what the block claims.
+	if !bc.finalityTracker.CanReorg(block.Header.Height) &&
+		!(block.Header.Height == bc.finalityTracker.GetFinalizedHeight() && bc.isFinalizedBlock(block)) {
 		return fmt.Errorf("cannot add block at height %d: already finalized at %d",
 			block.Header.Height, bc.finalityTracker.GetFinalizedHeight())
 	}
@@ -102,6 +243,11 @@ func (bc *Blockchain) AddBlock(block types.Block) error {
 		return fmt.Errorf("invalid block height: expected %d, got %d", bc.tip.Height+1, block.Header.Height)
 	}
 
+	// 1a. Reject blocks proven invalid by a fraud proof
+	if bc.fraudulent[types.HashBlockHeader(block.Header)] {
+		return fmt.Errorf("block #%d has a valid fraud proof", block.Header.Height)
+	}
+
 	// 2. Comprehensive validation
 	if block.Header.Height > 0 {
 		if err := ValidateBlock(block, bc.tip, bc.shardConfig); err != nil {
@@ -109,40 +255,152 @@ func (bc *Blockchain) AddBlock(block types.Block) error {
 		}
 	}
 
+	// 2a. Once blocks are committed by BFT, each one proves its parent was
+	if err := bc.checkLastCommit(block); err != nil {
+		return fmt.Errorf("block validation failed: %v", err)
+	}
+
+	// 2b. Race rewards only go to the miner and staked sorters
+	if err := bc.checkShardWinners(block); err != nil {
+		return fmt.Errorf("block validation failed: %v", err)
+	}
+
 	// 3. Apply all transactions to state
+	// Account changes are staged in the state manager's dirty set and only
+	// flushed once the whole block has been applied and saved.
+	bc.stateManager.BeginBlock(block.Header.Height)
+
+	// Evidence is executed first: slashing is a consensus outcome of the block
+	for _, ev := range block.Evidence {
+		if err := bc.stateManager.ApplyEvidence(ev); err != nil {
+			bc.stateManager.Discard()
+			return fmt.Errorf("failed to apply evidence: %v", err)
+		}
+	}
+
+	// Liveness: who signed the parent block
+	if err := bc.stateManager.UpdateLiveness(block.LastCommit); err != nil {
+		bc.stateManager.Discard()
+		return fmt.Errorf("failed to update liveness: %v", err)
+	}
+
 	for _, shard := range block.Shards {
 		for _, tx := range shard.TxData {
 			// Handle contract transactions
 			if tx.Type == types.TxTypeContractDeploy || tx.Type == types.TxTypeContractCall {
 				if err := bc.contractProcessor.ProcessContractTransaction(tx); err != nil {
+					bc.stateManager.Discard()
 					return fmt.Errorf("failed to process contract tx: %v", err)
 				}
 			}
 
+			// Validity window is enforced for every shard, not only validated ones
+			if err := tx.CheckValidityWindow(block.Header.Height); err != nil {
+				bc.stateManager.Discard()
+				return fmt.Errorf("failed to apply tx %x: %v", tx.ID[:4], err)
+			}
+
+			// Multisig senders are authorized against their on-chain policy
+			if err := VerifyMultisigTransaction(tx, bc.stateManager); err != nil {
+				bc.stateManager.Discard()
+				return err
+			}
+
+			// Single-key senders must sign with their currently bound key
+			if err := VerifyAccountKey(tx, bc.stateManager); err != nil {
+				bc.stateManager.Discard()
+				return err
+			}
+
 			// Apply regular state changes
 			if err := bc.stateManager.ApplyTransaction(tx); err != nil {
+				bc.stateManager.Discard()
 				return fmt.Errorf("failed to apply tx: %v", err)
 			}
 		}
 	}
 
-	// 4. Save to Disk
-	if err := bc.store.SaveBlock(block); err != nil {
-		return err
+	// 3a. End-of-block staking (unbonding payouts, epoch validator set)
+	if err := bc.stateManager.EndBlock(block.Header.Height); err != nil {
+		bc.stateManager.Discard()
+		return fmt.Errorf("failed to end block: %v", err)
 	}
 
-	// 5. Update Tip
-	bc.tip = block.Header
+	return nil
+}
 
-	// Save tip to DB
-	tipData, _ := json.Marshal(bc.tip)
-	bc.store.SaveTip(tipData)
+// isFinalizedBlock reports whether the recomputed BFT block ID of block is the finalized one
+func (bc *Blockchain) isFinalizedBlock(block types.Block) bool {
+	parts, err := bft.PartSetHeaderOf(&block)
+	if err != nil {
+		return false
+	}
+	return bft.BlockID(block.Header, parts) == bc.finalityTracker.GetFinalizedHash()
+}
 
-	// 6. Prune Old Blocks (synchronously to avoid race)
-	if block.Header.Height > 25 {
-		bc.store.PruneOldBlocks(block.Header.Height)
+// checkShardWinners requires every sorting race winner other than the block's miner
+// (whose identity its PoW binds) to be a staked sorter, so one sort re-signed under
+// throwaway keys cannot win slots
+func (bc *Blockchain) checkShardWinners(block types.Block) error {
+	for slot, winner := range block.Header.WinningNodes {
+		if winner == ([32]byte{}) || winner == block.Header.MinerPubKey {
+			continue
+		}
+		staked, err := bc.isStakedSorter(winner)
+		if err != nil {
+			return fmt.Errorf("cannot check slot %d winner %x: %v", slot, winner[:4], err)
+		}
+		if !staked {
+			return fmt.Errorf("slot %d winner %x is not a staked sorter", slot, winner[:4])
+		}
 	}
+	return nil
+}
 
-	fmt.Printf("⛓️  Block #%d added to chain.\n", block.Header.Height)
+// IsStakedSorter reports whether key may win sorting race slots of blocks it did not mine
+// (e.g. for Aggregator.Eligible); keys whose state cannot be read are not admitted
+func (bc *Blockchain) IsStakedSorter(key [32]byte) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	staked, err := bc.isStakedSorter(key)
+	return err == nil && staked
+}
+
+// isStakedSorter reports whether key is the operator or consensus key of an unjailed
+// validator self-bonding at least MinValidatorStake
+func (bc *Blockchain) isStakedSorter(key [32]byte) (bool, error) {
+	staking := bc.stateManager.GetStakingState()
+	operator := key
+	op, ok, err := staking.OperatorOf(key)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		operator = op
+	}
+	rec, err := staking.GetValidator(operator)
+	if err != nil {
+		return false, err
+	}
+	return rec != nil && !rec.Jailed && !rec.Tombstoned && rec.Stake >= params.MinValidatorStake, nil
+}
+
+// checkLastCommit requires every block whose parent carries a BFT commit certificate
+// to include the parent's commit, with 2/3+ of the parent validators' voting power.
+// The first BFT block (on genesis or on earlier PoW blocks) has no parent commit to carry.
+func (bc *Blockchain) checkLastCommit(block types.Block) error {
+	if bc.validatorsAt == nil || block.Header.Height < 2 || !bc.store.HasCommit(bc.tip.Height) {
+		return nil
+	}
+	if block.LastCommit == nil {
+		return fmt.Errorf("block #%d omits the commit certificate of its parent", block.Header.Height)
+	}
+	valSet := bc.validatorsAt(bc.tip.Height)
+	if valSet == nil {
+		return fmt.Errorf("no validator set for height %d", bc.tip.Height)
+	}
+	if err := bft.VerifyBlockCommit(valSet, bc.tip, block.LastCommit); err != nil {
+		return fmt.Errorf("invalid last commit: %v", err)
+	}
 	return nil
 }
@@ -1,11 +1,21 @@
 package blockchain_test
 
 import (
+	"crypto/ed25519"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/blockchain"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/config"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/storage"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/validator"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/wallet"
 )
 
 func TestGenesisBlock(t *testing.T) {
@@ -28,14 +38,17 @@ func TestGenesisBlock(t *testing.T) {
 
 func TestBlockchainInitialization(t *testing.T) {
 	// Create temp database
-	db, err := storage.NewLevelDB("./test-data")
+	db, err := storage.NewLevelDB(t.TempDir())
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer db.GetDB().Close()
 
 	// Initialize blockchain
-	chain := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
 	tip := chain.GetTip()
 
 	if tip.Height != 0 {
@@ -58,3 +71,555 @@ func TestStateTransitions(t *testing.T) {
 	t.Skip("TODO: Implement state transition tests")
 	// TODO: Test balance updates, nonce increments
 }
+
+// fraudTestBlock builds a block whose shard 3 holds txs in the order given
+func fraudTestBlock(txs []types.Transaction) types.Block {
+	var block types.Block
+	block.Header.Height = 5
+	block.Header.VRFSeed = [32]byte{0x42}
+	ids := make([][32]byte, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.ID
+	}
+	block.Shards[3].TxData = txs
+	block.Header.ShardRoots[3] = utils.CalculateMerkleRoot(ids)
+
+	roots := make([][32]byte, len(block.Header.ShardRoots))
+	for i, root := range block.Header.ShardRoots {
+		roots[i] = root
+	}
+	block.Header.MerkleRoot = utils.CalculateMerkleRoot(roots)
+	return block
+}
+
+func TestShardFraudProofs(t *testing.T) {
+	w, err := wallet.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []types.Transaction
+	for nonce := uint64(1); nonce <= 5; nonce++ {
+		tx, err := w.CreateTransaction("0b", 10, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, *tx)
+	}
+	sorted, _ := consensus.SortShard(txs, [32]byte{0x42}, 3, 5)
+
+	// An honest shard yields no proof
+	if proof := blockchain.FindShardFraud(fraudTestBlock(sorted), 3); proof != nil {
+		t.Fatalf("honest shard produced a kind %d fraud proof", proof.Kind)
+	}
+
+	// Unsorted order is proven by the two adjacent transactions
+	unsorted := append([]types.Transaction(nil), sorted...)
+	unsorted[1], unsorted[2] = unsorted[2], unsorted[1]
+	proof := blockchain.FindShardFraud(fraudTestBlock(unsorted), 3)
+	if proof == nil || proof.Kind != types.FraudUnsorted || proof.Txs[0].Index != 1 {
+		t.Fatalf("expected an unsorted proof at index 1, got %+v", proof)
+	}
+	if err := blockchain.VerifyFraudProof(proof); err != nil {
+		t.Errorf("unsorted proof rejected: %v", err)
+	}
+
+	// A forged signature is proven by the transaction alone
+	forged := append([]types.Transaction(nil), sorted...)
+	forged[4].Signature[0] ^= 0xff
+	proof = blockchain.FindShardFraud(fraudTestBlock(forged), 3)
+	if proof == nil || proof.Kind != types.FraudBadSignature {
+		t.Fatalf("expected a bad signature proof, got %+v", proof)
+	}
+	if err := blockchain.VerifyFraudProof(proof); err != nil {
+		t.Errorf("bad signature proof rejected: %v", err)
+	}
+
+	// The same claim against the honest block fails: the signature is valid
+	honest := fraudTestBlock(sorted)
+	proof.Header = honest.Header
+	proof.Txs[0].Tx = sorted[4]
+	if err := blockchain.VerifyFraudProof(proof); err == nil {
+		t.Error("fraud proof against an honest shard accepted")
+	}
+
+	// Padding copies past the end of the shard cannot be used as transactions
+	ids := make([][32]byte, len(sorted)+1)
+	for i, tx := range sorted {
+		ids[i] = tx.ID
+	}
+	ids[len(sorted)] = sorted[len(sorted)-1].ID
+	phantom := &types.FraudProof{
+		Kind:    types.FraudUnsorted,
+		Header:  honest.Header,
+		ShardID: 3,
+		Txs: []types.FraudTx{
+			{Tx: sorted[4], Index: 4, Path: utils.CalculateMerkleProof(ids, 4)},
+			{Tx: sorted[4], Index: 5, Path: utils.CalculateMerkleProof(ids, 5)},
+		},
+	}
+	if err := phantom.VerifyInclusion(); err == nil {
+		t.Error("path to a padding copy accepted")
+	}
+}
+
+func TestFinalityPersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := [32]byte{0xab}
+	commit := &types.Commit{Height: 100, Round: 1, BlockHash: hash, Signatures: []types.CommitSig{{ValidatorAddress: [32]byte{1}}}}
+	if err := chain.MarkBlockFinalized(100, hash, commit); err != nil {
+		t.Fatal(err)
+	}
+	db.GetDB().Close()
+
+	// A restarted node must not reorganize below the persisted finality
+	db, err = storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err = blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := chain.GetFinalityTracker()
+
+	if tracker.GetFinalizedHeight() != 100 || tracker.GetFinalizedHash() != hash {
+		t.Fatalf("finality not restored: height %d", tracker.GetFinalizedHeight())
+	}
+	if cps := tracker.GetCheckpointHeights(); len(cps) != 1 || cps[0] != 100 {
+		t.Errorf("expected checkpoint at 100, got %v", cps)
+	}
+	if rec := tracker.GetFinalizedRecord(); rec.Commit == nil || rec.Commit.Round != 1 || len(rec.Commit.Signatures) != 1 {
+		t.Error("finalizing commit was not restored")
+	}
+	if tracker.CanReorg(100) {
+		t.Error("finalized height can be reorganized after restart")
+	}
+}
+
+func TestFinalizedBlockHashIsRecomputed(t *testing.T) {
+	db, err := storage.NewLevelDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := types.Block{Header: types.BlockHeader{Height: 1, PrevBlockHash: chain.GetTip().Hash, Timestamp: 1}}
+	parts, err := bft.PartSetHeaderOf(&block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalized := bft.BlockID(block.Header, parts)
+	if err := chain.MarkBlockFinalized(1, finalized, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A block with the same PoW hash but other contents must not get past the finality check
+	forged := block
+	forged.Header.Hash = types.HashBlockHeaderForPoW(block.Header)
+	forged.Header.MerkleRoot = [32]byte{0xee}
+	if err := chain.AddBlock(forged); err == nil || !strings.Contains(err.Error(), "already finalized") {
+		t.Errorf("block posing as the finalized one not rejected by finality: %v", err)
+	}
+}
+
+func TestAddBlockRequiresCommitOverWholeHeader(t *testing.T) {
+	db, err := storage.NewLevelDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var addr [32]byte
+	copy(addr[:], pub)
+	valSet := bft.NewValidatorSet([]*bft.Validator{{Address: addr, PubKey: pub, VotingPower: 1}})
+	chain.SetValidatorsAt(func(uint64) *bft.ValidatorSet { return valSet })
+
+	block := types.Block{Header: types.BlockHeader{Height: 1, PrevBlockHash: chain.GetTip().Hash, Timestamp: 1}}
+	block.Header.Hash = types.HashBlockHeaderForPoW(block.Header)
+	parts, err := bft.PartSetHeaderOf(&block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitTo := func(hash [32]byte) *types.Commit {
+		vote := &bft.Vote{Type: bft.VoteTypePrecommit, Height: 1, BlockHash: hash, Timestamp: 1, ValidatorAddress: addr}
+		vote.Sign(priv)
+		return &types.Commit{Height: 1, BlockHash: hash, PartsTotal: parts.Total, PartsRoot: parts.Root,
+			Signatures: []types.CommitSig{{ValidatorAddress: addr, Timestamp: 1, Signature: vote.Signature}}}
+	}
+
+	if err := chain.AddBlock(block); err == nil || !strings.Contains(err.Error(), "commit certificate") {
+		t.Errorf("block without a commit certificate not rejected for it: %v", err)
+	}
+
+	// A commit to the PoW hash leaves the rest of the header open to substitution
+	block.Commit = commitTo(block.Header.Hash)
+	if err := chain.AddBlock(block); err == nil || !strings.Contains(err.Error(), "commit certificate") {
+		t.Errorf("commit to the PoW hash not rejected: %v", err)
+	}
+
+	block.Commit = commitTo(bft.BlockID(block.Header, parts))
+	if err := chain.AddBlock(block); err != nil && strings.Contains(err.Error(), "commit") {
+		t.Errorf("valid commit certificate rejected: %v", err)
+	}
+
+	// The same header with another body is not what the validators committed
+	swapped := block
+	swapped.Shards[3].AlgoUsed = "QUICK_SORT"
+	if err := chain.AddBlock(swapped); err == nil || !strings.Contains(err.Error(), "commit certificate") {
+		t.Errorf("commit accepted for another body: %v", err)
+	}
+}
+
+func TestBFTBlocksCarryTheirParentsCommit(t *testing.T) {
+	db, err := storage.NewLevelDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var addr [32]byte
+	copy(addr[:], pub)
+	valSet := bft.NewValidatorSet([]*bft.Validator{{Address: addr, PubKey: pub, VotingPower: 1}})
+	chain.SetValidatorsAt(func(uint64) *bft.ValidatorSet { return valSet })
+
+	miner := consensus.NewPoWEngine(1, priv, chain.GetTip)
+	commit := func(block *types.Block) *types.Commit {
+		parts, err := bft.PartSetHeaderOf(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vote := &bft.Vote{Type: bft.VoteTypePrecommit, Height: block.Header.Height, BlockHash: bft.BlockID(block.Header, parts), Timestamp: 1, ValidatorAddress: addr}
+		vote.Sign(priv)
+		return &types.Commit{Height: vote.Height, BlockHash: vote.BlockHash, PartsTotal: parts.Total, PartsRoot: parts.Root,
+			Signatures: []types.CommitSig{{ValidatorAddress: addr, Timestamp: 1, Signature: vote.Signature}}}
+	}
+
+	// The first BFT block has no parent commit to carry
+	first, err := miner.RunConsensusRound(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Commit = commit(first)
+	if err := chain.AddBlock(*first); err != nil {
+		t.Fatalf("first BFT block rejected: %v", err)
+	}
+
+	second, err := miner.RunConsensusRound(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Commit = commit(second)
+	if err := chain.AddBlock(*second); err == nil || !strings.Contains(err.Error(), "omits the commit certificate") {
+		t.Errorf("block without its parent's commit not rejected: %v", err)
+	}
+
+	// A last commit short of 2/3+ of the parent validators' power proves nothing
+	second.LastCommit = commit(first)
+	second.LastCommit.Signatures = nil
+	second.Commit = commit(second)
+	if err := chain.AddBlock(*second); err == nil || !strings.Contains(err.Error(), "invalid last commit") {
+		t.Errorf("block with a partial last commit not rejected: %v", err)
+	}
+
+	second.LastCommit = first.Commit
+	second.Commit = commit(second)
+	if err := chain.AddBlock(*second); err != nil {
+		t.Errorf("block with its parent's commit rejected: %v", err)
+	}
+}
+
+func TestRaceWinnersMustBeStaked(t *testing.T) {
+	db, err := storage.NewLevelDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err := blockchain.NewBlockchain(db, config.ShardConfig{Role: "FullNode", ShardIDs: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, minerPriv, _ := ed25519.GenerateKey(nil)
+	block, err := consensus.NewPoWEngine(1, minerPriv, chain.GetTip).RunConsensusRound(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A throwaway key re-signing the miner's sort of slot 3 may not take its reward
+	_, sybilPriv, _ := ed25519.GenerateKey(nil)
+	stolen := *block
+	proof := consensus.BlockShardProof(&stolen, 3)
+	proof.Sign(sybilPriv)
+	if chain.IsStakedSorter(proof.NodeID) {
+		t.Fatal("unbonded key counted as a staked sorter")
+	}
+	stolen.Header.WinningNodes[3] = proof.NodeID
+	stolen.Shards[3].NodeID, stolen.Shards[3].Signature = proof.NodeID, proof.Signature
+	if err := chain.AddBlock(stolen); err == nil || !strings.Contains(err.Error(), "not a staked sorter") {
+		t.Errorf("block crediting an unstaked sorter not rejected: %v", err)
+	}
+
+	if err := chain.AddBlock(*block); err != nil {
+		t.Fatalf("block won by its miner rejected: %v", err)
+	}
+}
+
+func TestAggregatedBlocksAreValid(t *testing.T) {
+	db, err := storage.NewLevelDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	shardCfg := config.ShardConfig{Role: "FullNode", ShardIDs: []int{}}
+	chain, err := blockchain.NewBlockchain(db, shardCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wallet.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []types.Transaction
+	for nonce := uint64(1); nonce <= 20; nonce++ {
+		tx, err := w.CreateTransaction("0b", 10, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, *tx)
+	}
+
+	// A sorter answers every announced round with shards from its own mempool
+	minerPub, minerPriv, _ := ed25519.GenerateKey(nil)
+	_, sorterPriv, _ := ed25519.GenerateKey(nil)
+	var miner, sorter [32]byte
+	copy(miner[:], minerPub)
+	copy(sorter[:], sorterPriv.Public().(ed25519.PublicKey))
+	agg := consensus.NewAggregator()
+	agg.Deadline = time.Second
+	engine := consensus.NewPoWEngine(1, minerPriv, chain.GetTip)
+	engine.Aggregation = &consensus.Aggregation{
+		Aggregator: agg,
+		Publish: func(msg *consensus.RaceMessage) error {
+			for slot := uint8(0); slot < 10; slot++ {
+				shard, err := consensus.SolveRound(msg.Round, slot, txs, sorterPriv)
+				if err != nil {
+					return err
+				}
+				agg.SubmitShard(msg.Round.Height, shard.Proof, shard.Txs)
+			}
+			return nil
+		},
+	}
+
+	block, err := engine.RunConsensusRound(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carried := 0
+	for slot, shard := range block.Shards {
+		if block.Header.WinningNodes[slot] != sorter {
+			t.Errorf("slot %d not won by the sorter", slot)
+		}
+		carried += len(shard.TxData)
+	}
+	if carried != len(txs) {
+		t.Errorf("aggregated block carries %d of %d transactions", carried, len(txs))
+	}
+	if err := blockchain.ValidateBlock(*block, chain.GetTip(), shardCfg); err != nil {
+		t.Fatalf("aggregated block invalid: %v", err)
+	}
+
+	// Unstaked sorters are turned away; the miner fills their slots and the block is applied
+	agg.Eligible = func(slot uint8, nodeID [32]byte) bool {
+		return nodeID == miner || chain.IsStakedSorter(nodeID)
+	}
+	block, err = engine.RunConsensusRound(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slot, winner := range block.Header.WinningNodes {
+		if winner != miner {
+			t.Errorf("slot %d credited to %x, expected the miner", slot, winner[:4])
+		}
+	}
+	if err := chain.AddBlock(*block); err != nil {
+		t.Fatalf("aggregated block rejected: %v", err)
+	}
+}
+
+func TestFraudProofsMatchTheWholeHeader(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shardCfg := config.ShardConfig{Role: "FullNode", ShardIDs: []int{}}
+	chain, err := blockchain.NewBlockchain(db, shardCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, minerPriv, _ := ed25519.GenerateKey(nil)
+	honest, err := consensus.NewPoWEngine(1, minerPriv, chain.GetTip).RunConsensusRound(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(*honest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The honest header with doctored shard roots keeps its seal and PoW hash
+	w, err := wallet.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := w.CreateTransaction("0b", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Signature[0] ^= 0xff
+	doctored := fraudTestBlock([]types.Transaction{*tx})
+	header := honest.Header
+	header.ShardRoots, header.MerkleRoot = doctored.Header.ShardRoots, doctored.Header.MerkleRoot
+	doctored.Header = header
+	proof := blockchain.FindShardFraud(doctored, 3)
+	if proof == nil {
+		t.Fatal("no fraud proof for the doctored block")
+	}
+	if err := chain.HandleFraudProof(proof); err != nil {
+		t.Fatal(err)
+	}
+	if chain.GetTip().Height != 1 || chain.IsFraudulent(types.HashBlockHeader(honest.Header)) {
+		t.Fatal("proof against a doctored header condemned the honest block")
+	}
+	db.GetDB().Close()
+
+	// The doctored block stays rejected after a restart
+	db, err = storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.GetDB().Close()
+	chain, err = blockchain.NewBlockchain(db, shardCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain.IsFraudulent(proof.BlockHash()) {
+		t.Error("fraudulent block forgotten after restart")
+	}
+}
+
+func TestLastCommitVerifiesAfterRestartPastEpochChange(t *testing.T) {
+	dir := t.TempDir()
+	shardCfg := config.ShardConfig{Role: "FullNode", ShardIDs: []int{}}
+	genesisPub, genesisPriv, _ := ed25519.GenerateKey(nil)
+	electedPub, electedPriv, _ := ed25519.GenerateKey(nil)
+	operator, err := wallet.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nodes start from the genesis validator and replay the sets elected on-chain
+	var db *storage.Store
+	var chain *blockchain.Blockchain
+	var valMgr *validator.Manager
+	open := func() {
+		if db, err = storage.NewLevelDB(dir); err != nil {
+			t.Fatal(err)
+		}
+		if chain, err = blockchain.NewBlockchain(db, shardCfg); err != nil {
+			t.Fatal(err)
+		}
+		var genesisAddr [32]byte
+		copy(genesisAddr[:], genesisPub)
+		valMgr = validator.NewManager(params.MinValidatorStake, params.MaxValidators, params.EpochLength,
+			[]*bft.Validator{{Address: genesisAddr, PubKey: genesisPub, VotingPower: 1}})
+		if err := valMgr.LoadHistory(chain.GetStateManager().GetStakingState()); err != nil {
+			t.Fatal(err)
+		}
+		chain.SetValidatorsAt(valMgr.SetAt)
+	}
+	open()
+
+	var last *types.Commit
+	add := func(txs []types.Transaction, signer ed25519.PrivateKey) {
+		height := chain.GetTip().Height + 1
+		block, err := consensus.NewPoWEngine(1, genesisPriv, chain.GetTip).RunConsensusRound(height, txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.LastCommit = last
+		parts, err := bft.PartSetHeaderOf(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var addr [32]byte
+		copy(addr[:], signer.Public().(ed25519.PublicKey))
+		vote := &bft.Vote{Type: bft.VoteTypePrecommit, Height: height, BlockHash: bft.BlockID(block.Header, parts), Timestamp: 1, ValidatorAddress: addr}
+		vote.Sign(signer)
+		block.Commit = &types.Commit{Height: height, BlockHash: vote.BlockHash, PartsTotal: parts.Total, PartsRoot: parts.Root,
+			Signatures: []types.CommitSig{{ValidatorAddress: addr, Timestamp: 1, Signature: vote.Signature}}}
+		if err := chain.AddBlock(*block); err != nil {
+			t.Fatalf("block #%d rejected: %v", height, err)
+		}
+		last = block.Commit
+
+		// As the node does after each block: follow the set elected at epoch boundaries
+		if height%params.EpochLength == 0 {
+			epoch, err := chain.GetStateManager().GetStakingState().ActiveEpoch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			valMgr.ApplyChainEpoch(epoch, height+1)
+		}
+	}
+
+	// Fund and bond a validator, who is elected at the first epoch boundary
+	var opAddr [32]byte
+	copy(opAddr[:], operator.PublicKey)
+	add([]types.Transaction{{ID: [32]byte{1, 1, 1, 1}, Receiver: opAddr, Amount: 5000}}, genesisPriv)
+	bond, err := operator.CreateStakeBond(electedPub, 2000, "", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add([]types.Transaction{*bond}, genesisPriv)
+	for chain.GetTip().Height < params.EpochLength {
+		add(nil, genesisPriv)
+	}
+	add(nil, electedPriv)
+
+	// After a restart the parent's commit is still checked against the elected set
+	db.GetDB().Close()
+	open()
+	defer db.GetDB().Close()
+	var electedAddr [32]byte
+	copy(electedAddr[:], electedPub)
+	if set := valMgr.SetAt(params.EpochLength + 1); set == nil || !set.HasAddress(electedAddr) {
+		t.Fatal("elected validator set forgotten after restart")
+	}
+	add(nil, electedPriv)
+}
@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"github.com/LICODX/PoSSR-RNRCORE/internal/finality"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // GetFinalityTracker returns the finality tracker for external access (e.g., BFT engine)
@@ -10,6 +11,7 @@ func (bc *Blockchain) GetFinalityTracker() *finality.FinalityTracker {
 }
 
 // MarkBlockFinalized marks a block as finalized (called by BFT consensus on 2/3+ precommits)
-func (bc *Blockchain) MarkBlockFinalized(height uint64, hash [32]byte) error {
-	return bc.finalityTracker.MarkFinalized(height, hash)
+// The record, with the precommits of commit, is persisted and survives restarts.
+func (bc *Blockchain) MarkBlockFinalized(height uint64, hash [32]byte, commit *types.Commit) error {
+	return bc.finalityTracker.Finalize(finality.Record{Height: height, Hash: hash, Commit: commit})
 }
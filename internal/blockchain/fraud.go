@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// badSignature reports whether tx is a single-key transaction whose signature does not verify
+// Coinbase has no signature and multisig authorization needs state, so neither can be proven bad.
+func badSignature(tx types.Transaction) bool {
+	if tx.Sender == ([32]byte{}) || tx.IsMultisig() {
+		return false
+	}
+	signingKey := tx.SigningKey()
+	return !utils.Verify(signingKey[:], types.SerializeTransaction(tx), tx.Signature[:])
+}
+
+// VerifyFraudProof checks that proof shows an invalid shard in the block of its header
+// Stateful rules (balances, nonces, bound keys) are checked by every node when it
+// applies a block, so only what ShardNodes skip for other shards can be proven.
+func VerifyFraudProof(proof *types.FraudProof) error {
+	if err := proof.VerifyInclusion(); err != nil {
+		return fmt.Errorf("invalid fraud proof: %w", err)
+	}
+
+	switch proof.Kind {
+	case types.FraudBadSignature:
+		if !badSignature(proof.Txs[0].Tx) {
+			return fmt.Errorf("invalid fraud proof: signature of tx %x is valid", proof.Txs[0].Tx.ID[:4])
+		}
+	case types.FraudUnsorted:
+		shardSeed := consensus.ShardSeed(proof.Header.VRFSeed, proof.ShardID)
+		prevKey := utils.MixHash(proof.Txs[0].Tx.ID, shardSeed)
+		currKey := utils.MixHash(proof.Txs[1].Tx.ID, shardSeed)
+		if bytes.Compare(currKey[:], prevKey[:]) >= 0 {
+			return fmt.Errorf("invalid fraud proof: transactions at %d and %d are sorted", proof.Txs[0].Index, proof.Txs[1].Index)
+		}
+	case types.FraudBadEncoding:
+		if err := availability.VerifyEncodingFraud(proof); err != nil {
+			return fmt.Errorf("invalid fraud proof: %w", err)
+		}
+	}
+	return nil
+}
+
+// FindShardFraud returns a fraud proof for the first offence in a shard of block, or nil
+// Full validators call it on blocks they reject so ShardNodes can reject them too.
+// A shard whose data does not match its root cannot be proven this way (see
+// availability.FindEncodingFraud).
+func FindShardFraud(block types.Block, shardID uint8) *types.FraudProof {
+	txs := block.Shards[shardID].TxData
+	ids := make([][32]byte, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.ID
+	}
+	if utils.CalculateMerkleRoot(ids) != block.Header.ShardRoots[shardID] {
+		return nil
+	}
+
+	proofTx := func(i int) types.FraudTx {
+		return types.FraudTx{Tx: txs[i], Index: i, Path: utils.CalculateMerkleProof(ids, i)}
+	}
+	newProof := func(kind uint8, ftxs ...types.FraudTx) *types.FraudProof {
+		return &types.FraudProof{Kind: kind, Header: block.Header, ShardID: shardID, Txs: ftxs}
+	}
+
+	shardSeed := consensus.ShardSeed(block.Header.VRFSeed, shardID)
+	var prevKey [32]byte
+	for i, tx := range txs {
+		key := utils.MixHash(tx.ID, shardSeed)
+		if i > 0 && bytes.Compare(key[:], prevKey[:]) < 0 {
+			return newProof(types.FraudUnsorted, proofTx(i-1), proofTx(i))
+		}
+		prevKey = key
+
+		if types.HashTransaction(tx) != tx.ID {
+			continue
+		}
+		if badSignature(tx) {
+			return newProof(types.FraudBadSignature, proofTx(i))
+		}
+	}
+	return nil
+}
+
+// HandleFraudProof verifies a gossiped fraud proof and acts on it
+// The block with the proof's full header is rejected from now on, also after a
+// restart; if it is already part of the chain, the chain is rolled back to its parent.
+func (bc *Blockchain) HandleFraudProof(proof *types.FraudProof) error {
+	if err := consensus.VerifyPoWSeal(&proof.Header); err != nil {
+		return fmt.Errorf("invalid fraud proof header: %w", err)
+	}
+	if err := VerifyFraudProof(proof); err != nil {
+		return err
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	hash := proof.BlockHash()
+	if bc.fraudulent[hash] {
+		return nil
+	}
+	if err := bc.store.SaveFraudulent(hash); err != nil {
+		return fmt.Errorf("failed to record fraudulent block: %v", err)
+	}
+	bc.fraudulent[hash] = true
+	fmt.Printf("🚨 Fraud proof accepted: block #%d (%x) shard %d\n", proof.Header.Height, hash[:4], proof.ShardID)
+
+	// Not applied: the block is rejected if it ever arrives
+	height := proof.Header.Height
+	if height == 0 || height > bc.tip.Height {
+		return nil
+	}
+	stored, err := bc.store.GetBlockHeaderByHeight(height)
+	if err != nil || types.HashBlockHeader(*stored) != hash {
+		return nil
+	}
+	return bc.rollbackTo(height - 1)
+}
+
+// IsFraudulent reports whether a fraud proof was accepted for the block with hash (types.HashBlockHeader)
+func (bc *Blockchain) IsFraudulent(hash [32]byte) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.fraudulent[hash]
+}
+
+// rollbackTo reverts the chain to the block at height, undoing the state of every later block
+func (bc *Blockchain) rollbackTo(height uint64) error {
+	if !bc.finalityTracker.CanReorg(height + 1) {
+		return fmt.Errorf("cannot roll back to height %d: already finalized at %d",
+			height, bc.finalityTracker.GetFinalizedHeight())
+	}
+	if bc.tip.Height-height > params.StateUndoDepth {
+		return fmt.Errorf("cannot roll back %d blocks (max %d)", bc.tip.Height-height, params.StateUndoDepth)
+	}
+
+	parent, err := bc.store.GetBlockHeaderByHeight(height)
+	if err != nil {
+		return err
+	}
+
+	for h := bc.tip.Height; h > height; h-- {
+		if err := bc.stateManager.Rollback(h); err != nil {
+			return fmt.Errorf("failed to roll back state of block #%d: %v", h, err)
+		}
+		if err := bc.store.DeleteBlock(h); err != nil {
+			return fmt.Errorf("failed to delete block #%d: %v", h, err)
+		}
+	}
+
+	bc.tip = *parent
+	tipData, _ := json.Marshal(bc.tip)
+	bc.store.SaveTip(tipData)
+
+	fmt.Printf("⏪ Chain rolled back to block #%d\n", height)
+	return nil
+}
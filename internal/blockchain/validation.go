@@ -1,13 +1,15 @@
 package blockchain
 
 import (
-	"crypto/ed25519"
+	"bytes"
 	"crypto/sha256"
 	"fmt"
-	"math/big"
 	"time"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/config"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/state"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
@@ -26,15 +28,51 @@ func ValidateTransaction(tx types.Transaction) error {
 		}
 	}
 
-	if !isCoinbase {
+	if tx.IsMultisig() {
+		// Partial signatures are checked against the Sender's registered
+		// policy in VerifyMultisigTransaction (requires state)
+		if isCoinbase {
+			return fmt.Errorf("coinbase cannot carry multisig signatures")
+		}
+		if len(tx.Signatures) > types.MaxMultisigKeys {
+			return fmt.Errorf("too many multisig signatures: %d", len(tx.Signatures))
+		}
+		if tx.PubKey != ([32]byte{}) {
+			return fmt.Errorf("multisig transaction cannot carry a signing key")
+		}
+	} else if !isCoinbase {
+		// The signing key is bound to the Sender account in VerifyAccountKey (requires state)
+		signingKey := tx.SigningKey()
 		message := types.SerializeTransaction(tx)
-		if !utils.Verify(tx.Sender[:], message, tx.Signature[:]) {
+		if !utils.Verify(signingKey[:], message, tx.Signature[:]) {
 			return fmt.Errorf("invalid signature for tx %x", tx.ID)
 		}
 	}
 
 	// 2. Basic sanity checks
-	if tx.Amount == 0 {
+	// Multisig registration may carry no funding, key rotations carry none, a
+	// multi-transfer's Amount is the native RNR total (0 for token-only batches)
+	// and staking transactions check their own amounts
+	switch {
+	case tx.Type == types.TxTypeMultiTransfer:
+		if _, err := types.DecodeMultiTransfer(tx); err != nil {
+			return err
+		}
+	case tx.Type == types.TxTypeKeyRotate:
+		if isCoinbase {
+			return fmt.Errorf("coinbase cannot rotate keys")
+		}
+		if _, err := types.DecodeKeyRotate(tx); err != nil {
+			return err
+		}
+	case types.IsStakingTx(tx.Type):
+		if isCoinbase {
+			return fmt.Errorf("coinbase cannot stake")
+		}
+		if err := types.CheckStakingTx(tx); err != nil {
+			return err
+		}
+	case tx.Amount == 0 && tx.Type != types.TxTypeMultisigRegister:
 		return fmt.Errorf("zero amount transaction")
 	}
 
@@ -49,12 +87,28 @@ func ValidateTransaction(tx types.Transaction) error {
 }
 
 // ValidateTransactionAgainstState verifies tx against current state (Nonce & Balance)
-func ValidateTransactionAgainstState(tx types.Transaction, stateDir *state.Manager) error {
+// height is the height of the block the transaction would be included in.
+func ValidateTransactionAgainstState(tx types.Transaction, stateDir *state.Manager, height uint64) error {
 	// 1. Basic validation first
 	if err := ValidateTransaction(tx); err != nil {
 		return err
 	}
 
+	// 1a. Validity window (not-before / expiry height)
+	if err := tx.CheckValidityWindow(height); err != nil {
+		return err
+	}
+
+	// 1b. Multisig authorization
+	if err := VerifyMultisigTransaction(tx, stateDir); err != nil {
+		return err
+	}
+
+	// 1c. Signing key must be the one currently bound to the account
+	if err := VerifyAccountKey(tx, stateDir); err != nil {
+		return err
+	}
+
 	// 2. Get Account State
 	acc, err := stateDir.GetAccount(tx.Sender)
 	if err != nil {
@@ -78,11 +132,56 @@ func ValidateTransactionAgainstState(tx types.Transaction, stateDir *state.Manag
 		}
 
 		// 4. Check Balance (Prevent Mempool Spam)
-		if acc.Balance < tx.Amount {
-			return fmt.Errorf("insufficient balance: have %d, want %d", acc.Balance, tx.Amount)
+		required := tx.Amount
+		if types.PaidFromStake(tx.Type) {
+			required = 0 // Paid out of stake
 		}
+		if tx.Type == types.TxTypeMultiTransfer {
+			payload, _ := types.DecodeMultiTransfer(tx) // Checked in ValidateTransaction
+			required += state.MultiTransferFee(len(payload.Outputs))
+		}
+		if acc.Balance < required {
+			return fmt.Errorf("insufficient balance: have %d, want %d", acc.Balance, required)
+		}
+	}
+
+	return nil
+}
+
+// VerifyMultisigTransaction checks partial signatures against the Sender's registered policy
+// Single-key transactions pass through unchanged.
+func VerifyMultisigTransaction(tx types.Transaction, stateDir *state.Manager) error {
+	if !tx.IsMultisig() {
+		return nil
+	}
+
+	policy, err := stateDir.GetMultisigState().GetPolicy(tx.Sender)
+	if err != nil {
+		return fmt.Errorf("sender is not a multisig account: %v", err)
 	}
 
+	if err := policy.VerifyTransaction(tx); err != nil {
+		return fmt.Errorf("multisig authorization failed for tx %x: %v", tx.ID, err)
+	}
+	return nil
+}
+
+// VerifyAccountKey checks that tx was signed with the key currently bound to its Sender
+// Coinbase and multisig transactions are authorized elsewhere.
+func VerifyAccountKey(tx types.Transaction, stateDir *state.Manager) error {
+	if tx.IsMultisig() || tx.Sender == ([32]byte{}) {
+		return nil
+	}
+
+	acc, err := stateDir.GetAccount(tx.Sender)
+	if err != nil {
+		return fmt.Errorf("failed to get account state: %v", err)
+	}
+
+	if signingKey := tx.SigningKey(); signingKey != acc.BoundKey(tx.Sender) {
+		return fmt.Errorf("tx %x signed with key %x, account is bound to another key",
+			tx.ID[:4], signingKey[:4])
+	}
 	return nil
 }
 
@@ -90,7 +189,7 @@ func ValidateTransactionAgainstState(tx types.Transaction, stateDir *state.Manag
 func ValidateBlock(block types.Block, prevHeader types.BlockHeader, shardCfg config.ShardConfig) error {
 	// 1. Validate timestamp (not too far in future)
 	now := time.Now().Unix()
-	if block.Header.Timestamp > now+600 {
+	if block.Header.Timestamp > now+consensus.MaxFutureBlockTime {
 		return fmt.Errorf("block timestamp too far in future")
 	}
 
@@ -103,24 +202,9 @@ func ValidateBlock(block types.Block, prevHeader types.BlockHeader, shardCfg con
 		}
 	}
 
-	// 2a. Validate PoW (Difficuly Target)
-	// Crucial after criticism about "fake PoW"
-	powHash := types.HashBlockHeaderForPoW(block.Header)
-	hashInt := new(big.Int).SetBytes(powHash[:])
-	maxVal := new(big.Int).Exp(big.NewInt(2), big.NewInt(256), nil)
-	targetVal := new(big.Int).Div(maxVal, big.NewInt(int64(block.Header.Difficulty)))
-	if hashInt.Cmp(targetVal) != -1 {
-		return fmt.Errorf("block hash does not meet difficulty target")
-	}
-
-	// 2b. Validate VRF (Miner Signature & Seed Derivation)
-	// Seed MUST be H(Signature(Miner, PoWHash))
-	if !ed25519.Verify(ed25519.PublicKey(block.Header.MinerPubKey[:]), powHash[:], block.Header.MinerSignature[:]) {
-		return fmt.Errorf("invalid miner signature (VRF proof failed)")
-	}
-	expectedSeed := sha256.Sum256(block.Header.MinerSignature[:])
-	if expectedSeed != block.Header.VRFSeed {
-		return fmt.Errorf("VRF seed mismatch: does not match signature entropy")
+	// 2a. Validate PoW (Difficulty Target) and VRF (Miner Signature & Seed Derivation)
+	if err := consensus.VerifyPoWSeal(&block.Header); err != nil {
+		return err
 	}
 
 	// 3. Validate block size
@@ -143,6 +227,21 @@ func ValidateBlock(block types.Block, prevHeader types.BlockHeader, shardCfg con
 			block.Header.MerkleRoot, recalculatedGlobalRoot)
 	}
 
+	// 4a. Validate evidence (signatures here, stake and age when applied)
+	if err := validateEvidence(block); err != nil {
+		return err
+	}
+
+	// 4b. Validate the previous block's commit (signatures are counted when applied)
+	if err := validateLastCommit(block, prevHeader); err != nil {
+		return err
+	}
+
+	// 4c. Validate sorting race winners (rewards follow them)
+	if err := validateShardWinners(block); err != nil {
+		return err
+	}
+
 	// 5. Validate Shards (Partial Validation based on Config)
 	// Identify shards we MUST validate
 	shardsToValidate := make(map[int]bool)
@@ -178,6 +277,9 @@ func ValidateBlock(block types.Block, prevHeader types.BlockHeader, shardCfg con
 				if err := ValidateTransaction(tx); err != nil {
 					return fmt.Errorf("invalid transaction in shard %d: %v", shardID, err)
 				}
+				if err := tx.CheckValidityWindow(block.Header.Height); err != nil {
+					return fmt.Errorf("invalid transaction in shard %d: %v", shardID, err)
+				}
 				txHashes = append(txHashes, tx.ID)
 			}
 
@@ -187,27 +289,122 @@ func ValidateBlock(block types.Block, prevHeader types.BlockHeader, shardCfg con
 					shardID, block.Header.ShardRoots[shardID], calculatedShardRoot)
 			}
 
+			// Samplers trust the data commitment, so it must match the erasure coding
+			if block.Header.Version >= types.HeaderVersionDataAvailability {
+				if err := availability.VerifyShard(&block.Header, shardID, shard.TxData); err != nil {
+					return err
+				}
+			}
+
 			// B. VERIFY SORTING ORDER (O(N) - Linear Scan)
 			if len(shard.TxData) > 1 {
 				shardSeed := sha256.Sum256(append(block.Header.VRFSeed[:], byte(shardID)))
 				prevKey := utils.MixHash(shard.TxData[0].ID, shardSeed)
 				for i := 1; i < len(shard.TxData); i++ {
 					currKey := utils.MixHash(shard.TxData[i].ID, shardSeed)
-					if currKey < prevKey {
+					if bytes.Compare(currKey[:], prevKey[:]) < 0 {
 						return fmt.Errorf("shard %d is NOT sorted! Cheating detected at index %d", shardID, i)
 					}
 					prevKey = currKey
 				}
 			}
 		} else {
-			// We are NOT responsible. Trust the Header's ShardRoot.
-			// (Verified by Committee/Others)
+			// We are NOT responsible. Trust the Header's ShardRoot:
+			// validators of the shard gossip a fraud proof if it is invalid
+			// (see HandleFraudProof).
 		}
 	}
 
 	return nil
 }
 
+// ValidateCommit checks that block carries a commit certificate from 2/3+ of valSet's
+// voting power, signed over its whole header and the parts it was proposed as (bft.BlockID)
+func ValidateCommit(block types.Block, valSet *bft.ValidatorSet) error {
+	commit := block.Commit
+	if commit == nil {
+		return fmt.Errorf("block #%d has no commit certificate", block.Header.Height)
+	}
+	if valSet == nil {
+		return fmt.Errorf("no validator set for height %d", block.Header.Height)
+	}
+	parts, err := bft.PartSetHeaderOf(&block)
+	if err != nil {
+		return err
+	}
+	if parts != bft.CommitParts(commit) {
+		return fmt.Errorf("commit certificate is for another body of block #%d", block.Header.Height)
+	}
+	return bft.VerifyBlockCommit(valSet, block.Header, commit)
+}
+
+// validateEvidence checks the evidence carried by block against its header
+func validateEvidence(block types.Block) error {
+	if len(block.Evidence) > params.MaxEvidencePerBlock {
+		return fmt.Errorf("too much evidence: %d items (max %d)", len(block.Evidence), params.MaxEvidencePerBlock)
+	}
+	if root := types.CalculateEvidenceRoot(block.Evidence); root != block.Header.EvidenceRoot {
+		return fmt.Errorf("evidence root mismatch: expected %x, got %x", block.Header.EvidenceRoot, root)
+	}
+
+	seen := make(map[[32]byte]bool, len(block.Evidence))
+	for i := range block.Evidence {
+		ev := &block.Evidence[i]
+		if err := ev.Verify(); err != nil {
+			return fmt.Errorf("invalid evidence %d: %v", i, err)
+		}
+		validator := ev.Validator()
+		if seen[validator] {
+			return fmt.Errorf("duplicate evidence against validator %x", validator[:4])
+		}
+		seen[validator] = true
+	}
+	return nil
+}
+
+// validateShardWinners checks that every recorded race winner signed its shard's output
+// The signed root is the header's shard root, which validators of the shard check
+// against the sorted data (and ShardNodes through fraud proofs). Whether a winner
+// was eligible needs state, see Blockchain.checkShardWinners. Blocks without
+// winners (from before the race) are accepted as they are.
+func validateShardWinners(block types.Block) error {
+	for slot, winner := range block.Header.WinningNodes {
+		if winner == ([32]byte{}) {
+			continue
+		}
+		if block.Shards[slot].NodeID != winner {
+			return fmt.Errorf("shard %d credited to %x, header winner is %x", slot, block.Shards[slot].NodeID[:4], winner[:4])
+		}
+		if block.Shards[slot].ShardRoot != block.Header.ShardRoots[slot] {
+			return fmt.Errorf("shard %d output %x differs from the header root", slot, block.Shards[slot].ShardRoot[:4])
+		}
+		if !consensus.BlockShardProof(&block, uint8(slot)).Verify() {
+			return fmt.Errorf("invalid shard proof from winner %x of slot %d", winner[:4], slot)
+		}
+	}
+	return nil
+}
+
+// validateLastCommit checks that block's LastCommit, if any, is for its parent
+func validateLastCommit(block types.Block, prevHeader types.BlockHeader) error {
+	commit := block.LastCommit
+	if commit == nil {
+		return nil
+	}
+	if commit.Height+1 != block.Header.Height || commit.BlockHash != bft.BlockID(prevHeader, bft.CommitParts(commit)) {
+		return fmt.Errorf("last commit is for block %x at height %d, not the parent", commit.BlockHash[:4], commit.Height)
+	}
+
+	seen := make(map[[32]byte]bool, len(commit.Signatures))
+	for _, sig := range commit.Signatures {
+		if seen[sig.ValidatorAddress] {
+			return fmt.Errorf("duplicate last commit signature from validator %x", sig.ValidatorAddress[:4])
+		}
+		seen[sig.ValidatorAddress] = true
+	}
+	return nil
+}
+
 // calculateBlockSize estimates block size in bytes
 func calculateBlockSize(block types.Block) uint64 {
 	// Rough estimate: header + shards
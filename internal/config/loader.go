@@ -9,8 +9,10 @@ import (
 
 // Config represents the structure of mainnet.yaml
 type Config struct {
-	Network  NetworkConfig `yaml:"network"`
-	Sharding ShardConfig   `yaml:"sharding"`
+	Network   NetworkConfig   `yaml:"network"`
+	Sharding  ShardConfig     `yaml:"sharding"`
+	Consensus ConsensusConfig `yaml:"consensus"`
+	Staking   StakingConfig   `yaml:"staking"`
 }
 
 type NetworkConfig struct {
@@ -24,6 +26,16 @@ type ShardConfig struct {
 	ShardIDs []int  `yaml:"shard_ids"` // List of shards to sync (0-9)
 }
 
+type ConsensusConfig struct {
+	Engine     string `yaml:"engine"`     // "pow" (PoSSR mining, default) or "bft"
+	Difficulty uint64 `yaml:"difficulty"` // PoW difficulty (0 = default)
+	Aggregate  bool   `yaml:"aggregate"`  // pow: build blocks from shards sorters supply instead of racing our own
+}
+
+type StakingConfig struct {
+	UnbondingPeriod uint64 `yaml:"unbonding_period"` // Blocks unbonded stake stays locked (0 = default)
+}
+
 // LoadConfig reads and parses a YAML configuration file
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -1,52 +1,174 @@
 package consensus
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/mempool"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
 )
 
 // ShardProof represents a proof submission from a shard winner
 type ShardProof struct {
+	Height    uint64
 	SlotID    uint8
-	NodeID    [32]byte
+	NodeID    [32]byte // Sorter's public key
+	Seed      [32]byte // VRF seed the shard was sorted with
 	Proof     [32]byte // Merkle root of sorted data
 	Timestamp int64
 	Signature [64]byte
 }
 
+// raceInfo is what proofs of a race must match
+type raceInfo struct {
+	seed     [32]byte
+	roots    *[10][32]byte      // Shard roots revealed by a miner (nil = sorters submit the shard data)
+	header   *types.BlockHeader // Sealed header an aggregation round fills
+	deadline time.Time          // Slots still missing afterwards get empty shards
+}
+
 // Aggregator collects and validates shard proofs
+// It serves two flows: a PoW miner's sorting race over shards it revealed
+// (StartRace), and aggregated blocks whose shards are built by the sorters
+// themselves (OpenRound, SubmitShard, CreateAggregatedBlock).
 type Aggregator struct {
-	proofs map[uint64]map[uint8]*ShardProof // blockHeight -> slotID -> proof
+	proofs map[uint64]map[uint8]*ShardProof         // blockHeight -> slotID -> proof
+	shards map[uint64]map[uint8][]types.Transaction // blockHeight -> slotID -> sorted shard data
+	races  map[uint64]raceInfo                      // blockHeight -> expected seed and shard roots
 	mu     sync.RWMutex
+
+	// Eligible reports whether nodeID may sort slot (nil = anyone may)
+	Eligible func(slot uint8, nodeID [32]byte) bool
+	// Deadline is how long an aggregation round waits for its shards
+	Deadline time.Duration
 }
 
 // NewAggregator creates a new consensus aggregator
 func NewAggregator() *Aggregator {
 	return &Aggregator{
-		proofs: make(map[uint64]map[uint8]*ShardProof),
+		proofs:   make(map[uint64]map[uint8]*ShardProof),
+		shards:   make(map[uint64]map[uint8][]types.Transaction),
+		races:    make(map[uint64]raceInfo),
+		Deadline: params.ShardDeadline * time.Second,
+	}
+}
+
+// StartRace only admits proofs for height that match seed and the shard's root
+func (a *Aggregator) StartRace(height uint64, seed [32]byte, roots [10][32]byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.races[height] = raceInfo{seed: seed, roots: &roots}
+	delete(a.proofs, height) // Proofs for another seed
+	delete(a.shards, height)
+}
+
+// OpenRound starts collecting the shards of the block sealed by header
+// Sorters sort under the header's VRF seed, which only exists once its PoW is
+// found, so they cannot start early; slots missing after Deadline are left empty.
+func (a *Aggregator) OpenRound(header types.BlockHeader) error {
+	if err := VerifyPoWSeal(&header); err != nil {
+		return fmt.Errorf("invalid round header: %w", err)
 	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	height := header.Height
+	a.races[height] = raceInfo{seed: header.VRFSeed, header: &header, deadline: time.Now().Add(a.Deadline)}
+	delete(a.proofs, height)
+	delete(a.shards, height)
+	return nil
 }
 
 // SubmitProof adds a shard proof to the aggregator
+// Aggregation rounds also need the shard data, see SubmitShard.
 func (a *Aggregator) SubmitProof(height uint64, proof *ShardProof) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if race, ok := a.races[height]; ok && race.roots == nil {
+		return fmt.Errorf("slot %d needs its shard data", proof.SlotID)
+	}
+	return a.submit(height, proof)
+}
+
+// SubmitShard adds a proof together with the sorted shard it commits to
+// The data must hold only transactions of the slot, sorted under the round's
+// seed, with the proof's Merkle root.
+func (a *Aggregator) SubmitShard(height uint64, proof *ShardProof, txs []types.Transaction) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if proof.SlotID >= 10 {
+		return fmt.Errorf("invalid slot ID: %d", proof.SlotID)
+	}
+
+	sharding := mempool.NewShardingManager()
+	shardSeed := ShardSeed(proof.Seed, proof.SlotID)
+	txHashes := make([][32]byte, len(txs))
+	var prevKey [32]byte
+	for i, tx := range txs {
+		if slot := sharding.AssignToSlot(tx); slot != proof.SlotID {
+			return fmt.Errorf("transaction %x belongs to slot %d", tx.ID[:4], slot)
+		}
+		key := utils.MixHash(tx.ID, shardSeed)
+		if keyLess(&key, &prevKey) {
+			return fmt.Errorf("shard %d not sorted under the race seed", proof.SlotID)
+		}
+		prevKey = key
+		txHashes[i] = tx.ID
+	}
+	if utils.CalculateMerkleRoot(txHashes) != proof.Proof {
+		return fmt.Errorf("shard data does not match proof root")
+	}
+
+	if err := a.submit(height, proof); err != nil {
+		return err
+	}
+	if a.shards[height] == nil {
+		a.shards[height] = make(map[uint8][]types.Transaction)
+	}
+	a.shards[height][proof.SlotID] = append([]types.Transaction(nil), txs...)
+	return nil
+}
+
+// submit checks proof and keeps it if it holds the lowest ticket of its slot
+func (a *Aggregator) submit(height uint64, proof *ShardProof) error {
 	// Validate slot ID
 	if proof.SlotID >= 10 {
 		return fmt.Errorf("invalid slot ID: %d", proof.SlotID)
 	}
 
+	if proof.Height != height {
+		return fmt.Errorf("proof for height %d, expected %d", proof.Height, height)
+	}
+
 	// Verify signature on proof
-	message := append(proof.Proof[:], byte(proof.SlotID))
-	if !utils.Verify(proof.NodeID[:], message, proof.Signature[:]) {
+	if !proof.Verify() {
 		return fmt.Errorf("invalid proof signature")
 	}
 
+	if a.Eligible != nil && !a.Eligible(proof.SlotID, proof.NodeID) {
+		return fmt.Errorf("node %x not eligible for slot %d", proof.NodeID[:4], proof.SlotID)
+	}
+
+	// Only correct sorts of the revealed shard compete
+	if race, ok := a.races[height]; ok {
+		if proof.Seed != race.seed {
+			return fmt.Errorf("proof for another seed")
+		}
+		if race.roots != nil && proof.Proof != race.roots[proof.SlotID] {
+			return fmt.Errorf("wrong shard root for slot %d", proof.SlotID)
+		}
+	}
+
 	// Initialize map for this height if needed
 	if a.proofs[height] == nil {
 		a.proofs[height] = make(map[uint8]*ShardProof)
@@ -54,8 +176,9 @@ func (a *Aggregator) SubmitProof(height uint64, proof *ShardProof) error {
 
 	// Check if slot already filled
 	if existing, ok := a.proofs[height][proof.SlotID]; ok {
-		// Keep earliest submission
-		if proof.Timestamp > existing.Timestamp {
+		// Keep the lowest ticket (verifiable, unlike arrival time)
+		existingTicket, ticket := existing.Ticket(), proof.Ticket()
+		if bytes.Compare(ticket[:], existingTicket[:]) >= 0 {
 			return fmt.Errorf("slot %d already filled", proof.SlotID)
 		}
 	}
@@ -92,42 +215,164 @@ func (a *Aggregator) IsComplete(height uint64) bool {
 	return len(proofs) == 10
 }
 
-// CreateAggregatedBlock combines 10 shard proofs into a block
-func (a *Aggregator) CreateAggregatedBlock(height uint64, prevHeader types.BlockHeader) (*types.Block, error) {
-	proofs := a.GetProofs(height)
-	if len(proofs) < 10 {
-		return nil, fmt.Errorf("incomplete proofs: %d/10", len(proofs))
+// Ready reports whether the aggregation round at height has every shard or is past its deadline
+func (a *Aggregator) Ready(height uint64) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	race, ok := a.races[height]
+	if !ok || race.roots != nil {
+		return false
 	}
+	return len(a.shards[height]) == 10 || !time.Now().Before(race.deadline)
+}
 
-	// Build aggregated block
-	block := &types.Block{
-		Header: types.BlockHeader{
-			Version:       1,
-			PrevBlockHash: types.HashBlockHeader(prevHeader),
-			Height:        height,
-			Timestamp:     time.Now().Unix(),
-			VRFSeed:       prevHeader.VRFSeed, // TODO: Generate new seed
-		},
+// CreateAggregatedBlock fills the round's sealed header with its winning shards
+// Before the deadline every slot must be filled; afterwards missing slots get
+// empty shards without a winner, so late sorters cannot stall the chain.
+func (a *Aggregator) CreateAggregatedBlock(height uint64) (*types.Block, error) {
+	a.mu.RLock()
+	race, ok := a.races[height]
+	proofs := make(map[uint8]*ShardProof)
+	shards := make(map[uint8][]types.Transaction)
+	for slot, txs := range a.shards[height] {
+		proofs[slot] = a.proofs[height][slot]
+		shards[slot] = txs
 	}
+	a.mu.RUnlock()
+
+	if !ok || race.header == nil {
+		return nil, fmt.Errorf("no aggregation round open for height %d", height)
+	}
+	if len(shards) < 10 && time.Now().Before(race.deadline) {
+		return nil, fmt.Errorf("incomplete shards: %d/10", len(shards))
+	}
+
+	// Build aggregated block (the seal covers none of the shard fields)
+	block := &types.Block{Header: *race.header}
 
 	// Collect all shard roots
 	var shardRoots [][32]byte
 	for i := uint8(0); i < 10; i++ {
-		if proof, ok := proofs[i]; ok {
-			block.Header.WinningNodes[i] = proof.NodeID
-			shardRoots = append(shardRoots, proof.Proof)
+		proof, ok := proofs[i]
+		if !ok {
+			// Missed the deadline: empty shard, nobody rewarded
+			fmt.Printf("[AGGREGATOR] Slot %d missed its deadline, using an empty shard\n", i)
+			root := utils.CalculateMerkleRoot(nil)
+			block.Header.ShardRoots[i] = root
+			block.Shards[i] = types.ShardData{ShardRoot: root}
+			shardRoots = append(shardRoots, root)
+			continue
+		}
 
-			// Add shard data (simplified - in reality we need actual tx data)
-			block.Shards[i] = types.ShardData{
-				NodeID:    proof.NodeID,
-				ShardRoot: proof.Proof,
-			}
+		block.Header.WinningNodes[i] = proof.NodeID
+		block.Header.ShardRoots[i] = proof.Proof
+		block.Shards[i] = types.ShardData{
+			NodeID:    proof.NodeID,
+			TxData:    shards[i],
+			ShardRoot: proof.Proof,
+			Signature: proof.Signature,
 		}
+		shardRoots = append(shardRoots, proof.Proof)
 	}
 
 	// Calculate aggregated Merkle root
 	block.Header.MerkleRoot = utils.CalculateMerkleRoot(shardRoots)
 
+	// Commit to the erasure-coded shard bodies (availability sampling)
+	if block.Header.Version >= types.HeaderVersionDataAvailability {
+		if err := availability.Commit(block); err != nil {
+			return nil, fmt.Errorf("data commitment failed: %w", err)
+		}
+	}
+	return block, nil
+}
+
+// SolveRound sorts our mempool's transactions of slot for the round sealed by header
+// and returns the shard with our signed proof, ready for SubmitShard
+func SolveRound(header *types.BlockHeader, slot uint8, txs []types.Transaction, privKey ed25519.PrivateKey) (*ShardSubmission, error) {
+	if slot >= 10 {
+		return nil, fmt.Errorf("invalid slot ID: %d", slot)
+	}
+	if err := VerifyPoWSeal(header); err != nil {
+		return nil, fmt.Errorf("invalid round header: %w", err)
+	}
+
+	sharding := mempool.NewShardingManager()
+	var slotTxs []types.Transaction
+	for _, tx := range txs {
+		if sharding.AssignToSlot(tx) == slot {
+			slotTxs = append(slotTxs, tx)
+		}
+	}
+	sorted, root := SortShard(slotTxs, header.VRFSeed, slot, header.Height)
+	proof := &ShardProof{
+		Height:    header.Height,
+		SlotID:    slot,
+		Seed:      header.VRFSeed,
+		Proof:     root,
+		Timestamp: time.Now().Unix(),
+	}
+	proof.Sign(privKey)
+	return &ShardSubmission{Proof: proof, Txs: sorted}, nil
+}
+
+// Aggregation builds the blocks this node mines from shards sorted by the network
+// The miner seals a header, announces it and waits until every slot has a
+// shard or the aggregator's deadline passes. Slots nobody filled get the
+// miner's own sort of its transactions, so the block still carries them.
+type Aggregation struct {
+	Aggregator *Aggregator
+	Publish    func(*RaceMessage) error // Announces rounds (e.g. on the proofs topic)
+	Poll       time.Duration            // How often Ready is checked (0 = 100ms)
+}
+
+// Run collects the shards of the round sealed by header and returns the block
+func (g *Aggregation) Run(header types.BlockHeader, txs []types.Transaction, privKey ed25519.PrivateKey, stopChan chan struct{}) (*types.Block, error) {
+	height := header.Height
+	if err := g.Aggregator.OpenRound(header); err != nil {
+		return nil, err
+	}
+	if g.Publish != nil {
+		if err := g.Publish(&RaceMessage{Round: &header}); err != nil {
+			fmt.Printf("[AGGREGATOR] Warning: Failed to announce height %d: %v\n", height, err)
+		}
+	}
+
+	poll := g.Poll
+	if poll == 0 {
+		poll = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for !g.Aggregator.Ready(height) {
+		select {
+		case <-ticker.C:
+		case <-stopChan:
+			return nil, fmt.Errorf("mining interrupted")
+		}
+	}
+
+	// Fill the slots nobody sorted with our own shards
+	proofs := g.Aggregator.GetProofs(height)
+	for slot := uint8(0); slot < 10; slot++ {
+		if _, ok := proofs[slot]; ok {
+			continue
+		}
+		shard, err := SolveRound(&header, slot, txs, privKey)
+		if err == nil {
+			err = g.Aggregator.SubmitShard(height, shard.Proof, shard.Txs)
+		}
+		if err != nil {
+			fmt.Printf("[AGGREGATOR] Own shard for slot %d rejected: %v\n", slot, err)
+		}
+	}
+
+	block, err := g.Aggregator.CreateAggregatedBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[AGGREGATOR] Height %d aggregated, %d/10 shards from sorters\n", height, len(proofs))
 	return block, nil
 }
 
@@ -138,8 +383,18 @@ func (a *Aggregator) Cleanup(currentHeight uint64) {
 
 	// Keep only last 10 blocks worth of proofs
 	for height := range a.proofs {
-		if height < currentHeight-10 {
+		if height+10 < currentHeight {
 			delete(a.proofs, height)
 		}
 	}
+	for height := range a.shards {
+		if height+10 < currentHeight {
+			delete(a.shards, height)
+		}
+	}
+	for height := range a.races {
+		if height+10 < currentHeight {
+			delete(a.races, height)
+		}
+	}
 }
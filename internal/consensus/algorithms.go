@@ -0,0 +1,134 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SortAlgorithm is a sorting race algorithm and the height it joins the race
+type SortAlgorithm struct {
+	Name             string
+	Sort             func([]SortableTransaction) []SortableTransaction // Sorts in place and returns its input
+	ActivationHeight uint64
+}
+
+// AlgorithmRegistry holds the race algorithms in registration order
+// Every distinct activation height starts a new consensus version whose set is
+// all algorithms active by then. Algorithms can only be appended at or after the
+// latest fork, so the seed -> algorithm mapping of earlier versions never changes
+// and historical blocks select the same algorithm they were mined with.
+type AlgorithmRegistry struct {
+	algorithms []SortAlgorithm
+	mu         sync.RWMutex
+}
+
+// Algorithms is the registry used by the sorting race
+var Algorithms = defaultAlgorithms()
+
+// defaultAlgorithms returns the genesis (version 1) algorithm set
+func defaultAlgorithms() *AlgorithmRegistry {
+	r := NewAlgorithmRegistry()
+	for _, alg := range []SortAlgorithm{
+		{Name: "QUICK_SORT", Sort: QuickSort},
+		{Name: "MERGE_SORT", Sort: MergeSort},
+		{Name: "HEAP_SORT", Sort: HeapSort},
+		{Name: "RADIX_SORT", Sort: RadixSort},
+		{Name: "TIM_SORT", Sort: TimSort},
+		{Name: "INTRO_SORT", Sort: IntroSort},
+		{Name: "SHELL_SORT", Sort: ShellSort},
+	} {
+		if err := r.Register(alg); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// NewAlgorithmRegistry creates an empty registry
+func NewAlgorithmRegistry() *AlgorithmRegistry {
+	return &AlgorithmRegistry{}
+}
+
+// Register appends alg to the race from its activation height on
+func (r *AlgorithmRegistry) Register(alg SortAlgorithm) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if alg.Name == "" || alg.Sort == nil {
+		return fmt.Errorf("algorithm needs a name and an implementation")
+	}
+	for _, existing := range r.algorithms {
+		if existing.Name == alg.Name {
+			return fmt.Errorf("algorithm %s already registered", alg.Name)
+		}
+	}
+	if n := len(r.algorithms); n > 0 && alg.ActivationHeight < r.algorithms[n-1].ActivationHeight {
+		return fmt.Errorf("activation height %d before the latest fork at %d", alg.ActivationHeight, r.algorithms[n-1].ActivationHeight)
+	}
+
+	r.algorithms = append(r.algorithms, alg)
+	return nil
+}
+
+// Version returns the consensus version at height (1 = genesis set, 0 = no algorithms)
+func (r *AlgorithmRegistry) Version(height uint64) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	version := 0
+	for i, alg := range r.algorithms {
+		if alg.ActivationHeight > height {
+			break
+		}
+		if i == 0 || alg.ActivationHeight != r.algorithms[i-1].ActivationHeight {
+			version++
+		}
+	}
+	return version
+}
+
+// Active returns the algorithms racing at height, in selection order
+func (r *AlgorithmRegistry) Active(height uint64) []SortAlgorithm {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var active []SortAlgorithm
+	for _, alg := range r.algorithms {
+		if alg.ActivationHeight > height {
+			break
+		}
+		active = append(active, alg)
+	}
+	return active
+}
+
+// Select picks the algorithm of the block at height from its VRF seed
+func (r *AlgorithmRegistry) Select(seed [32]byte, height uint64) (SortAlgorithm, error) {
+	active := r.Active(height)
+	if len(active) == 0 {
+		return SortAlgorithm{}, fmt.Errorf("no sorting algorithm active at height %d", height)
+	}
+	return active[int(seed[31])%len(active)], nil
+}
+
+// Lookup returns the registered algorithm called name
+func (r *AlgorithmRegistry) Lookup(name string) (SortAlgorithm, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, alg := range r.algorithms {
+		if alg.Name == name {
+			return alg, true
+		}
+	}
+	return SortAlgorithm{}, false
+}
+
+// SelectAlgorithm uses the VRF seed to name the sorting algorithm of the block at height
+func SelectAlgorithm(seed [32]byte, height uint64) string {
+	alg, err := Algorithms.Select(seed, height)
+	if err != nil {
+		return "QUICK_SORT"
+	}
+	return alg.Name
+}
@@ -0,0 +1,142 @@
+package bft
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+const (
+	// BlockPartSize is the maximum payload of one gossiped block part
+	BlockPartSize = 64 * 1024
+
+	// MaxBlockParts bounds the parts a proposal may announce
+	MaxBlockParts = 1024
+)
+
+// PartSetHeader commits to a block split into parts (signed as part of the proposal)
+type PartSetHeader struct {
+	Total uint32   // Number of parts
+	Root  [32]byte // Merkle root of the part hashes
+}
+
+// BlockPart is one verifiable chunk of a proposed block
+type BlockPart struct {
+	Height uint64
+	Round  int32
+	Index  uint32
+	Bytes  []byte
+	Proof  [][32]byte // Merkle proof of sha256(Bytes) against PartSetHeader.Root
+}
+
+// PartSet collects the parts of one proposal block
+type PartSet struct {
+	header PartSetHeader
+	parts  [][]byte
+	count  uint32
+}
+
+// NewPartSetFromBlock splits block into parts for height/round
+func NewPartSetFromBlock(block *types.Block, height uint64, round int32) (PartSetHeader, []*BlockPart, error) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return PartSetHeader{}, nil, fmt.Errorf("failed to encode block: %w", err)
+	}
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := BlockPartSize
+		if len(data) < n {
+			n = len(data)
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	if len(chunks) > MaxBlockParts {
+		return PartSetHeader{}, nil, fmt.Errorf("block needs %d parts (max %d)", len(chunks), MaxBlockParts)
+	}
+
+	hashes := make([][32]byte, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = sha256.Sum256(chunk)
+	}
+
+	header := PartSetHeader{Total: uint32(len(chunks)), Root: utils.CalculateMerkleRoot(hashes)}
+	parts := make([]*BlockPart, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = &BlockPart{
+			Height: height,
+			Round:  round,
+			Index:  uint32(i),
+			Bytes:  chunk,
+			Proof:  utils.CalculateMerkleProof(hashes, i),
+		}
+	}
+
+	return header, parts, nil
+}
+
+// PartSetHeaderOf returns the part set header block was proposed under
+// The commit certificate is added after voting and is left out.
+func PartSetHeaderOf(block *types.Block) (PartSetHeader, error) {
+	proposed := *block
+	proposed.Commit = nil
+	header, _, err := NewPartSetFromBlock(&proposed, block.Header.Height, 0)
+	return header, err
+}
+
+// NewPartSet creates an empty part set expecting the parts committed to by header
+func NewPartSet(header PartSetHeader) (*PartSet, error) {
+	if header.Total == 0 || header.Total > MaxBlockParts {
+		return nil, fmt.Errorf("invalid part count %d", header.Total)
+	}
+	return &PartSet{header: header, parts: make([][]byte, header.Total)}, nil
+}
+
+// AddPart verifies part against the header and stores it
+// Returns false (without error) for duplicates.
+func (ps *PartSet) AddPart(part *BlockPart) (bool, error) {
+	if part.Index >= ps.header.Total {
+		return false, fmt.Errorf("part index %d out of range (total %d)", part.Index, ps.header.Total)
+	}
+	if len(part.Bytes) == 0 || len(part.Bytes) > BlockPartSize {
+		return false, fmt.Errorf("invalid part size %d", len(part.Bytes))
+	}
+	if ps.parts[part.Index] != nil {
+		return false, nil
+	}
+
+	if !utils.VerifyMerkleProof(sha256.Sum256(part.Bytes), int(part.Index), part.Proof, ps.header.Root) {
+		return false, fmt.Errorf("invalid merkle proof for part %d", part.Index)
+	}
+
+	ps.parts[part.Index] = part.Bytes
+	ps.count++
+	return true, nil
+}
+
+// IsComplete returns true once every part has been received
+func (ps *PartSet) IsComplete() bool {
+	return ps.count == ps.header.Total
+}
+
+// Block reassembles and decodes the block (only valid once complete)
+func (ps *PartSet) Block() (*types.Block, error) {
+	if !ps.IsComplete() {
+		return nil, fmt.Errorf("part set incomplete: %d/%d parts", ps.count, ps.header.Total)
+	}
+
+	var data []byte
+	for _, part := range ps.parts {
+		data = append(data, part...)
+	}
+
+	var block types.Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("failed to decode block: %w", err)
+	}
+	return &block, nil
+}
@@ -0,0 +1,48 @@
+package bft
+
+import (
+	"testing"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+func TestBlockPartsRoundTrip(t *testing.T) {
+	block := &types.Block{Header: types.BlockHeader{Height: 3, Hash: [32]byte{3}}}
+	for i := 0; i < 2000; i++ {
+		block.Shards[i%10].TxData = append(block.Shards[i%10].TxData, types.Transaction{Nonce: uint64(i), Payload: make([]byte, 64)})
+	}
+
+	header, parts, err := NewPartSetFromBlock(block, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Total < 3 {
+		t.Fatalf("expected a multi-part block, got %d parts", header.Total)
+	}
+
+	ps, err := NewPartSet(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tampered part must not verify against the signed root
+	forged := *parts[1]
+	forged.Bytes = append([]byte{'x'}, forged.Bytes[1:]...)
+	if _, err := ps.AddPart(&forged); err == nil {
+		t.Fatal("tampered part accepted")
+	}
+
+	for i := len(parts) - 1; i >= 0; i-- { // Order must not matter
+		if _, err := ps.AddPart(parts[i]); err != nil {
+			t.Fatalf("part %d rejected: %v", i, err)
+		}
+	}
+
+	got, err := ps.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Hash != block.Header.Hash || len(got.Shards[9].TxData) != 200 {
+		t.Error("reassembled block differs from the original")
+	}
+}
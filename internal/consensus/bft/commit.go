@@ -0,0 +1,114 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// BlockID returns the hash validators vote on for a proposal block
+// It binds the whole header (types.HashBlockHeaderForCommit) to the part set the
+// block was gossiped as, so one ID can never stand for two different bodies.
+func BlockID(header types.BlockHeader, parts PartSetHeader) [32]byte {
+	headerHash := types.HashBlockHeaderForCommit(header)
+
+	var buf bytes.Buffer
+	buf.Write(headerHash[:])
+	binary.Write(&buf, binary.BigEndian, parts.Total)
+	buf.Write(parts.Root[:])
+	return sha256.Sum256(buf.Bytes())
+}
+
+// CommitParts returns the part set header commit was made for
+func CommitParts(commit *types.Commit) PartSetHeader {
+	return PartSetHeader{Total: commit.PartsTotal, Root: commit.PartsRoot}
+}
+
+// MakeCommit builds a commit certificate from the precommits for blockHash
+func (voteSet *VoteSet) MakeCommit(blockHash [32]byte) (*types.Commit, error) {
+	if voteSet.VoteType != VoteTypePrecommit {
+		return nil, fmt.Errorf("commit requires precommits, got %s votes", voteSet.VoteType)
+	}
+	if !voteSet.valSet.HasTwoThirdsMajority(voteSet.votesByBlock[blockHash]) {
+		return nil, fmt.Errorf("no 2/3+ precommits for block %x", blockHash[:4])
+	}
+
+	commit := &types.Commit{
+		Height:    voteSet.Height,
+		Round:     voteSet.Round,
+		BlockHash: blockHash,
+	}
+	for _, vote := range voteSet.votes {
+		if vote.BlockHash != blockHash {
+			continue
+		}
+		commit.Signatures = append(commit.Signatures, types.CommitSig{
+			ValidatorAddress: vote.ValidatorAddress,
+			Timestamp:        vote.Timestamp,
+			Signature:        vote.Signature,
+		})
+	}
+
+	sort.Slice(commit.Signatures, func(i, j int) bool {
+		return bytes.Compare(commit.Signatures[i].ValidatorAddress[:], commit.Signatures[j].ValidatorAddress[:]) < 0
+	})
+	return commit, nil
+}
+
+// VerifyBlockCommit checks that commit is a valid commit certificate of valSet for header
+func VerifyBlockCommit(valSet *ValidatorSet, header types.BlockHeader, commit *types.Commit) error {
+	if commit == nil {
+		return fmt.Errorf("missing commit certificate")
+	}
+	if id := BlockID(header, CommitParts(commit)); commit.Height != header.Height || commit.BlockHash != id {
+		return fmt.Errorf("commit certificate is for block %x at height %d, not %x at %d",
+			commit.BlockHash[:4], commit.Height, id[:4], header.Height)
+	}
+	return VerifyCommit(valSet, commit)
+}
+
+// VerifyCommit checks that commit carries valid precommits from 2/3+ of valSet's voting power
+func VerifyCommit(valSet *ValidatorSet, commit *types.Commit) error {
+	if commit == nil {
+		return fmt.Errorf("missing commit certificate")
+	}
+	if commit.BlockHash == [32]byte{} {
+		return fmt.Errorf("commit for nil block")
+	}
+
+	seen := make(map[[32]byte]bool, len(commit.Signatures))
+	var power uint64
+	for _, sig := range commit.Signatures {
+		if seen[sig.ValidatorAddress] {
+			return fmt.Errorf("duplicate commit signature from validator %x", sig.ValidatorAddress[:4])
+		}
+		seen[sig.ValidatorAddress] = true
+
+		val := valSet.GetByAddress(sig.ValidatorAddress)
+		if val == nil {
+			return fmt.Errorf("commit signed by unknown validator %x", sig.ValidatorAddress[:4])
+		}
+
+		vote := &Vote{
+			Type:      VoteTypePrecommit,
+			Height:    commit.Height,
+			Round:     commit.Round,
+			BlockHash: commit.BlockHash,
+			Timestamp: sig.Timestamp,
+			Signature: sig.Signature,
+		}
+		if !vote.Verify(val.PubKey) {
+			return fmt.Errorf("invalid commit signature from validator %x", sig.ValidatorAddress[:4])
+		}
+		power += val.VotingPower
+	}
+
+	if !valSet.HasTwoThirdsMajority(power) {
+		return fmt.Errorf("insufficient commit voting power: %d of %d", power, valSet.TotalVotingPower())
+	}
+	return nil
+}
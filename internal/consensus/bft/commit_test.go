@@ -0,0 +1,78 @@
+package bft
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+func newTestValidators(n int) (*ValidatorSet, []ed25519.PrivateKey) {
+	vals := make([]*Validator, n)
+	keys := make(map[[32]byte]ed25519.PrivateKey, n)
+	for i := range vals {
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		var addr [32]byte
+		copy(addr[:], pub)
+		vals[i] = &Validator{Address: addr, PubKey: pub, VotingPower: 1}
+		keys[addr] = priv
+	}
+
+	valSet := NewValidatorSet(vals)
+	privs := make([]ed25519.PrivateKey, n)
+	for i, val := range valSet.Validators { // Sorted by address
+		privs[i] = keys[val.Address]
+	}
+	return valSet, privs
+}
+
+func TestCommitCertificate(t *testing.T) {
+	valSet, privs := newTestValidators(4)
+	blockHash := [32]byte{0xb}
+
+	precommits := NewVoteSet(7, 1, VoteTypePrecommit, valSet)
+	for i := 0; i < 3; i++ {
+		vote := &Vote{
+			Type:             VoteTypePrecommit,
+			Height:           7,
+			Round:            1,
+			BlockHash:        blockHash,
+			Timestamp:        int64(1000 + i),
+			ValidatorAddress: valSet.Validators[i].Address,
+			ValidatorIndex:   int32(i),
+		}
+		vote.Sign(privs[i])
+		if _, err := precommits.AddVote(vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commit, err := precommits.MakeCommit(blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCommit(valSet, commit); err != nil {
+		t.Fatalf("valid commit rejected: %v", err)
+	}
+
+	// 2 of 4 is not a quorum
+	short := *commit
+	short.Signatures = commit.Signatures[:2]
+	if err := VerifyCommit(valSet, &short); err == nil {
+		t.Error("commit without 2/3+ voting power accepted")
+	}
+
+	// Signatures must cover the committed block
+	forged := *commit
+	forged.BlockHash = [32]byte{0xf}
+	if err := VerifyCommit(valSet, &forged); err == nil {
+		t.Error("commit for a different block accepted")
+	}
+
+	// Duplicated signers cannot inflate voting power
+	dup := *commit
+	dup.Signatures = append([]types.CommitSig{}, commit.Signatures[0], commit.Signatures[0], commit.Signatures[1])
+	if err := VerifyCommit(valSet, &dup); err == nil {
+		t.Error("duplicate commit signatures accepted")
+	}
+}
@@ -0,0 +1,63 @@
+package bft
+
+import "testing"
+
+func TestRoundProgression(t *testing.T) {
+	valSet, privs := newTestValidators(4)
+
+	// Every round gets a different proposer, without touching the set
+	proposer := valSet.GetProposer().Address
+	seen := make(map[[32]byte]bool)
+	for round := int32(0); round < 4; round++ {
+		seen[valSet.ProposerForRound(round).Address] = true
+	}
+	if len(seen) != 4 {
+		t.Fatalf("expected 4 distinct proposers over 4 rounds, got %d", len(seen))
+	}
+	if valSet.GetProposer().Address != proposer {
+		t.Fatal("ProposerForRound modified the validator set")
+	}
+
+	cs := NewConsensusState(5, valSet)
+	blockHash := [32]byte{0xa}
+	prevote := func(i int, round int32) error {
+		vote := &Vote{
+			Type:             VoteTypePrevote,
+			Height:           5,
+			Round:            round,
+			BlockHash:        blockHash,
+			ValidatorAddress: valSet.Validators[i].Address,
+			ValidatorIndex:   int32(i),
+		}
+		vote.Sign(privs[i])
+		return cs.AddVote(vote)
+	}
+
+	cs.EnterNewRound(5, 0)
+	for i := 0; i < 3; i++ {
+		if err := prevote(i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !cs.HasPOL(0, blockHash) {
+		t.Fatal("expected proof-of-lock in round 0")
+	}
+
+	// The round 0 polka survives into round 1, whose vote set starts empty
+	cs.EnterNewRound(5, 1)
+	if ok, _ := cs.HasTwoThirdsPrevotes(); ok {
+		t.Fatal("round 1 should start without prevotes")
+	}
+	if !cs.HasPOL(0, blockHash) {
+		t.Fatal("round 0 proof-of-lock lost on round change")
+	}
+
+	// Votes more than one round ahead are dropped
+	if err := prevote(0, 3); err == nil {
+		t.Fatal("accepted vote two rounds ahead")
+	}
+
+	if cs.ProposeTimeoutFor(2) <= cs.ProposeTimeoutFor(1) || cs.PrevoteTimeoutFor(1) <= cs.PrevoteTimeoutFor(0) {
+		t.Fatal("timeouts should grow with the round")
+	}
+}
@@ -0,0 +1,182 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// LastSignState is the highest (height, round, step) a validator key has signed
+type LastSignState struct {
+	Height    uint64
+	Round     int32
+	Step      RoundStep // RoundStepPropose, RoundStepPrevote or RoundStepPrecommit
+	Timestamp int64
+	SignBytes []byte
+	Signature [64]byte
+}
+
+// SignGuard signs votes and proposals, refusing anything that conflicts with what the key already signed
+// The last signed state is persisted before a signature is released, so the guard
+// survives restarts and is shared by instances using the same state file.
+type SignGuard struct {
+	mu      sync.Mutex
+	path    string
+	privKey ed25519.PrivateKey
+	last    LastSignState
+}
+
+// LoadSignGuard loads (or initializes) the last signed state stored at path
+func LoadSignGuard(path string, privKey ed25519.PrivateKey) (*SignGuard, error) {
+	g := &SignGuard{path: path, privKey: privKey}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// LastSigned returns the last signed state
+func (g *SignGuard) LastSigned() LastSignState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.last
+}
+
+// SignVote signs vote if it does not conflict with an earlier signature
+func (g *SignGuard) SignVote(vote *Vote) error {
+	step := RoundStepPrevote
+	if vote.Type == VoteTypePrecommit {
+		step = RoundStepPrecommit
+	}
+
+	sig, timestamp, err := g.sign(vote.Height, vote.Round, step, vote.Timestamp, func(ts int64) []byte {
+		v := *vote
+		v.Timestamp = ts
+		return v.SignBytes()
+	})
+	if err != nil {
+		return fmt.Errorf("refusing to sign %s at %d/%d: %w", vote.Type, vote.Height, vote.Round, err)
+	}
+
+	vote.Timestamp = timestamp
+	vote.Signature = sig
+	return nil
+}
+
+// SignProposal signs proposal if it does not conflict with an earlier signature
+func (g *SignGuard) SignProposal(proposal *Proposal) error {
+	sig, timestamp, err := g.sign(proposal.Height, proposal.Round, RoundStepPropose, proposal.Timestamp, func(ts int64) []byte {
+		p := *proposal
+		p.Timestamp = ts
+		return p.SignBytes()
+	})
+	if err != nil {
+		return fmt.Errorf("refusing to sign proposal at %d/%d: %w", proposal.Height, proposal.Round, err)
+	}
+
+	proposal.Timestamp = timestamp
+	proposal.Signature = sig
+	return nil
+}
+
+// sign checks (height, round, step) against the last signed state, then signs and persists
+// Re-signing the same message (only the timestamp may differ) returns the earlier signature.
+func (g *SignGuard) sign(height uint64, round int32, step RoundStep, timestamp int64, signBytes func(int64) []byte) ([64]byte, int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Another instance may have signed since we last looked
+	if err := g.load(); err != nil {
+		return [64]byte{}, 0, err
+	}
+
+	switch cmp := compareHRS(height, round, step, g.last); {
+	case cmp < 0:
+		return [64]byte{}, 0, fmt.Errorf("already signed at %d/%d step %d", g.last.Height, g.last.Round, g.last.Step)
+	case cmp == 0:
+		if bytes.Equal(signBytes(g.last.Timestamp), g.last.SignBytes) {
+			return g.last.Signature, g.last.Timestamp, nil
+		}
+		return [64]byte{}, 0, fmt.Errorf("conflicting data already signed at this height/round/step")
+	}
+
+	data := signBytes(timestamp)
+	var sig [64]byte
+	copy(sig[:], ed25519.Sign(g.privKey, data))
+
+	next := LastSignState{Height: height, Round: round, Step: step, Timestamp: timestamp, SignBytes: data, Signature: sig}
+	if err := g.save(next); err != nil {
+		return [64]byte{}, 0, err
+	}
+	g.last = next
+	return sig, timestamp, nil
+}
+
+// compareHRS orders (height, round, step) against last
+func compareHRS(height uint64, round int32, step RoundStep, last LastSignState) int {
+	switch {
+	case height != last.Height:
+		if height < last.Height {
+			return -1
+		}
+		return 1
+	case round != last.Round:
+		if round < last.Round {
+			return -1
+		}
+		return 1
+	case step != last.Step:
+		if step < last.Step {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func (g *SignGuard) load() error {
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read sign state: %w", err)
+	}
+	var last LastSignState
+	if err := json.Unmarshal(data, &last); err != nil {
+		return fmt.Errorf("corrupt sign state %s: %w", g.path, err)
+	}
+	g.last = last
+	return nil
+}
+
+// save writes state atomically and durably (temp file, fsync, rename)
+func (g *SignGuard) save(state LastSignState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := g.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write sign state: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write sign state: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync sign state: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmpPath, g.path); err != nil {
+		return fmt.Errorf("failed to replace sign state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package bft
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSignGuardRefusesConflicts(t *testing.T) {
+	valSet, privs := newTestValidators(1)
+	path := filepath.Join(t.TempDir(), "priv_validator_state.json")
+
+	guard, err := LoadSignGuard(path, privs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vote := func(voteType VoteType, round int32, hash byte, ts int64) *Vote {
+		return &Vote{Type: voteType, Height: 9, Round: round, BlockHash: [32]byte{hash}, Timestamp: ts,
+			ValidatorAddress: valSet.Validators[0].Address}
+	}
+
+	first := vote(VoteTypePrevote, 1, 0xa, 100)
+	if err := guard.SignVote(first); err != nil {
+		t.Fatal(err)
+	}
+	if !first.Verify(valSet.Validators[0].PubKey) {
+		t.Fatal("guarded signature does not verify")
+	}
+
+	// Re-signing the same vote later returns the original signature and timestamp
+	again := vote(VoteTypePrevote, 1, 0xa, 200)
+	if err := guard.SignVote(again); err != nil || again.Signature != first.Signature || again.Timestamp != 100 {
+		t.Fatalf("idempotent re-sign failed: %v", err)
+	}
+
+	// A second instance (or a restart) sees the persisted state
+	restarted, err := LoadSignGuard(path, privs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.SignVote(vote(VoteTypePrevote, 1, 0xb, 100)); err == nil {
+		t.Fatal("conflicting prevote signed after restart")
+	}
+	if err := restarted.SignVote(vote(VoteTypePrevote, 0, 0xa, 100)); err == nil {
+		t.Fatal("signed an earlier round")
+	}
+
+	// Moving forward is fine
+	if err := restarted.SignVote(vote(VoteTypePrecommit, 1, 0xa, 101)); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.SignProposal(&Proposal{Height: 9, Round: 2, Timestamp: 102}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,14 @@
 package bft
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // RoundStep represents the current step in consensus
@@ -36,11 +41,42 @@ func (rs RoundStep) String() string {
 
 // Proposal represents a block proposal
 type Proposal struct {
-	Height    uint64
-	Round     int32
-	BlockHash [32]byte
-	Timestamp int64
-	Proposer  [32]byte
+	Height     uint64
+	Round      int32
+	POLRound   int32 // Round of the 2/3+ prevotes justifying a re-proposed block (-1 = none)
+	BlockHash  [32]byte
+	BlockParts PartSetHeader // How the block is split for gossip
+	Timestamp  int64
+	Proposer   [32]byte
+	Signature  [64]byte // Proposer's Ed25519 signature over SignBytes
+}
+
+// SignBytes returns the canonical byte representation for signing
+func (p *Proposal) SignBytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("proposal")
+	binary.Write(&buf, binary.BigEndian, p.Height)
+	binary.Write(&buf, binary.BigEndian, p.Round)
+	binary.Write(&buf, binary.BigEndian, p.POLRound)
+	buf.Write(p.BlockHash[:])
+	binary.Write(&buf, binary.BigEndian, p.BlockParts.Total)
+	buf.Write(p.BlockParts.Root[:])
+	binary.Write(&buf, binary.BigEndian, p.Timestamp)
+	buf.Write(p.Proposer[:])
+
+	return buf.Bytes()
+}
+
+// Sign signs the proposal with the proposer's private key
+func (p *Proposal) Sign(privKey ed25519.PrivateKey) {
+	sig := ed25519.Sign(privKey, p.SignBytes())
+	copy(p.Signature[:], sig)
+}
+
+// Verify verifies the proposal signature
+func (p *Proposal) Verify(pubKey ed25519.PublicKey) bool {
+	return ed25519.Verify(pubKey, p.SignBytes(), p.Signature[:])
 }
 
 // ConsensusState manages the state machine for BFT consensus
@@ -63,15 +99,20 @@ type ConsensusState struct {
 	ValidRound    int32       // Latest round with valid proposal
 	ValidBlock    [32]byte    // Latest valid block
 
-	// Votes
-	Prevotes   *VoteSet
-	Precommits *VoteSet
-
-	// Timeouts
-	ProposeTimeout   time.Duration
-	PrevoteTimeout   time.Duration
-	PrecommitTimeout time.Duration
-	CommitTimeout    time.Duration
+	// Votes (current round; earlier and next rounds are kept by round)
+	Prevotes          *VoteSet
+	Precommits        *VoteSet
+	prevotesByRound   map[int32]*VoteSet
+	precommitsByRound map[int32]*VoteSet
+
+	// Timeouts (round r waits Timeout + r*TimeoutDelta)
+	ProposeTimeout        time.Duration
+	PrevoteTimeout        time.Duration
+	PrecommitTimeout      time.Duration
+	CommitTimeout         time.Duration
+	ProposeTimeoutDelta   time.Duration
+	PrevoteTimeoutDelta   time.Duration
+	PrecommitTimeoutDelta time.Duration
 }
 
 // NewConsensusState creates a new consensus state machine
@@ -85,18 +126,109 @@ func NewConsensusState(height uint64, validators *ValidatorSet) *ConsensusState
 		ValidRound:  -1,
 
 		// Timeouts (Tendermint default values)
-		ProposeTimeout:   3 * time.Second,
-		PrevoteTimeout:   1 * time.Second,
-		PrecommitTimeout: 1 * time.Second,
-		CommitTimeout:    1 * time.Second,
+		ProposeTimeout:        3 * time.Second,
+		PrevoteTimeout:        1 * time.Second,
+		PrecommitTimeout:      1 * time.Second,
+		CommitTimeout:         1 * time.Second,
+		ProposeTimeoutDelta:   500 * time.Millisecond,
+		PrevoteTimeoutDelta:   500 * time.Millisecond,
+		PrecommitTimeoutDelta: 500 * time.Millisecond,
 	}
 
-	cs.Prevotes = NewVoteSet(height, 0, VoteTypePrevote, validators)
-	cs.Precommits = NewVoteSet(height, 0, VoteTypePrecommit, validators)
+	cs.resetVoteSets()
 
 	return cs
 }
 
+// resetVoteSets drops all votes and starts round 0 of cs.Height (caller holds the lock)
+func (cs *ConsensusState) resetVoteSets() {
+	cs.prevotesByRound = make(map[int32]*VoteSet)
+	cs.precommitsByRound = make(map[int32]*VoteSet)
+	cs.Prevotes = cs.voteSet(VoteTypePrevote, 0)
+	cs.Precommits = cs.voteSet(VoteTypePrecommit, 0)
+}
+
+// voteSet returns (creating if needed) the vote set of voteType at round (caller holds the lock)
+func (cs *ConsensusState) voteSet(voteType VoteType, round int32) *VoteSet {
+	var sets map[int32]*VoteSet
+	switch voteType {
+	case VoteTypePrevote:
+		sets = cs.prevotesByRound
+	case VoteTypePrecommit:
+		sets = cs.precommitsByRound
+	default:
+		return nil
+	}
+
+	if set, ok := sets[round]; ok {
+		return set
+	}
+	set := NewVoteSet(cs.Height, round, voteType, cs.Validators)
+	sets[round] = set
+	return set
+}
+
+// SetValidators replaces the validator set (only between heights)
+func (cs *ConsensusState) SetValidators(validators *ValidatorSet) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.Validators = validators
+	cs.resetVoteSets()
+}
+
+// RestoreRoundState restores round, step and lock state at the current height (WAL replay)
+func (cs *ConsensusState) RestoreRoundState(round int32, step RoundStep, lockedRound int32, lockedBlock [32]byte, validRound int32, validBlock [32]byte) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.Round = round
+	cs.Step = step
+	cs.Prevotes = cs.voteSet(VoteTypePrevote, round)
+	cs.Precommits = cs.voteSet(VoteTypePrecommit, round)
+	cs.LockedRound = lockedRound
+	cs.LockedBlock = lockedBlock
+	cs.ValidRound = validRound
+	cs.ValidBlock = validBlock
+}
+
+// ProposeTimeoutFor returns the propose timeout of round (escalates every round)
+func (cs *ConsensusState) ProposeTimeoutFor(round int32) time.Duration {
+	return cs.ProposeTimeout + time.Duration(round)*cs.ProposeTimeoutDelta
+}
+
+// PrevoteTimeoutFor returns the prevote timeout of round (escalates every round)
+func (cs *ConsensusState) PrevoteTimeoutFor(round int32) time.Duration {
+	return cs.PrevoteTimeout + time.Duration(round)*cs.PrevoteTimeoutDelta
+}
+
+// PrecommitTimeoutFor returns the precommit timeout of round (escalates every round)
+func (cs *ConsensusState) PrecommitTimeoutFor(round int32) time.Duration {
+	return cs.PrecommitTimeout + time.Duration(round)*cs.PrecommitTimeoutDelta
+}
+
+// EnterNewRound starts round at the current height
+// Votes already received for the round (from faster peers) are kept; locks carry over.
+func (cs *ConsensusState) EnterNewRound(height uint64, round int32) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.Height != height || cs.Round > round || (cs.Round == round && cs.Step != RoundStepNewHeight) {
+		return
+	}
+
+	cs.Round = round
+	cs.Step = RoundStepNewHeight
+	cs.Proposal = nil
+	cs.ProposalBlock = nil
+	cs.Prevotes = cs.voteSet(VoteTypePrevote, round)
+	cs.Precommits = cs.voteSet(VoteTypePrecommit, round)
+
+	if round > 0 {
+		fmt.Printf("[Consensus] Height %d: Entered NEW ROUND %d\n", height, round)
+	}
+}
+
 // EnterPropose enters the propose step
 func (cs *ConsensusState) EnterPropose(height uint64, round int32) {
 	cs.mu.Lock()
@@ -177,8 +309,7 @@ func (cs *ConsensusState) FinalizeCommit(height uint64) {
 	cs.ValidBlock = [32]byte{}
 
 	// Reset vote sets for new height
-	cs.Prevotes = NewVoteSet(cs.Height, 0, VoteTypePrevote, cs.Validators)
-	cs.Precommits = NewVoteSet(cs.Height, 0, VoteTypePrecommit, cs.Validators)
+	cs.resetVoteSets()
 
 	fmt.Printf("[Consensus] Finalized commit. Moving to Height %d\n", cs.Height)
 }
@@ -192,10 +323,14 @@ func (cs *ConsensusState) SetProposal(proposal *Proposal, block interface{}) err
 		return fmt.Errorf("proposal height/round mismatch")
 	}
 
-	// Check if proposer is valid
-	if cs.Validators.GetProposer().Address != proposal.Proposer {
+	// Check if proposer is valid for this round and actually signed it
+	proposer := cs.Validators.ProposerForRound(proposal.Round)
+	if proposer == nil || proposer.Address != proposal.Proposer {
 		return fmt.Errorf("invalid proposer")
 	}
+	if !proposal.Verify(proposer.PubKey) {
+		return fmt.Errorf("invalid proposal signature")
+	}
 
 	cs.Proposal = proposal
 	cs.ProposalBlock = block
@@ -208,21 +343,15 @@ func (cs *ConsensusState) AddVote(vote *Vote) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	var voteSet *VoteSet
-
-	switch vote.Type {
-	case VoteTypePrevote:
-		if vote.Height == cs.Height && vote.Round == cs.Round {
-			voteSet = cs.Prevotes
-		}
-	case VoteTypePrecommit:
-		if vote.Height == cs.Height && vote.Round == cs.Round {
-			voteSet = cs.Precommits
-		}
+	// Accept votes for earlier rounds (proof-of-lock) and the next round
+	// (peers that timed out first); anything further ahead is dropped
+	if vote.Height != cs.Height || vote.Round < 0 || vote.Round > cs.Round+1 {
+		return fmt.Errorf("vote for different height/round")
 	}
 
+	voteSet := cs.voteSet(vote.Type, vote.Round)
 	if voteSet == nil {
-		return fmt.Errorf("vote for different height/round")
+		return fmt.Errorf("unknown vote type %d", vote.Type)
 	}
 
 	added, err := voteSet.AddVote(vote)
@@ -252,6 +381,107 @@ func (cs *ConsensusState) HasTwoThirdsPrecommits() (bool, [32]byte) {
 	return cs.Precommits.HasTwoThirdsMajority()
 }
 
+// HasTwoThirdsPrevotesAt checks if round has 2/3+ prevotes for any block (or nil)
+func (cs *ConsensusState) HasTwoThirdsPrevotesAt(round int32) (bool, [32]byte) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if set, ok := cs.prevotesByRound[round]; ok {
+		return set.HasTwoThirdsMajority()
+	}
+	return false, [32]byte{}
+}
+
+// HasTwoThirdsPrecommitsAt checks if round has 2/3+ precommits for any block (or nil)
+func (cs *ConsensusState) HasTwoThirdsPrecommitsAt(round int32) (bool, [32]byte) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if set, ok := cs.precommitsByRound[round]; ok {
+		return set.HasTwoThirdsMajority()
+	}
+	return false, [32]byte{}
+}
+
+// CommittedRound finds the first round of the height with 2/3+ precommits for a block
+// Once one exists the block is decided, whatever round we have moved on to.
+func (cs *ConsensusState) CommittedRound() (int32, [32]byte, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	committedRound, committed := int32(-1), [32]byte{}
+	for round, set := range cs.precommitsByRound {
+		if committedRound != -1 && round > committedRound {
+			continue
+		}
+		if ok, hash := set.HasTwoThirdsMajority(); ok && hash != ([32]byte{}) {
+			committedRound, committed = round, hash
+		}
+	}
+	return committedRound, committed, committedRound != -1
+}
+
+// HasPOL checks for a proof-of-lock: 2/3+ prevotes for blockHash at round
+func (cs *ConsensusState) HasPOL(round int32, blockHash [32]byte) bool {
+	ok, hash := cs.HasTwoThirdsPrevotesAt(round)
+	return ok && hash == blockHash
+}
+
+// Lock locks on blockHash at round (after seeing 2/3+ prevotes for it)
+func (cs *ConsensusState) Lock(round int32, blockHash [32]byte) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.LockedRound = round
+	cs.LockedBlock = blockHash
+	fmt.Printf("[Consensus] Height %d Round %d: LOCKED on block %x\n", cs.Height, round, blockHash[:4])
+}
+
+// Unlock releases the lock (after seeing 2/3+ prevotes for nil in a later round)
+func (cs *ConsensusState) Unlock() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.LockedRound == -1 {
+		return
+	}
+	cs.LockedRound = -1
+	cs.LockedBlock = [32]byte{}
+	fmt.Printf("[Consensus] Height %d Round %d: UNLOCKED\n", cs.Height, cs.Round)
+}
+
+// SetValid records blockHash as the latest block with 2/3+ prevotes
+func (cs *ConsensusState) SetValid(round int32, blockHash [32]byte) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.ValidRound = round
+	cs.ValidBlock = blockHash
+}
+
+// GetLock returns the locked round and block (-1 = not locked)
+func (cs *ConsensusState) GetLock() (int32, [32]byte) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.LockedRound, cs.LockedBlock
+}
+
+// GetValid returns the valid round and block (-1 = none)
+func (cs *ConsensusState) GetValid() (int32, [32]byte) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ValidRound, cs.ValidBlock
+}
+
+// MakeCommit builds the commit certificate for blockHash from the current precommits
+func (cs *ConsensusState) MakeCommit(blockHash [32]byte) (*types.Commit, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.Precommits.MakeCommit(blockHash)
+}
+
+// MakeCommitAt builds the commit certificate from the precommits of round
+func (cs *ConsensusState) MakeCommitAt(round int32, blockHash [32]byte) (*types.Commit, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.voteSet(VoteTypePrecommit, round).MakeCommit(blockHash)
+}
+
 // GetState returns current height, round, and step (thread-safe)
 func (cs *ConsensusState) GetState() (uint64, int32, RoundStep) {
 	cs.mu.RLock()
@@ -100,6 +100,28 @@ func (vs *ValidatorSet) GetProposer() *Validator {
 	return vs.Proposer
 }
 
+// ProposerForRound returns the proposer of round at the current height without modifying the set
+// Round 0 is the current proposer; every further round advances the weighted round-robin once.
+func (vs *ValidatorSet) ProposerForRound(round int32) *Validator {
+	proposer := vs.GetProposer()
+	if round <= 0 || proposer == nil {
+		return proposer
+	}
+
+	// Advance a copy that keeps the current priorities
+	cp := &ValidatorSet{
+		Validators:       make([]*Validator, len(vs.Validators)),
+		totalVotingPower: vs.totalVotingPower,
+	}
+	for i, v := range vs.Validators {
+		valCopy := *v
+		cp.Validators[i] = &valCopy
+	}
+	cp.IncrementProposerPriority(int(round))
+
+	return vs.GetByAddress(cp.Proposer.Address)
+}
+
 // GetByAddress returns validator by address
 func (vs *ValidatorSet) GetByAddress(address [32]byte) *Validator {
 	for _, val := range vs.Validators {
@@ -16,6 +16,17 @@ const (
 	VoteTypePrecommit VoteType = 0x02 // Second voting phase (commitment)
 )
 
+func (vt VoteType) String() string {
+	switch vt {
+	case VoteTypePrevote:
+		return "Prevote"
+	case VoteTypePrecommit:
+		return "Precommit"
+	default:
+		return "Unknown"
+	}
+}
+
 // Vote represents a validator's vote in consensus
 type Vote struct {
 	Type      VoteType // Prevote or Precommit
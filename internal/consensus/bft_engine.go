@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/slashing"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
@@ -20,25 +21,63 @@ type BFTEngine struct {
 	Validators *bft.ValidatorSet
 
 	// Security
-	SlashingTracker *slashing.SlashingTracker
-	voteCache       *voteCache // Track votes to detect double-signing
+	EvidencePool *slashing.EvidencePool // Double-sign evidence awaiting inclusion
+	voteCache    *voteCache             // Track votes to detect double-signing
 
 	// Node identity
 	ValidatorAddress [32]byte
 	ValidatorPrivKey ed25519.PrivateKey
 	ValidatorIndex   int32
+	SignGuard        *bft.SignGuard // Persistent double-sign protection (nil = sign unguarded)
 
 	// Communication channels
 	VoteChan     chan *bft.Vote
 	ProposalChan chan *bft.Proposal
-	BlockChan    chan *types.Block
+	PartChan     chan *bft.BlockPart
 
 	// Callbacks for P2P broadcasting
-	BroadcastVote     func(*bft.Vote) error
-	BroadcastProposal func(*bft.Proposal) error
-	MarkFinalized     func(uint64, [32]byte) error // Called when block reaches 2/3+ precommits
+	BroadcastVote      func(*bft.Vote) error
+	BroadcastProposal  func(*bft.Proposal) error
+	BroadcastBlockPart func(*bft.BlockPart) error
+	BroadcastEvidence  func(*types.DoubleSignEvidence) error
+	MarkFinalized      func(uint64, [32]byte, *types.Commit) error // Called with the commit certificate when block reaches 2/3+ precommits
+
+	// Chain access
+	GetTip     func() types.BlockHeader            // Header proposals are built on (e.g. Blockchain.GetTip)
+	LoadCommit func(uint64) (*types.Commit, error) // Persisted commit certificates, used after a restart (e.g. Blockchain.GetCommit)
+	CheckBlock func(types.Block) error             // Validates and dry-runs a block against state (e.g. Blockchain.CheckBlock)
+
+	// Checks evidence is executable in the block at height (e.g. state.Manager.CheckEvidence)
+	CheckEvidence func(types.DoubleSignEvidence, uint64) error
+
+	// Recent commit certificates (height -> commit), until they are persisted with their blocks
+	commits map[uint64]*types.Commit
+
+	// Proposal blocks created or assembled from signed part sets at the current height, by block ID
+	proposalBlocks map[[32]byte]*proposalBlock
+
+	// Write-ahead log for crash recovery (nil = disabled)
+	WAL         *WAL
+	resumeRound int32 // First round to run after WAL replay
+
+	// Time source for timeouts and timestamps (nil = SystemClock)
+	Clock Clock
+	// Called just before blocking on network input or a timeout (lets a simulator schedule deterministically)
+	OnIdle func()
+
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
+// proposalBlock is a proposed block and the part set it was gossiped as (together they make its bft.BlockID)
+type proposalBlock struct {
+	block *types.Block
+	parts bft.PartSetHeader
+}
+
+// maxCachedCommits bounds the in-memory commit certificate cache
+const maxCachedCommits = 100
+
 // NewBFTEngine creates a new BFT consensus engine
 func NewBFTEngine(
 	height uint64,
@@ -60,273 +99,703 @@ func NewBFTEngine(
 	engine := &BFTEngine{
 		State:            state,
 		Validators:       validators,
-		SlashingTracker:  slashing.NewSlashingTracker(),
+		EvidencePool:     slashing.NewEvidencePool(params.UnbondingPeriod),
 		voteCache:        newVoteCache(),
 		ValidatorAddress: validatorAddr,
 		ValidatorPrivKey: privKey,
 		ValidatorIndex:   valIndex,
 		VoteChan:         make(chan *bft.Vote, 100),
 		ProposalChan:     make(chan *bft.Proposal, 10),
-		BlockChan:        make(chan *types.Block, 10),
+		PartChan:         make(chan *bft.BlockPart, 100),
+		commits:          make(map[uint64]*types.Commit),
+		quit:             make(chan struct{}),
 	}
 
 	return engine
 }
 
-// RunConsensusRound executes one round of BFT consensus
-// Returns the committed block or error
+// RunConsensusRound runs BFT consensus at height until a block is committed
+// A round that fails to commit (offline proposer, split or nil votes) moves on
+// to the next round with the next proposer and longer timeouts. Returns the
+// committed block, or an error if the engine is stopped first.
 func (be *BFTEngine) RunConsensusRound(
 	height uint64,
 	mempool []types.Transaction,
 ) (*types.Block, error) {
-	fmt.Printf("\n[BFT] Starting consensus round for height %d\n", height)
+	fmt.Printf("\n[BFT] Starting consensus for height %d\n", height)
+
+	// Proposal blocks seen at this height, by hash (locked/valid blocks are re-proposed from here)
+	if be.proposalBlocks == nil {
+		be.proposalBlocks = make(map[[32]byte]*proposalBlock)
+	}
+
+	// Earlier heights are final; keep the WAL short
+	if be.WAL != nil {
+		if err := be.WAL.Compact(height); err != nil {
+			fmt.Printf("[BFT] Warning: Failed to compact WAL: %v\n", err)
+		}
+	}
 
-	// Phase 1: NewHeight → Propose
-	be.State.EnterPropose(height, 0)
+	for round := be.resumeRound; ; round++ {
+		select {
+		case <-be.quit:
+			return nil, fmt.Errorf("consensus stopped at height %d round %d", height, round)
+		default:
+		}
+
+		commitRound, blockID, err := be.runRound(height, round, mempool)
+		if err != nil {
+			return nil, err
+		}
+		if blockID != ([32]byte{}) {
+			return be.commitBlock(height, commitRound, blockID)
+		}
+
+		fmt.Printf("[BFT] Height %d round %d ended without commit, moving to round %d\n", height, round, round+1)
+	}
+}
+
+// runRound executes one propose/prevote/precommit round
+// Returns the round and ID of a block with 2/3+ precommits (in this round, or in an
+// earlier one whose votes arrived late), or the zero hash to move to the next round.
+func (be *BFTEngine) runRound(height uint64, round int32, mempool []types.Transaction) (int32, [32]byte, error) {
+	// Phase 1: NewRound → Propose
+	be.State.EnterNewRound(height, round)
+	if commitRound, blockID, ok := be.State.CommittedRound(); ok {
+		return be.awaitCommittedBlock(height, round, commitRound, blockID)
+	}
+	be.State.EnterPropose(height, round)
+	be.walStep(height, round)
 
-	var proposedBlock *types.Block
+	proposal, block := be.propose(height, round, mempool)
+	if proposal != nil && block != nil {
+		be.walWrite(WALRecord{Type: WALRecordProposal, Height: height, Round: round, Proposal: proposal, Block: block}, false)
+	}
+	if commitRound, blockID, ok := be.State.CommittedRound(); ok {
+		return be.awaitCommittedBlock(height, round, commitRound, blockID)
+	}
+
+	// Phase 2: Prevote
+	be.State.EnterPrevote(height, round)
+	be.walStep(height, round)
+	be.castVote(bft.VoteTypePrevote, height, round, be.prevoteFor(round, proposal, block))
+
+	polka, polkaHash := be.awaitTwoThirds(height, round, bft.VoteTypePrevote, be.State.PrevoteTimeoutFor(round))
+	if commitRound, blockID, ok := be.State.CommittedRound(); ok {
+		return be.awaitCommittedBlock(height, round, commitRound, blockID)
+	}
+
+	// Phase 3: Precommit
+	be.State.EnterPrecommit(height, round)
+
+	var precommitHash [32]byte
+	switch {
+	case !polka:
+		// Prevote timeout without a majority: precommit nil
+		fmt.Printf("[BFT] No 2/3+ prevotes in round %d, precommitting nil\n", round)
+	case polkaHash == [32]byte{}:
+		// 2/3+ prevoted nil: release any lock
+		be.State.Unlock()
+	case be.proposalBlocks[polkaHash] != nil:
+		// Polka for a block we have: lock on it and precommit it
+		fmt.Printf("[BFT] Reached 2/3+ prevotes for block %x\n", polkaHash[:4])
+		be.State.Lock(round, polkaHash)
+		be.State.SetValid(round, polkaHash)
+		precommitHash = polkaHash
+	default:
+		fmt.Printf("[BFT] 2/3+ prevotes for unknown block %x, precommitting nil\n", polkaHash[:4])
+	}
+	be.walStep(height, round)
+	be.castVote(bft.VoteTypePrecommit, height, round, precommitHash)
+
+	be.awaitTwoThirds(height, round, bft.VoteTypePrecommit, be.State.PrecommitTimeoutFor(round))
+	if commitRound, blockID, ok := be.State.CommittedRound(); ok {
+		return be.awaitCommittedBlock(height, round, commitRound, blockID)
+	}
+	return -1, [32]byte{}, nil
+}
+
+// awaitCommittedBlock returns the block 2/3+ precommitted in commitRound, first waiting for
+// its proposal and parts if we missed them (peers re-gossip them to validators still at the
+// height). Gives up after the round's propose timeout, so the next round tries again.
+func (be *BFTEngine) awaitCommittedBlock(height uint64, round, commitRound int32, blockID [32]byte) (int32, [32]byte, error) {
+	if _, ok := be.proposalBlocks[blockID]; ok {
+		fmt.Printf("[BFT] Reached 2/3+ precommits for block %x\n", blockID[:4])
+		return commitRound, blockID, nil
+	}
+
+	fmt.Printf("[BFT] Block %x was committed in round %d, waiting for its parts\n", blockID[:4], commitRound)
+	be.State.EnterCommit(height, commitRound)
+
+	deadline := be.clock().After(be.State.ProposeTimeoutFor(round))
 	var proposal *bft.Proposal
+	var partSet *bft.PartSet
+	var pendingParts []*bft.BlockPart
+	for {
+		if proposal != nil && partSet.IsComplete() {
+			if _, err := be.assembleProposalBlock(proposal, partSet); err != nil {
+				fmt.Printf("[BFT] Warning: Bad committed block: %v\n", err)
+				proposal, partSet = nil, nil
+			} else {
+				return commitRound, blockID, nil
+			}
+		}
 
-	// Check if we are the proposer
-	proposer := be.Validators.GetProposer()
-	weAreProposer := proposer.Address == be.ValidatorAddress
+		be.idle()
+		select {
+		case p := <-be.ProposalChan:
+			// The block ID binds the part set, so any round's proposal of the block will do
+			if proposal != nil || p.Height != height || p.BlockHash != blockID {
+				continue
+			}
+			ps, err := bft.NewPartSet(p.BlockParts)
+			if err != nil {
+				continue
+			}
+			proposal, partSet = p, ps
+			for _, part := range pendingParts {
+				be.addBlockPart(partSet, part)
+			}
+			pendingParts = nil
 
-	if weAreProposer {
-		// We propose the block
-		fmt.Printf("[BFT] We are the proposer (validator %x)\n", be.ValidatorAddress[:4])
+		case part := <-be.PartChan:
+			// Parts are checked against the part set root, whichever round they were sent in
+			if part.Height != height {
+				continue
+			}
+			if partSet == nil {
+				if len(pendingParts) < bft.MaxBlockParts {
+					pendingParts = append(pendingParts, part)
+				}
+				continue
+			}
+			be.addBlockPart(partSet, part)
 
-		// Create block using existing mining logic (PoW + Sorting)
-		// Get previous block header from blockchain
-		// For now, create a simplified block
-		block, err := be.createBlock(height, mempool)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create proposal block: %w", err)
+		case vote := <-be.VoteChan:
+			be.handleVote(height, vote)
+
+		case <-deadline:
+			fmt.Printf("[BFT] Timeout waiting for committed block %x\n", blockID[:4])
+			return -1, [32]byte{}, nil
+
+		case <-be.quit:
+			return -1, [32]byte{}, nil
 		}
+	}
+}
 
-		proposedBlock = block
+// propose creates and broadcasts our proposal if we are this round's proposer,
+// otherwise waits for the proposer's. Returns nils on propose timeout.
+func (be *BFTEngine) propose(height uint64, round int32, mempool []types.Transaction) (*bft.Proposal, *types.Block) {
+	proposer := be.Validators.ProposerForRound(round)
+	if proposer == nil {
+		return nil, nil
+	}
 
-		// Create proposal
-		proposal = &bft.Proposal{
-			Height:    height,
-			Round:     0,
-			BlockHash: block.Header.Hash,
-			Timestamp: time.Now().Unix(),
-			Proposer:  be.ValidatorAddress,
+	if proposer.Address == be.ValidatorAddress {
+		fmt.Printf("[BFT] We are the proposer for round %d (validator %x)\n", round, be.ValidatorAddress[:4])
+
+		// Re-propose the block that already has a polka, so a locked network can commit it
+		polRound := int32(-1)
+		validRound, validHash := be.State.GetValid()
+		var block *types.Block
+		if pb, ok := be.proposalBlocks[validHash]; validRound >= 0 && ok {
+			block, polRound = pb.block, validRound
+		} else {
+			var err error
+			block, err = be.createBlock(height, mempool)
+			if err != nil {
+				fmt.Printf("[BFT] Warning: Failed to create proposal block: %v\n", err)
+				return nil, nil
+			}
+		}
+
+		partsHeader, parts, err := bft.NewPartSetFromBlock(block, height, round)
+		if err != nil {
+			fmt.Printf("[BFT] Warning: Failed to split proposal block: %v\n", err)
+			return nil, nil
+		}
+		blockID := bft.BlockID(block.Header, partsHeader)
+		be.proposalBlocks[blockID] = &proposalBlock{block: block, parts: partsHeader}
+
+		proposal := &bft.Proposal{
+			Height:     height,
+			Round:      round,
+			POLRound:   polRound,
+			BlockHash:  blockID,
+			BlockParts: partsHeader,
+			Timestamp:  be.clock().Now().Unix(),
+			Proposer:   be.ValidatorAddress,
+		}
+		if err := be.signProposal(proposal); err != nil {
+			fmt.Printf("[BFT] ⛔ %v\n", err)
+			return nil, nil
 		}
 
-		// Set proposal in consensus state
 		if err := be.State.SetProposal(proposal, block); err != nil {
-			return nil, fmt.Errorf("failed to set proposal: %w", err)
+			fmt.Printf("[BFT] Warning: Failed to set own proposal: %v\n", err)
+			return nil, nil
 		}
 
-		// Broadcast proposal to network
+		// Proposal first so peers can verify the parts against its signed part set header
 		if be.BroadcastProposal != nil {
 			if err := be.BroadcastProposal(proposal); err != nil {
 				fmt.Printf("[BFT] Warning: Failed to broadcast proposal: %v\n", err)
 			}
 		}
+		if be.BroadcastBlockPart != nil {
+			for _, part := range parts {
+				if err := be.BroadcastBlockPart(part); err != nil {
+					fmt.Printf("[BFT] Warning: Failed to broadcast block part %d/%d: %v\n", part.Index+1, len(parts), err)
+				}
+			}
+		}
+		return proposal, block
+	}
+
+	// Wait for the proposal and its block from the network
+	fmt.Printf("[BFT] Waiting for round %d proposal from proposer %x\n", round, proposer.Address[:4])
 
-		// Broadcast block data
-		if be.BlockChan != nil {
-			be.BlockChan <- block
+	deadline := be.clock().After(be.State.ProposeTimeoutFor(round))
+	var proposal *bft.Proposal
+	var partSet *bft.PartSet
+	var pendingParts []*bft.BlockPart // Parts that arrived before their proposal
+	for {
+		if proposal != nil {
+			// A block already known under the ID was gossiped as the same parts (the ID binds them)
+			pb, ok := be.proposalBlocks[proposal.BlockHash]
+			if !ok && partSet.IsComplete() {
+				var err error
+				if pb, err = be.assembleProposalBlock(proposal, partSet); err != nil {
+					fmt.Printf("[BFT] Warning: Bad proposal block: %v, prevoting nil\n", err)
+					return nil, nil
+				}
+				ok = true
+			}
+			if ok {
+				if pb.parts != proposal.BlockParts {
+					fmt.Printf("[BFT] Warning: Proposal %x announces other parts than its block, prevoting nil\n", proposal.BlockHash[:4])
+					return nil, nil
+				}
+				if err := be.State.SetProposal(proposal, pb.block); err != nil {
+					fmt.Printf("[BFT] Warning: Rejected proposal: %v\n", err)
+					return nil, nil
+				}
+				return proposal, pb.block
+			}
 		}
-	} else {
-		// Wait for proposal from network
-		fmt.Printf("[BFT] Waiting for proposal from proposer %x\n", proposer.Address[:4])
 
+		be.idle()
 		select {
-		case proposal = <-be.ProposalChan:
-			if proposal.Proposer != proposer.Address {
-				return nil, fmt.Errorf("proposal from wrong proposer")
+		case p := <-be.ProposalChan:
+			if p.Height != height || p.Round != round || proposal != nil {
+				continue
 			}
+			// Authenticate the proposal before accepting any of its parts
+			if p.Proposer != proposer.Address {
+				fmt.Printf("[BFT] Warning: Proposal from wrong proposer %x\n", p.Proposer[:4])
+				continue
+			}
+			if !p.Verify(proposer.PubKey) {
+				fmt.Printf("[BFT] Warning: Invalid proposal signature from %x\n", p.Proposer[:4])
+				continue
+			}
+			ps, err := bft.NewPartSet(p.BlockParts)
+			if err != nil {
+				fmt.Printf("[BFT] Warning: Invalid proposal part set: %v\n", err)
+				continue
+			}
+			proposal, partSet = p, ps
+			for _, part := range pendingParts {
+				be.addBlockPart(partSet, part)
+			}
+			pendingParts = nil
 
-			// Wait for block data
-			select {
-			case proposedBlock = <-be.BlockChan:
-				if err := be.State.SetProposal(proposal, proposedBlock); err != nil {
-					return nil, fmt.Errorf("failed to set proposal: %w", err)
+		case part := <-be.PartChan:
+			if part.Height != height || part.Round != round {
+				continue
+			}
+			if partSet == nil {
+				if len(pendingParts) < bft.MaxBlockParts {
+					pendingParts = append(pendingParts, part)
 				}
-			case <-time.After(be.State.ProposeTimeout):
-				return nil, fmt.Errorf("timeout waiting for block data")
+				continue
 			}
+			be.addBlockPart(partSet, part)
 
-		case <-time.After(be.State.ProposeTimeout):
-			return nil, fmt.Errorf("timeout waiting for proposal")
-		}
-	}
+		case vote := <-be.VoteChan:
+			be.handleVote(height, vote)
+			if _, _, ok := be.State.CommittedRound(); ok {
+				return nil, nil // Decided in an earlier round
+			}
 
-	// Phase 2: Prevote
-	be.State.EnterPrevote(height, 0)
+		case <-deadline:
+			fmt.Printf("[BFT] Timeout waiting for round %d proposal, prevoting nil\n", round)
+			return nil, nil
 
-	// Create and broadcast our prevote
-	prevote := &bft.Vote{
-		Type:             bft.VoteTypePrevote,
-		Height:           height,
-		Round:            0,
-		BlockHash:        proposedBlock.Header.Hash,
-		Timestamp:        time.Now().Unix(),
-		ValidatorAddress: be.ValidatorAddress,
-		ValidatorIndex:   be.ValidatorIndex,
+		case <-be.quit:
+			return nil, nil
+		}
 	}
-	prevote.Sign(be.ValidatorPrivKey)
+}
 
-	// Add our own vote
-	if err := be.State.AddVote(prevote); err != nil {
-		fmt.Printf("[BFT] Warning: Failed to add own prevote: %v\n", err)
+// addBlockPart adds a gossiped part to the proposal's part set, dropping invalid ones
+func (be *BFTEngine) addBlockPart(partSet *bft.PartSet, part *bft.BlockPart) {
+	if _, err := partSet.AddPart(part); err != nil {
+		fmt.Printf("[BFT] Warning: Dropping block part %d: %v\n", part.Index, err)
 	}
+}
 
-	// Broadcast prevote
-	if be.BroadcastVote != nil {
-		if err := be.BroadcastVote(prevote); err != nil {
-			fmt.Printf("[BFT] Warning: Failed to broadcast prevote: %v\n", err)
-		}
+// assembleProposalBlock decodes the complete part set signed by the proposal and checks it is the proposed block
+// Blocks only ever enter proposalBlocks this way or from our own proposals.
+func (be *BFTEngine) assembleProposalBlock(proposal *bft.Proposal, partSet *bft.PartSet) (*proposalBlock, error) {
+	block, err := partSet.Block()
+	if err != nil {
+		return nil, err
+	}
+	if id := bft.BlockID(block.Header, proposal.BlockParts); id != proposal.BlockHash || block.Header.Hash != types.HashBlockHeaderForPoW(block.Header) {
+		return nil, fmt.Errorf("assembled block %x does not match proposal %x", id[:4], proposal.BlockHash[:4])
 	}
 
-	// Collect prevotes from network with timeout
-	prevoteDeadline := time.Now().Add(be.State.PrevoteTimeout)
-	prevoteReached := false
+	pb := &proposalBlock{block: block, parts: proposal.BlockParts}
+	be.proposalBlocks[proposal.BlockHash] = pb
+	return pb, nil
+}
 
-	for time.Now().Before(prevoteDeadline) {
-		select {
-		case vote := <-be.VoteChan:
-			if vote.Type == bft.VoteTypePrevote && vote.Height == height {
-				// Check for double-signing
-				if be.detectDoubleSign(vote, be.voteCache) {
-					fmt.Printf("[BFT] ⚠️  DOUBLE-SIGN DETECTED from validator %x - SLASHED!\n", vote.ValidatorAddress[:4])
-					continue // Skip this vote
-				}
+// prevoteFor validates the proposal and applies the locking rules to pick our prevote (zero hash = nil)
+func (be *BFTEngine) prevoteFor(round int32, proposal *bft.Proposal, block *types.Block) [32]byte {
+	lockedRound, lockedHash := be.State.GetLock()
+	if proposal == nil || block == nil {
+		return be.lockedPrevote(lockedRound, lockedHash)
+	}
 
-				if err := be.State.AddVote(vote); err != nil {
-					fmt.Printf("[BFT] Warning: Invalid prevote: %v\n", err)
-					continue
-				}
+	if err := be.validateProposalBlock(proposal.Height, block); err != nil {
+		fmt.Printf("[BFT] ❌ Invalid proposal block %x: %v, not prevoting it\n", proposal.BlockHash[:4], err)
+		return be.lockedPrevote(lockedRound, lockedHash)
+	}
 
-				// Check if we have 2/3+ prevotes
-				if has2_3, blockHash := be.State.HasTwoThirdsPrevotes(); has2_3 {
-					if blockHash == proposedBlock.Header.Hash {
-						fmt.Printf("[BFT] Reached 2/3+ prevotes for block %x\n", blockHash[:4])
-						prevoteReached = true
-						break
-					}
-				}
-			}
+	switch {
+	case lockedRound == -1 || lockedHash == proposal.BlockHash:
+		return proposal.BlockHash
+	case proposal.POLRound > lockedRound && proposal.POLRound < round &&
+		be.State.HasPOL(proposal.POLRound, proposal.BlockHash):
+		// A newer polka for another block justifies switching
+		return proposal.BlockHash
+	default:
+		return be.lockedPrevote(lockedRound, lockedHash)
+	}
+}
 
-		case <-time.After(100 * time.Millisecond):
-			// Check periodically
-			if has2_3, blockHash := be.State.HasTwoThirdsPrevotes(); has2_3 {
-				if blockHash == proposedBlock.Header.Hash {
-					prevoteReached = true
-					break
-				}
-			}
-		}
+// lockedPrevote is our prevote when we cannot vote for the proposal
+// Locked validators keep prevoting their block, so the 2/3+ that precommitted a
+// committed block can never form a nil polka and unlock.
+func (be *BFTEngine) lockedPrevote(lockedRound int32, lockedHash [32]byte) [32]byte {
+	if lockedRound == -1 {
+		return [32]byte{}
+	}
+	if _, ok := be.proposalBlocks[lockedHash]; !ok {
+		fmt.Printf("[BFT] Locked on unknown block %x since round %d, prevoting nil\n", lockedHash[:4], lockedRound)
+		return [32]byte{}
 	}
+	fmt.Printf("[BFT] Locked on block %x since round %d, prevoting it\n", lockedHash[:4], lockedRound)
+	return lockedHash
+}
 
-	if !prevoteReached {
-		return nil, fmt.Errorf("failed to reach 2/3+ prevotes")
+// validateProposalBlock checks that block extends our tip at height and applies cleanly to state
+func (be *BFTEngine) validateProposalBlock(height uint64, block *types.Block) error {
+	if block.Header.Height != height {
+		return fmt.Errorf("block height %d, expected %d", block.Header.Height, height)
 	}
+	if block.Header.Hash != types.HashBlockHeaderForPoW(block.Header) {
+		return fmt.Errorf("header hash does not match contents")
+	}
+	if be.CheckBlock == nil {
+		return fmt.Errorf("no block validator configured")
+	}
+	return be.CheckBlock(*block)
+}
 
-	// Phase 3: Precommit
-	be.State.EnterPrecommit(height, 0)
+// castVote signs, records and broadcasts our vote for blockHash (zero hash = nil)
+func (be *BFTEngine) castVote(voteType bft.VoteType, height uint64, round int32, blockHash [32]byte) {
+	if be.ValidatorIndex < 0 {
+		return // Not a validator this epoch
+	}
 
-	// Create and broadcast our precommit
-	precommit := &bft.Vote{
-		Type:             bft.VoteTypePrecommit,
+	vote := &bft.Vote{
+		Type:             voteType,
 		Height:           height,
-		Round:            0,
-		BlockHash:        proposedBlock.Header.Hash,
-		Timestamp:        time.Now().Unix(),
+		Round:            round,
+		BlockHash:        blockHash,
+		Timestamp:        be.clock().Now().Unix(),
 		ValidatorAddress: be.ValidatorAddress,
 		ValidatorIndex:   be.ValidatorIndex,
 	}
-	precommit.Sign(be.ValidatorPrivKey)
+	if err := be.signVote(vote); err != nil {
+		fmt.Printf("[BFT] ⛔ %v\n", err)
+		return
+	}
 
-	// Add our own vote
-	if err := be.State.AddVote(precommit); err != nil {
-		fmt.Printf("[BFT] Warning: Failed to add own precommit: %v\n", err)
+	// The vote must be durable before anyone can see it, or a restart could sign a conflicting one
+	if err := be.walWrite(WALRecord{Type: WALRecordOwnVote, Height: height, Round: round, Vote: vote}, true); err != nil {
+		fmt.Printf("[BFT] Not casting %s: %v\n", voteType, err)
+		return
+	}
+
+	if err := be.State.AddVote(vote); err != nil {
+		fmt.Printf("[BFT] Warning: Failed to add own %s: %v\n", voteType, err)
 	}
 
-	// Broadcast precommit
 	if be.BroadcastVote != nil {
-		if err := be.BroadcastVote(precommit); err != nil {
-			fmt.Printf("[BFT] Warning: Failed to broadcast precommit: %v\n", err)
+		if err := be.BroadcastVote(vote); err != nil {
+			fmt.Printf("[BFT] Warning: Failed to broadcast %s: %v\n", voteType, err)
 		}
 	}
+}
+
+// awaitTwoThirds collects votes until round has 2/3+ of voteType for one value or timeout expires
+func (be *BFTEngine) awaitTwoThirds(height uint64, round int32, voteType bft.VoteType, timeout time.Duration) (bool, [32]byte) {
+	check := be.State.HasTwoThirdsPrevotesAt
+	if voteType == bft.VoteTypePrecommit {
+		check = be.State.HasTwoThirdsPrecommitsAt
+	}
 
-	// Collect precommits from network
-	precommitDeadline := time.Now().Add(be.State.PrecommitTimeout)
-	commitReached := false
+	deadline := be.clock().After(timeout)
+	for {
+		if ok, hash := check(round); ok {
+			return true, hash
+		}
+		if _, _, ok := be.State.CommittedRound(); ok {
+			return false, [32]byte{} // Decided in an earlier round
+		}
 
-	for time.Now().Before(precommitDeadline) {
+		be.idle()
 		select {
 		case vote := <-be.VoteChan:
-			if vote.Type == bft.VoteTypePrecommit && vote.Height == height {
-				// Check for double-signing
-				if be.detectDoubleSign(vote, be.voteCache) {
-					fmt.Printf("[BFT] ⚠️  DOUBLE-SIGN DETECTED from validator %x - SLASHED!\n", vote.ValidatorAddress[:4])
-					continue // Skip this vote
-				}
-
-				if err := be.State.AddVote(vote); err != nil {
-					fmt.Printf("[BFT] Warning: Invalid precommit: %v\n", err)
-					continue
-				}
+			be.handleVote(height, vote)
+		case <-deadline:
+			return check(round)
+		case <-be.quit:
+			return false, [32]byte{}
+		}
+	}
+}
 
-				// Check if we have 2/3+ precommits
-				if has2_3, blockHash := be.State.HasTwoThirdsPrecommits(); has2_3 {
-					if blockHash == proposedBlock.Header.Hash {
-						fmt.Printf("[BFT] Reached 2/3+ precommits for block %x\n", blockHash[:4])
-						commitReached = true
-						break
-					}
-				}
-			}
+// handleVote checks a network vote for double-signing and adds it to the consensus state
+func (be *BFTEngine) handleVote(height uint64, vote *bft.Vote) {
+	if vote.Height != height {
+		return
+	}
 
-		case <-time.After(100 * time.Millisecond):
-			// Check periodically
-			if has2_3, blockHash := be.State.HasTwoThirdsPrecommits(); has2_3 {
-				if blockHash == proposedBlock.Header.Hash {
-					commitReached = true
-					break
-				}
-			}
-		}
+	if be.detectDoubleSign(vote, be.voteCache) {
+		fmt.Printf("[BFT] ⚠️  DOUBLE-SIGN DETECTED from validator %x - evidence submitted\n", vote.ValidatorAddress[:4])
+		return
 	}
 
-	if !commitReached {
-		return nil, fmt.Errorf("failed to reach 2/3+ precommits")
+	be.walWrite(WALRecord{Type: WALRecordVote, Height: vote.Height, Round: vote.Round, Vote: vote}, false)
+	if err := be.State.AddVote(vote); err != nil {
+		fmt.Printf("[BFT] Warning: Invalid %s: %v\n", vote.Type, err)
 	}
+}
+
+// commitBlock finalizes the block with ID blockID at height with the precommits of round
+func (be *BFTEngine) commitBlock(height uint64, round int32, blockID [32]byte) (*types.Block, error) {
+	pb := be.proposalBlocks[blockID]
 
 	// Phase 4: Commit (Block Finalized!)
-	be.State.EnterCommit(height, 0)
-	fmt.Printf("[BFT] ✅ Block %d COMMITTED (finalized with 2/3+ votes)\n", height)
+	be.State.EnterCommit(height, round)
+	be.walStep(height, round)
+	fmt.Printf("[BFT] ✅ Block %d COMMITTED in round %d (finalized with 2/3+ votes)\n", height, round)
+
+	// Keep the precommits as the block's commit certificate
+	commit, err := be.State.MakeCommitAt(round, blockID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build commit certificate: %w", err)
+	}
+	commit.PartsTotal, commit.PartsRoot = pb.parts.Total, pb.parts.Root
+	committedBlock := *pb.block
+	committedBlock.Commit = commit
+	be.recordCommit(commit)
 
 	// Mark block as finalized (irreversible)
 	if be.MarkFinalized != nil {
-		if err := be.MarkFinalized(height, proposedBlock.Header.Hash); err != nil {
+		if err := be.MarkFinalized(height, blockID, commit); err != nil {
 			fmt.Printf("[BFT] Warning: Failed to mark block as finalized: %v\n", err)
 		}
 	}
 
-	// Finalize commit and prepare for next height
+	be.walWrite(WALRecord{Type: WALRecordEndHeight, Height: height, Round: round}, true)
+
+	// Finalize commit and prepare for next height (clears locks)
 	be.State.FinalizeCommit(height)
+	be.proposalBlocks = nil
+	be.resumeRound = 0
 
 	// Move proposer to next validator (round-robin)
 	be.Validators.IncrementProposerPriority(1)
 
-	return proposedBlock, nil
+	return &committedBlock, nil
+}
+
+// clock returns the configured time source
+func (be *BFTEngine) clock() Clock {
+	if be.Clock == nil {
+		return SystemClock{}
+	}
+	return be.Clock
+}
+
+// idle notifies OnIdle, if set, that the engine is about to block
+func (be *BFTEngine) idle() {
+	if be.OnIdle != nil {
+		be.OnIdle()
+	}
+}
+
+// signVote signs vote through the sign guard, if configured
+func (be *BFTEngine) signVote(vote *bft.Vote) error {
+	if be.SignGuard == nil {
+		vote.Sign(be.ValidatorPrivKey)
+		return nil
+	}
+	return be.SignGuard.SignVote(vote)
+}
+
+// signProposal signs proposal through the sign guard, if configured
+func (be *BFTEngine) signProposal(proposal *bft.Proposal) error {
+	if be.SignGuard == nil {
+		proposal.Sign(be.ValidatorPrivKey)
+		return nil
+	}
+	return be.SignGuard.SignProposal(proposal)
+}
+
+// walWrite appends rec to the WAL if one is configured
+func (be *BFTEngine) walWrite(rec WALRecord, sync bool) error {
+	if be.WAL == nil {
+		return nil
+	}
+	if err := be.WAL.Write(rec, sync); err != nil {
+		fmt.Printf("[BFT] Warning: WAL write failed: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// walStep durably records the current step together with the lock state
+func (be *BFTEngine) walStep(height uint64, round int32) {
+	_, _, step := be.State.GetState()
+	lockedRound, lockedBlock := be.State.GetLock()
+	validRound, validBlock := be.State.GetValid()
+
+	be.walWrite(WALRecord{
+		Type:        WALRecordStep,
+		Height:      height,
+		Round:       round,
+		Step:        step,
+		LockedRound: lockedRound,
+		LockedBlock: lockedBlock,
+		ValidRound:  validRound,
+		ValidBlock:  validBlock,
+	}, true)
+}
+
+// ReplayWAL restores round, step, lock, proposal blocks and votes of the current
+// height from the WAL. Consensus then resumes in the round after the restored one,
+// so nothing already signed is signed again.
+func (be *BFTEngine) ReplayWAL() error {
+	if be.WAL == nil {
+		return nil
+	}
+
+	records, err := be.WAL.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	height, _, _ := be.State.GetState()
+	restored := false
+	for _, rec := range records {
+		if rec.Height != height {
+			continue
+		}
+
+		switch rec.Type {
+		case WALRecordStep:
+			be.State.RestoreRoundState(rec.Round, rec.Step, rec.LockedRound, rec.LockedBlock, rec.ValidRound, rec.ValidBlock)
+			restored = true
+		case WALRecordProposal:
+			if rec.Block != nil && rec.Proposal != nil {
+				if be.proposalBlocks == nil {
+					be.proposalBlocks = make(map[[32]byte]*proposalBlock)
+				}
+				be.proposalBlocks[rec.Proposal.BlockHash] = &proposalBlock{block: rec.Block, parts: rec.Proposal.BlockParts}
+			}
+		case WALRecordOwnVote, WALRecordVote:
+			if rec.Vote != nil {
+				be.State.AddVote(rec.Vote)
+			}
+		}
+	}
+
+	if restored {
+		_, round, step := be.State.GetState()
+		lockedRound, _ := be.State.GetLock()
+		be.resumeRound = round + 1
+		fmt.Printf("[WAL] Restored height %d round %d step %d (locked round %d), resuming at round %d\n",
+			height, round, step, lockedRound, be.resumeRound)
+	}
+	return nil
+}
+
+// SetValidatorSet switches to a new validator set from the next height on
+// Must be called between heights (e.g. after adding an epoch boundary block).
+func (be *BFTEngine) SetValidatorSet(validators *bft.ValidatorSet) {
+	be.Validators = validators
+	be.State.SetValidators(validators)
+
+	be.ValidatorIndex = -1
+	for i, val := range validators.Validators {
+		if val.Address == be.ValidatorAddress {
+			be.ValidatorIndex = int32(i)
+			break
+		}
+	}
+	if be.ValidatorIndex == -1 {
+		fmt.Printf("[BFT] Not in the validator set (%d validators): following only\n", validators.Size())
+	}
+}
+
+// Stop aborts a running consensus height
+func (be *BFTEngine) Stop() {
+	be.stopOnce.Do(func() { close(be.quit) })
+}
+
+// recordCommit caches a commit certificate, dropping the oldest beyond maxCachedCommits
+func (be *BFTEngine) recordCommit(commit *types.Commit) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	be.commits[commit.Height] = commit
+	if commit.Height > maxCachedCommits {
+		delete(be.commits, commit.Height-maxCachedCommits)
+	}
+}
+
+// GetCommit returns a cached commit certificate for height, or nil
+func (be *BFTEngine) GetCommit(height uint64) *types.Commit {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+	return be.commits[height]
 }
 
 // createBlock creates a block proposal (using existing PoW + Sorting logic)
 func (be *BFTEngine) createBlock(height uint64, txs []types.Transaction) (*types.Block, error) {
-	// This is a simplified version - in full implementation, this would call
-	// the existing MineBlock function or a modified version without PoW difficulty
-
-	// For now, use the existing sorting-based block creation
-	// Get previous block header from blockchain (TODO: pass as parameter)
-	prevBlock := types.BlockHeader{
-		Height: height - 1,
-		Hash:   [32]byte{}, // TODO: Get from blockchain
+	if be.GetTip == nil {
+		return nil, fmt.Errorf("no chain tip source configured")
+	}
+	prevBlock := be.GetTip()
+	if height != prevBlock.Height+1 {
+		return nil, fmt.Errorf("cannot propose height %d on tip %d", height, prevBlock.Height)
 	}
 
 	difficulty := uint64(1) // Minimal PoW for block creation
@@ -338,11 +807,23 @@ func (be *BFTEngine) createBlock(height uint64, txs []types.Transaction) (*types
 	var minerPubKey [32]byte
 	copy(minerPubKey[:], be.ValidatorPrivKey.Public().(ed25519.PublicKey))
 
-	block, err := MineBlock(txs, prevBlock, difficulty, stopChan, minerPubKey, be.ValidatorPrivKey)
+	block, err := mineBlock(txs, prevBlock, difficulty, stopChan, minerPubKey, be.ValidatorPrivKey, be.clock().Now)
 	if err != nil {
 		return nil, err
 	}
 
+	// Include pending double-sign evidence (not part of the PoW hash, like MerkleRoot)
+	block.Evidence = be.pendingEvidence(height)
+	block.Header.EvidenceRoot = types.CalculateEvidenceRoot(block.Evidence)
+
+	// The parent's commit proves it was committed and tells the chain which validators were live
+	block.LastCommit = be.GetCommit(height - 1)
+	if block.LastCommit == nil && be.LoadCommit != nil {
+		if commit, err := be.LoadCommit(height - 1); err == nil {
+			block.LastCommit = commit
+		}
+	}
+
 	return block, nil
 }
 
@@ -366,12 +847,11 @@ func (be *BFTEngine) ProcessIncomingProposal(proposal *bft.Proposal) {
 	}
 }
 
-// ProcessIncomingBlock handles block data received from the P2P network
-func (be *BFTEngine) ProcessIncomingBlock(block *types.Block) {
-	// Send to block channel for consensus processing
+// ProcessIncomingBlockPart handles proposal block parts received from the P2P network
+func (be *BFTEngine) ProcessIncomingBlockPart(part *bft.BlockPart) {
 	select {
-	case be.BlockChan <- block:
+	case be.PartChan <- part:
 	default:
-		fmt.Printf("[BFT] Warning: Block channel full, dropping block\n")
+		fmt.Printf("[BFT] Warning: Block part channel full, dropping part\n")
 	}
 }
@@ -1,10 +1,11 @@
 package consensus
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
-	"github.com/LICODX/PoSSR-RNRCORE/internal/slashing"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // voteCache stores votes per validator to detect double-signing
@@ -21,28 +22,29 @@ func newVoteCache() *voteCache {
 }
 
 // detectDoubleSign checks if a vote conflicts with a previous vote (double-signing)
+// Conflicting votes become evidence in the pool and are gossiped; the offender
+// is slashed once a block includes the evidence.
 func (be *BFTEngine) detectDoubleSign(vote *bft.Vote, cache *voteCache) bool {
-	var existing *bft.Vote
-	var ok bool
+	// Only signed votes can prove anything (and forged ones must not shadow real ones)
+	val := be.Validators.GetByAddress(vote.ValidatorAddress)
+	if val == nil || !vote.Verify(val.PubKey) {
+		return false
+	}
 
-	// Get existing vote based on type
+	var votes map[[32]byte]*bft.Vote
 	switch vote.Type {
 	case bft.VoteTypePrevote:
-		existing, ok = cache.prevotes[vote.ValidatorAddress]
+		votes = cache.prevotes
 	case bft.VoteTypePrecommit:
-		existing, ok = cache.precommits[vote.ValidatorAddress]
+		votes = cache.precommits
 	default:
 		return false
 	}
 
-	// If no previous vote, cache this one
-	if !ok {
-		switch vote.Type {
-		case bft.VoteTypePrevote:
-			cache.prevotes[vote.ValidatorAddress] = vote
-		case bft.VoteTypePrecommit:
-			cache.precommits[vote.ValidatorAddress] = vote
-		}
+	// Votes from another height/round can't conflict; track the latest one instead
+	existing, ok := votes[vote.ValidatorAddress]
+	if !ok || existing.Height != vote.Height || existing.Round != vote.Round {
+		votes[vote.ValidatorAddress] = vote
 		return false
 	}
 
@@ -53,30 +55,83 @@ func (be *BFTEngine) detectDoubleSign(vote *bft.Vote, cache *voteCache) bool {
 
 	// DOUBLE SIGN DETECTED!
 	// Same height, same round, same type, DIFFERENT block hash
-	if existing.Height == vote.Height && existing.Round == vote.Round {
-		// Submit evidence
-		evidence := slashing.Evidence{
-			Type:             slashing.DoubleSign,
-			ValidatorAddress: vote.ValidatorAddress,
-			Height:           vote.Height,
-			SubmittedAt:      time.Now().Unix(),
-			// Proof would contain both votes serialized
-		}
+	ev := types.NewDoubleSignEvidence(evidenceVote(existing), evidenceVote(vote))
+	be.submitEvidence(&ev)
+	return true
+}
 
-		be.SlashingTracker.SubmitEvidence(evidence)
+// ProcessIncomingEvidence handles evidence received from the P2P network
+func (be *BFTEngine) ProcessIncomingEvidence(ev *types.DoubleSignEvidence) {
+	if be.EvidencePool == nil {
+		return
+	}
+	if err := be.checkEvidence(*ev); err != nil {
+		fmt.Printf("[BFT] Rejected evidence: %v\n", err)
+		return
+	}
+	if _, err := be.EvidencePool.AddEvidence(*ev); err != nil {
+		fmt.Printf("[BFT] Rejected evidence: %v\n", err)
+	}
+}
 
-		// Get validator from set to get stake amount
-		val := be.Validators.GetByAddress(vote.ValidatorAddress)
-		if val != nil {
-			// Slash 100% of stake
-			be.SlashingTracker.Slash(vote.ValidatorAddress, slashing.DoubleSign, evidence, val.VotingPower)
+// submitEvidence adds locally detected evidence to the pool and gossips it
+func (be *BFTEngine) submitEvidence(ev *types.DoubleSignEvidence) {
+	if be.EvidencePool == nil {
+		return
+	}
+	added, err := be.EvidencePool.AddEvidence(*ev)
+	if err != nil {
+		fmt.Printf("[BFT] Warning: Invalid evidence: %v\n", err)
+		return
+	}
+	if added && be.BroadcastEvidence != nil {
+		if err := be.BroadcastEvidence(ev); err != nil {
+			fmt.Printf("[BFT] Warning: Failed to broadcast evidence: %v\n", err)
+		}
+	}
+}
+
+// pendingEvidence returns pool evidence that can be executed in a block at height
+// Evidence the chain rejects (expired, already executed) is dropped from the pool.
+func (be *BFTEngine) pendingEvidence(height uint64) []types.DoubleSignEvidence {
+	if be.EvidencePool == nil {
+		return nil
+	}
 
-			// Remove validator from active set
-			be.Validators.Remove(vote.ValidatorAddress)
+	var evidence []types.DoubleSignEvidence
+	for _, ev := range be.EvidencePool.PendingEvidence(params.MaxEvidencePerBlock) {
+		if be.CheckEvidence != nil {
+			if err := be.CheckEvidence(ev, height); err != nil {
+				be.EvidencePool.RemoveEvidence(ev)
+				continue
+			}
 		}
+		evidence = append(evidence, ev)
+	}
+	return evidence
+}
 
-		return true
+// checkEvidence checks gossiped evidence against the chain (or, without chain access, the validator set)
+func (be *BFTEngine) checkEvidence(ev types.DoubleSignEvidence) error {
+	if be.CheckEvidence != nil && be.GetTip != nil {
+		return be.CheckEvidence(ev, be.GetTip().Height+1)
+	}
+	validator := ev.Validator()
+	if be.Validators.GetByAddress(validator) == nil {
+		return fmt.Errorf("evidence against unknown validator %x", validator[:4])
 	}
+	return ev.Verify()
+}
 
-	return false
+// evidenceVote converts a vote to its evidence form
+func evidenceVote(vote *bft.Vote) types.EvidenceVote {
+	return types.EvidenceVote{
+		Type:             uint8(vote.Type),
+		Height:           vote.Height,
+		Round:            vote.Round,
+		BlockHash:        vote.BlockHash,
+		Timestamp:        vote.Timestamp,
+		ValidatorAddress: vote.ValidatorAddress,
+		Signature:        vote.Signature,
+	}
 }
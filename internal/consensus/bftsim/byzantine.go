@@ -0,0 +1,120 @@
+package bftsim
+
+import (
+	"crypto/sha256"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// Behavior scripts how a validator deviates from the protocol
+// Byzantine validators run the normal engine; their misbehaviour is applied to
+// what they send.
+type Behavior int
+
+const (
+	Honest      Behavior = iota
+	Silent               // Never sends anything
+	Equivocate           // Signs a conflicting second vote for every vote it casts
+	BadProposal          // Proposes blocks that do not extend the chain
+)
+
+func (b Behavior) String() string {
+	switch b {
+	case Honest:
+		return "Honest"
+	case Silent:
+		return "Silent"
+	case Equivocate:
+		return "Equivocate"
+	case BadProposal:
+		return "BadProposal"
+	default:
+		return "Unknown"
+	}
+}
+
+func (n *node) broadcastVote(vote *bft.Vote) error {
+	switch n.behavior {
+	case Silent:
+		return nil
+	case Equivocate:
+		// Every peer gets both votes, so the double-sign can be detected
+		conflict := *vote
+		if vote.BlockHash == [32]byte{} {
+			conflict.BlockHash = sha256.Sum256(vote.SignBytes())
+		} else {
+			conflict.BlockHash = [32]byte{}
+		}
+		conflict.Sign(n.privKey)
+
+		for _, peer := range n.net.nodes {
+			first, second := vote, &conflict
+			if peer.index%2 == 1 {
+				first, second = second, first
+			}
+			n.net.send(n, peer, message{vote: first})
+			n.net.send(n, peer, message{vote: second})
+		}
+		return nil
+	}
+	n.net.broadcast(n, message{vote: vote})
+	return nil
+}
+
+func (n *node) broadcastProposal(proposal *bft.Proposal) error {
+	switch n.behavior {
+	case Silent:
+		return nil
+	case BadProposal:
+		// Swap in a block on a parent nobody has, with its own parts
+		block := n.badBlock(proposal.Height)
+		header, parts, err := bft.NewPartSetFromBlock(block, proposal.Height, proposal.Round)
+		if err != nil {
+			return err
+		}
+		bad := *proposal
+		bad.POLRound = -1
+		bad.BlockHash = bft.BlockID(block.Header, header)
+		bad.BlockParts = header
+		bad.Sign(n.privKey)
+
+		n.net.broadcast(n, message{proposal: &bad})
+		for _, part := range parts {
+			n.net.broadcast(n, message{part: part})
+		}
+		return nil
+	}
+	n.net.broadcast(n, message{proposal: proposal})
+	return nil
+}
+
+func (n *node) broadcastBlockPart(part *bft.BlockPart) error {
+	if n.behavior == Silent || n.behavior == BadProposal {
+		return nil // BadProposal already sent the parts of its own block
+	}
+	n.net.broadcast(n, message{part: part})
+	return nil
+}
+
+func (n *node) broadcastEvidence(ev *types.DoubleSignEvidence) error {
+	if n.behavior == Silent {
+		return nil
+	}
+	n.net.recordEvidence(*ev)
+	n.net.broadcast(n, message{evidence: ev})
+	return nil
+}
+
+// badBlock returns a well-formed block at height that does not extend the chain
+func (n *node) badBlock(height uint64) *types.Block {
+	header := types.BlockHeader{
+		Version:       1,
+		PrevBlockHash: sha256.Sum256(n.tip.Hash[:]),
+		Timestamp:     simEpoch.Add(n.net.now).Unix(),
+		Height:        height,
+		Difficulty:    1,
+	}
+	header.Hash = types.HashBlockHeaderForPoW(header)
+	return &types.Block{Header: header}
+}
@@ -0,0 +1,165 @@
+package bftsim
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// node is one simulated validator
+type node struct {
+	net      *network
+	index    int
+	behavior Behavior
+	address  [32]byte
+	privKey  ed25519.PrivateKey
+	engine   *consensus.BFTEngine
+	tip      types.BlockHeader // Last committed header
+
+	inbox []message // Arrived but not yet taken by the engine
+	timer *timer    // Timeout the engine is currently waiting on
+
+	known map[interface{}]bool // Messages already in sent
+	sent  map[uint64][]message // Messages sent or received per height, resent by gossip
+
+	idle   chan struct{} // Engine is blocked waiting for input
+	done   chan error    // Engine goroutine exited
+	halted bool
+}
+
+// nodeClock is a validator's view of the virtual clock
+type nodeClock struct{ n *node }
+
+func (c nodeClock) Now() time.Time { return simEpoch.Add(c.n.net.now) }
+
+// After schedules a timeout; the engine only ever waits on the latest one
+func (c nodeClock) After(d time.Duration) <-chan time.Time {
+	t := &timer{ch: make(chan time.Time, 1)}
+	c.n.timer = t
+	c.n.net.schedule(d, &event{to: c.n, timer: t})
+	return t.ch
+}
+
+// run drives the engine height after height until it is stopped
+func (n *node) run() {
+	for height := uint64(1); ; height++ {
+		block, err := n.engine.RunConsensusRound(height, nil)
+		if err != nil {
+			n.done <- err
+			return
+		}
+		n.commit(block)
+	}
+}
+
+// wait blocks until the engine is idle again or has exited
+func (n *node) wait() {
+	select {
+	case <-n.idle:
+	case err := <-n.done:
+		n.halted = true
+		if !n.net.stopping {
+			n.net.result.Failures[n.index] = err
+		}
+	}
+}
+
+// pump hands queued messages to the engine, one at a time, while it is waiting for them
+func (n *node) pump() {
+	for !n.halted {
+		i := n.nextMessage()
+		if i < 0 {
+			return
+		}
+		msg := n.inbox[i]
+		n.inbox = append(n.inbox[:i], n.inbox[i+1:]...)
+		n.net.result.Delivered++
+		n.remember(msg)
+
+		switch {
+		case msg.evidence != nil:
+			n.engine.ProcessIncomingEvidence(msg.evidence) // Handled synchronously
+			continue
+		case msg.vote != nil:
+			n.engine.ProcessIncomingVote(msg.vote)
+		case msg.proposal != nil:
+			n.engine.ProcessIncomingProposal(msg.proposal)
+		case msg.part != nil:
+			n.engine.ProcessIncomingBlockPart(msg.part)
+		}
+		n.wait()
+	}
+}
+
+// nextMessage returns the oldest inbox message the engine is reading now, or -1
+// Proposals and block parts are only read while waiting for a proposal or for a
+// block committed without us; they stay queued until then, like in the engine's
+// channel buffers.
+func (n *node) nextMessage() int {
+	_, _, step := n.engine.State.GetState()
+	for i, msg := range n.inbox {
+		if msg.vote != nil || msg.evidence != nil || step == bft.RoundStepPropose || step == bft.RoundStepCommit {
+			return i
+		}
+	}
+	return -1
+}
+
+// remember logs a message an honest validator sent or received, for gossip to resend
+func (n *node) remember(msg message) {
+	if n.net.cfg.GossipInterval == 0 || n.behavior != Honest || msg.evidence != nil {
+		return
+	}
+	var key interface{}
+	var height uint64
+	switch {
+	case msg.vote != nil:
+		key, height = msg.vote, msg.vote.Height
+	case msg.proposal != nil:
+		key, height = msg.proposal, msg.proposal.Height
+	default:
+		key, height = msg.part, msg.part.Height
+	}
+	if n.known[key] {
+		return
+	}
+	n.known[key] = true
+	n.sent[height] = append(n.sent[height], msg)
+}
+
+// commit records a committed block and builds on it
+func (n *node) commit(block *types.Block) {
+	if n.net.stopping {
+		return
+	}
+	n.tip = block.Header
+	n.engine.EvidencePool.MarkCommitted(block.Evidence, block.Header.Height)
+
+	var round int32
+	var hash [32]byte
+	if block.Commit != nil {
+		round, hash = block.Commit.Round, block.Commit.BlockHash
+	}
+	n.net.result.Commits[n.index] = append(n.net.result.Commits[n.index], CommitInfo{
+		Height: block.Header.Height,
+		Round:  round,
+		Hash:   hash,
+		Time:   n.net.now,
+	})
+}
+
+func (n *node) getTip() types.BlockHeader {
+	return n.tip
+}
+
+// checkBlock accepts blocks that extend the committed tip with a valid seal
+func (n *node) checkBlock(block types.Block) error {
+	if block.Header.PrevBlockHash != n.tip.Hash {
+		return fmt.Errorf("block does not extend tip %d", n.tip.Height)
+	}
+	return consensus.VerifyPoWSeal(&block.Header)
+}
@@ -0,0 +1,377 @@
+// Package bftsim runs BFTEngine validators in-process on a simulated network
+// Time is virtual and every random choice (latency, loss) comes from a seeded
+// RNG, and validators take turns processing one message at a time, so a run is
+// reproducible from its Config.
+package bftsim
+
+import (
+	"container/heap"
+	"crypto/ed25519"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// simEpoch is virtual time zero
+var simEpoch = time.Unix(1700000000, 0)
+
+// Partition splits the network between From and Until (virtual time since start)
+// Messages only flow between validators of the same group; unlisted validators are isolated.
+type Partition struct {
+	From, Until time.Duration
+	Groups      [][]int
+}
+
+// group returns the index of the group holding validator i, or -1
+func (p Partition) group(i int) int {
+	for g, members := range p.Groups {
+		for _, m := range members {
+			if m == i {
+				return g
+			}
+		}
+	}
+	return -1
+}
+
+// Config describes a simulated network
+type Config struct {
+	Seed       int64
+	Validators int
+	Heights    uint64        // Run until every honest validator committed this many blocks
+	MaxTime    time.Duration // Virtual time limit (0 = 1 hour)
+
+	Latency    time.Duration // Minimum one-way message delay
+	Jitter     time.Duration // Additional random delay in [0, Jitter)
+	DropRate   float64       // Probability that a message is lost
+	DropUntil  time.Duration // Messages are only lost before this (0 = the whole run)
+	Partitions []Partition
+
+	GossipInterval time.Duration // Honest validators resend what they know of each peer's height this often (0 = never)
+
+	Byzantine map[int]Behavior // Validator index -> behaviour (others are honest)
+}
+
+// CommitInfo is a block committed by one validator
+type CommitInfo struct {
+	Height uint64
+	Round  int32
+	Hash   [32]byte
+	Time   time.Duration // Virtual time of the commit
+}
+
+// Result is the outcome of a simulation run
+type Result struct {
+	Behaviors []Behavior
+	Commits   [][]CommitInfo             // Per validator, in height order
+	Failures  map[int]error              // Validators whose engine stopped with an error
+	Evidence  []types.DoubleSignEvidence // Double-sign evidence gossiped by validators
+	Elapsed   time.Duration              // Virtual time at the end of the run
+	Delivered int                        // Messages handed to engines
+	Dropped   int                        // Messages lost to DropRate or partitions
+}
+
+// CheckSafety returns an error if two honest validators committed different blocks at one height
+func (r *Result) CheckSafety() error {
+	committed := make(map[uint64][32]byte)
+	for i, commits := range r.Commits {
+		if r.Behaviors[i] != Honest {
+			continue
+		}
+		for _, c := range commits {
+			if hash, ok := committed[c.Height]; ok && hash != c.Hash {
+				return fmt.Errorf("validator %d committed %x at height %d, another committed %x", i, c.Hash[:4], c.Height, hash[:4])
+			}
+			committed[c.Height] = c.Hash
+		}
+	}
+	return nil
+}
+
+// CheckLiveness returns an error unless every honest validator committed heights blocks
+func (r *Result) CheckLiveness(heights uint64) error {
+	for i, commits := range r.Commits {
+		if r.Behaviors[i] != Honest || uint64(len(commits)) >= heights {
+			continue
+		}
+		if err := r.Failures[i]; err != nil {
+			return fmt.Errorf("validator %d stopped after %d/%d blocks: %v", i, len(commits), heights, err)
+		}
+		return fmt.Errorf("validator %d committed %d/%d blocks in %v", i, len(commits), heights, r.Elapsed)
+	}
+	return nil
+}
+
+// message is one gossiped consensus message
+type message struct {
+	vote     *bft.Vote
+	proposal *bft.Proposal
+	part     *bft.BlockPart
+	evidence *types.DoubleSignEvidence
+}
+
+// timer is a timeout an engine waits on
+type timer struct {
+	ch chan time.Time
+}
+
+// event is a message arrival or timeout, ordered by (at, seq)
+type event struct {
+	at     time.Duration
+	seq    uint64
+	to     *node
+	msg    *message
+	timer  *timer
+	gossip bool // Retransmission tick
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+// network is a running simulation
+// Only one goroutine runs at a time: the scheduler hands a node an event and
+// waits until its engine blocks again (OnIdle) or exits.
+type network struct {
+	cfg      Config
+	rng      *rand.Rand
+	now      time.Duration
+	seq      uint64
+	queue    eventQueue
+	nodes    []*node
+	result   *Result
+	stopping bool
+}
+
+// Run simulates cfg until every honest validator committed cfg.Heights blocks or MaxTime passes
+func Run(cfg Config) (*Result, error) {
+	if cfg.Validators < 1 {
+		return nil, fmt.Errorf("need at least one validator")
+	}
+	if cfg.Heights == 0 {
+		return nil, fmt.Errorf("heights must be greater than 0")
+	}
+	if cfg.MaxTime == 0 {
+		cfg.MaxTime = time.Hour
+	}
+
+	net := &network{
+		cfg: cfg,
+		rng: rand.New(rand.NewSource(cfg.Seed)),
+		result: &Result{
+			Behaviors: make([]Behavior, cfg.Validators),
+			Commits:   make([][]CommitInfo, cfg.Validators),
+			Failures:  make(map[int]error),
+		},
+	}
+	net.createNodes()
+
+	for _, n := range net.nodes {
+		go n.run()
+		n.wait()
+	}
+	if cfg.GossipInterval > 0 {
+		net.schedule(cfg.GossipInterval, &event{gossip: true})
+	}
+
+	for !net.finished() && net.queue.Len() > 0 {
+		ev := heap.Pop(&net.queue).(*event)
+		if ev.at > cfg.MaxTime {
+			break
+		}
+		net.now = ev.at
+		if ev.gossip {
+			net.gossip()
+			continue
+		}
+
+		n := ev.to
+		if n.halted {
+			continue
+		}
+		if ev.timer != nil {
+			if ev.timer != n.timer {
+				continue // The engine stopped waiting on it
+			}
+			n.timer = nil
+			ev.timer.ch <- simEpoch.Add(net.now)
+			n.wait()
+		} else {
+			n.inbox = append(n.inbox, *ev.msg)
+		}
+		n.pump()
+	}
+
+	net.shutdown()
+	net.result.Elapsed = net.now
+	return net.result, nil
+}
+
+// createNodes derives validator keys from the seed and wires an engine per validator
+func (net *network) createNodes() {
+	net.nodes = make([]*node, net.cfg.Validators)
+	vals := make([]bft.Validator, net.cfg.Validators)
+	for i := range net.nodes {
+		seed := make([]byte, ed25519.SeedSize)
+		net.rng.Read(seed)
+		privKey := ed25519.NewKeyFromSeed(seed)
+
+		n := &node{
+			net:      net,
+			index:    i,
+			behavior: net.cfg.Byzantine[i],
+			privKey:  privKey,
+			idle:     make(chan struct{}),
+			done:     make(chan error, 1),
+			known:    make(map[interface{}]bool),
+			sent:     make(map[uint64][]message),
+		}
+		copy(n.address[:], privKey.Public().(ed25519.PublicKey))
+		vals[i] = bft.Validator{Address: n.address, PubKey: privKey.Public().(ed25519.PublicKey), VotingPower: 1}
+		net.result.Behaviors[i] = n.behavior
+		net.nodes[i] = n
+	}
+
+	genesis := types.BlockHeader{Version: 1, Timestamp: simEpoch.Unix(), Difficulty: 1}
+	genesis.Hash = types.HashBlockHeaderForPoW(genesis)
+
+	for _, n := range net.nodes {
+		// Every engine mutates its own set (proposer rotation)
+		set := make([]*bft.Validator, len(vals))
+		for j := range vals {
+			v := vals[j]
+			set[j] = &v
+		}
+
+		n.tip = genesis
+		n.engine = consensus.NewBFTEngine(1, bft.NewValidatorSet(set), n.address, n.privKey)
+		n.engine.Clock = nodeClock{n}
+		n.engine.OnIdle = func() { n.idle <- struct{}{} }
+		n.engine.GetTip = n.getTip
+		n.engine.CheckBlock = n.checkBlock
+		n.engine.BroadcastVote = n.broadcastVote
+		n.engine.BroadcastProposal = n.broadcastProposal
+		n.engine.BroadcastBlockPart = n.broadcastBlockPart
+		n.engine.BroadcastEvidence = n.broadcastEvidence
+	}
+}
+
+// finished reports whether every honest validator that is still running reached cfg.Heights
+func (net *network) finished() bool {
+	for _, n := range net.nodes {
+		if n.behavior == Honest && !n.halted && uint64(len(net.result.Commits[n.index])) < net.cfg.Heights {
+			return false
+		}
+	}
+	return true
+}
+
+// schedule queues ev to happen after delay
+func (net *network) schedule(delay time.Duration, ev *event) {
+	ev.at = net.now + delay
+	ev.seq = net.seq
+	net.seq++
+	heap.Push(&net.queue, ev)
+}
+
+// send gossips msg from one validator to another, subject to partitions, loss and latency
+func (net *network) send(from, to *node, msg message) {
+	if net.stopping || from == to {
+		return
+	}
+	if !net.connected(from.index, to.index) || (net.lossy() && net.rng.Float64() < net.cfg.DropRate) {
+		net.result.Dropped++
+		return
+	}
+
+	delay := net.cfg.Latency
+	if net.cfg.Jitter > 0 {
+		delay += time.Duration(net.rng.Int63n(int64(net.cfg.Jitter)))
+	}
+	net.schedule(delay, &event{to: to, msg: &msg})
+}
+
+// lossy reports whether DropRate applies now
+func (net *network) lossy() bool {
+	return net.cfg.DropUntil == 0 || net.now < net.cfg.DropUntil
+}
+
+// broadcast sends msg from one validator to all others
+func (net *network) broadcast(from *node, msg message) {
+	from.remember(msg)
+	for _, to := range net.nodes {
+		net.send(from, to, msg)
+	}
+}
+
+// gossip has every honest validator resend the messages it knows of each peer's current height
+func (net *network) gossip() {
+	for _, from := range net.nodes {
+		if from.halted || from.behavior != Honest {
+			continue
+		}
+		for _, to := range net.nodes {
+			if to.halted {
+				continue
+			}
+			height, _, _ := to.engine.State.GetState()
+			for _, msg := range from.sent[height] {
+				net.send(from, to, msg)
+			}
+		}
+	}
+	net.schedule(net.cfg.GossipInterval, &event{gossip: true})
+}
+
+// connected reports whether validators a and b can reach each other now
+func (net *network) connected(a, b int) bool {
+	for _, p := range net.cfg.Partitions {
+		if net.now < p.From || net.now >= p.Until {
+			continue
+		}
+		if g := p.group(a); g < 0 || g != p.group(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordEvidence adds ev to the result unless another validator already reported it
+func (net *network) recordEvidence(ev types.DoubleSignEvidence) {
+	hash := ev.Hash()
+	for _, seen := range net.result.Evidence {
+		if seen.Hash() == hash {
+			return
+		}
+	}
+	net.result.Evidence = append(net.result.Evidence, ev)
+}
+
+// shutdown stops every engine, one at a time, and waits for it to exit
+func (net *network) shutdown() {
+	net.stopping = true
+	for _, n := range net.nodes {
+		n.engine.Stop()
+		for !n.halted {
+			n.wait()
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package bftsim_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bftsim"
+)
+
+func runSim(t *testing.T, cfg bftsim.Config) *bftsim.Result {
+	t.Helper()
+	result, err := bftsim.Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Liveness needs message delays below the round timeouts
+func TestSimJitteryNetworkIsLive(t *testing.T) {
+	result := runSim(t, bftsim.Config{
+		Seed: 1, Validators: 4, Heights: 5,
+		Latency: 50 * time.Millisecond, Jitter: 800 * time.Millisecond,
+	})
+	if err := result.CheckLiveness(5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Gossip resends what was lost, so every validator catches up once the loss stops
+func TestSimLossyNetworkRecovers(t *testing.T) {
+	result := runSim(t, bftsim.Config{
+		Seed: 2, Validators: 7, Heights: 5, MaxTime: 10 * time.Minute,
+		Latency: 50 * time.Millisecond, Jitter: 200 * time.Millisecond,
+		DropRate: 0.2, DropUntil: 30 * time.Second, GossipInterval: time.Second,
+	})
+	if result.Dropped == 0 {
+		t.Error("no messages were dropped")
+	}
+	if err := result.CheckLiveness(5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimIsReproducibleFromSeed(t *testing.T) {
+	cfg := bftsim.Config{
+		Seed: 42, Validators: 4, Heights: 3,
+		Latency: 10 * time.Millisecond, Jitter: 500 * time.Millisecond, DropRate: 0.1,
+		Byzantine: map[int]bftsim.Behavior{2: bftsim.Equivocate},
+	}
+	first, second := runSim(t, cfg), runSim(t, cfg)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("runs with the same seed differ:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestSimByzantineValidator(t *testing.T) {
+	for _, behavior := range []bftsim.Behavior{bftsim.Silent, bftsim.Equivocate, bftsim.BadProposal} {
+		t.Run(behavior.String(), func(t *testing.T) {
+			result := runSim(t, bftsim.Config{
+				Seed: 7, Validators: 4, Heights: 4,
+				Latency: 20 * time.Millisecond, Jitter: 50 * time.Millisecond,
+				Byzantine: map[int]bftsim.Behavior{0: behavior},
+			})
+			if err := result.CheckLiveness(4); err != nil {
+				t.Fatal(err)
+			}
+
+			switch behavior {
+			case bftsim.Equivocate:
+				if len(result.Evidence) == 0 {
+					t.Error("equivocation was not detected")
+				}
+			case bftsim.Silent, bftsim.BadProposal:
+				// Its turn as proposer must have been skipped
+				skipped := false
+				for _, c := range result.Commits[1] {
+					skipped = skipped || c.Round > 0
+				}
+				if !skipped {
+					t.Errorf("no height needed a second round: %+v", result.Commits[1])
+				}
+			}
+		})
+	}
+}
+
+func TestSimPartitionHeals(t *testing.T) {
+	heal := 20 * time.Second
+	result := runSim(t, bftsim.Config{
+		Seed: 3, Validators: 4, Heights: 2,
+		Latency:    20 * time.Millisecond,
+		Partitions: []bftsim.Partition{{Until: heal, Groups: [][]int{{0, 1}, {2, 3}}}},
+	})
+	if err := result.CheckLiveness(2); err != nil {
+		t.Fatal(err)
+	}
+	for i, commits := range result.Commits {
+		if commits[0].Time < heal {
+			t.Errorf("validator %d committed without a 2/3 majority at %v", i, commits[0].Time)
+		}
+	}
+}
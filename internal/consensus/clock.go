@@ -0,0 +1,16 @@
+package consensus
+
+import "time"
+
+// Clock is the BFT engine's source of time
+// The system clock is used unless another one is configured (e.g. a simulator's virtual clock).
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock reads the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
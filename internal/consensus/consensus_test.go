@@ -1,18 +1,104 @@
 package consensus_test
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus"
-	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/economics"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 func TestConsensusAggregator(t *testing.T) {
+	_, sorterPriv, _ := ed25519.GenerateKey(nil)
+	_, outsiderPriv, _ := ed25519.GenerateKey(nil)
+	minerPub, minerPriv, _ := ed25519.GenerateKey(nil)
+	var sorter, miner [32]byte
+	copy(sorter[:], sorterPriv.Public().(ed25519.PublicKey))
+	copy(miner[:], minerPub)
+
+	prev := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1, VRFSeed: [32]byte{7}}
+	prev.Hash = types.HashBlockHeaderForPoW(prev)
+
 	agg := consensus.NewAggregator()
+	agg.Deadline = 100 * time.Millisecond
+	assignment := economics.AssignShards([][32]byte{sorter}, 10)
+	agg.Eligible = func(slot uint8, nodeID [32]byte) bool { return assignment.IsAssigned(nodeID, int(slot)) }
+	if err := agg.OpenRound(prev); err == nil {
+		t.Fatal("round opened on an unsealed header")
+	}
+	header, err := consensus.SealHeader(prev, 1, nil, miner, minerPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agg.OpenRound(header); err != nil {
+		t.Fatal(err)
+	}
+	seed := header.VRFSeed
+
+	// Slot 0 holds transactions whose ID starts with 0x0-0x1
+	var txs []types.Transaction
+	for i := 0; i < 5; i++ {
+		txs = append(txs, types.Transaction{ID: [32]byte{0x10, byte(i)}})
+	}
+	sorted, root := consensus.SortShard(txs, seed, 0, 6)
+	newProof := func(priv ed25519.PrivateKey, root [32]byte) *consensus.ShardProof {
+		proof := &consensus.ShardProof{Height: 6, SlotID: 0, Seed: seed, Proof: root}
+		proof.Sign(priv)
+		return proof
+	}
+
+	if err := agg.SubmitProof(6, newProof(sorterPriv, root)); err == nil {
+		t.Error("proof without shard data accepted")
+	}
+	if err := agg.SubmitShard(6, newProof(outsiderPriv, root), sorted); err == nil {
+		t.Error("proof from an ineligible sorter accepted")
+	}
+	reversed := make([]types.Transaction, len(sorted))
+	for i := range sorted {
+		reversed[len(sorted)-1-i] = sorted[i]
+	}
+	if err := agg.SubmitShard(6, newProof(sorterPriv, root), reversed); err == nil {
+		t.Error("unsorted shard data accepted")
+	}
+	shard, err := consensus.SolveRound(&header, 0, txs, sorterPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agg.SubmitShard(6, shard.Proof, shard.Txs); err != nil {
+		t.Fatal(err)
+	}
 
-	// TODO: Submit 10 proofs and verify aggregation
-	if !agg.IsComplete(1) {
-		// Expected to be incomplete initially
+	// Nine slots are still missing: wait for the deadline, then fill them empty
+	if _, err := agg.CreateAggregatedBlock(6); err == nil {
+		t.Fatal("incomplete block created before the deadline")
+	}
+	time.Sleep(agg.Deadline)
+	if !agg.Ready(6) {
+		t.Fatal("round not ready after its deadline")
+	}
+	block, err := agg.CreateAggregatedBlock(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := consensus.VerifyPoWSeal(&block.Header); err != nil {
+		t.Errorf("aggregated block lost its seal: %v", err)
+	}
+	if block.Header.VRFSeed != seed || block.Header.PrevBlockHash != prev.Hash {
+		t.Error("aggregated block not linked to its parent")
+	}
+	if block.Header.WinningNodes[0] != sorter || len(block.Shards[0].TxData) != len(txs) {
+		t.Error("slot 0 should carry the sorter's shard")
+	}
+	if block.Header.WinningNodes[1] != ([32]byte{}) || len(block.Shards[1].TxData) != 0 {
+		t.Error("missed slot should be an empty shard without a winner")
 	}
 }
 
@@ -27,7 +113,7 @@ func TestVoting(t *testing.T) {
 
 func TestSelectAlgorithm(t *testing.T) {
 	seed := [32]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-	algo := utils.SelectAlgorithm(seed)
+	algo := consensus.SelectAlgorithm(seed, 0)
 
 	validAlgorithms := map[string]bool{
 		"QUICK_SORT": true,
@@ -42,3 +128,347 @@ func TestSelectAlgorithm(t *testing.T) {
 		t.Errorf("Invalid algorithm selected: %s", algo)
 	}
 }
+
+func TestPoWEngineSeal(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	var engine consensus.Engine = consensus.NewPoWEngine(4, priv, func() types.BlockHeader { return tip })
+	if err := engine.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.RunConsensusRound(7, nil); err == nil {
+		t.Error("mining a height that does not extend the tip should fail")
+	}
+
+	block, err := engine.RunConsensusRound(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ValidateBlockHeader(&block.Header); err != nil {
+		t.Fatalf("mined header rejected: %v", err)
+	}
+
+	if block.Header.Version != types.HeaderVersionDataAvailability {
+		t.Errorf("mined header version %d, expected %d", block.Header.Version, types.HeaderVersionDataAvailability)
+	}
+
+	// Changing the seed must break the VRF seal
+	forged := block.Header
+	forged.VRFSeed[0] ^= 0xff
+	if err := engine.VerifySeal(&forged); err == nil {
+		t.Error("forged VRF seed accepted")
+	}
+
+	// Signature-derived seeds may not be replayed after the ECVRF fork
+	legacy := types.BlockHeader{Version: types.HeaderVersionLegacyVRF, PrevBlockHash: tip.Hash, Height: 6, Timestamp: tip.Timestamp + 1, Difficulty: 1}
+	legacy.Hash = types.HashBlockHeaderForPoW(legacy)
+	copy(legacy.MinerPubKey[:], priv.Public().(ed25519.PublicKey))
+	copy(legacy.MinerSignature[:], ed25519.Sign(priv, legacy.Hash[:]))
+	legacy.VRFSeed = sha256.Sum256(legacy.MinerSignature[:])
+	if err := engine.VerifySeal(&legacy); err == nil {
+		t.Error("legacy header accepted after the ECVRF fork")
+	}
+}
+
+func TestHeaderVersionActivation(t *testing.T) {
+	defer func(ecvrf, da uint64) {
+		types.ECVRFActivationHeight, types.DataAvailabilityActivationHeight = ecvrf, da
+	}(types.ECVRFActivationHeight, types.DataAvailabilityActivationHeight)
+	types.ECVRFActivationHeight, types.DataAvailabilityActivationHeight = 7, 8
+
+	_, priv, _ := ed25519.GenerateKey(nil)
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+	engine := consensus.NewPoWEngine(1, priv, func() types.BlockHeader { return tip })
+
+	// Each height mines and accepts only its scheduled version
+	for height, want := range map[uint64]uint32{6: types.HeaderVersionLegacyVRF, 7: types.HeaderVersionECVRF, 8: types.HeaderVersionDataAvailability} {
+		tip.Height = height - 1
+		tip.Hash = types.HashBlockHeaderForPoW(tip)
+		block, err := engine.RunConsensusRound(height, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.Header.Version != want {
+			t.Errorf("mined version %d at height %d, expected %d", block.Header.Version, height, want)
+		}
+		if err := engine.VerifySeal(&block.Header); err != nil {
+			t.Errorf("height %d header rejected: %v", height, err)
+		}
+
+		replayed := block.Header
+		replayed.Height++
+		replayed.Hash = types.HashBlockHeaderForPoW(replayed)
+		if err := consensus.VerifyPoWSeal(&replayed); height < 8 && err == nil {
+			t.Errorf("version %d header accepted at height %d", want, replayed.Height)
+		}
+	}
+}
+
+func TestBFTPrevotesNilOnInvalidProposal(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var addr [32]byte
+	copy(addr[:], pub)
+	valSet := bft.NewValidatorSet([]*bft.Validator{{Address: addr, PubKey: pub, VotingPower: 1}})
+
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	engine := consensus.NewBFTEngine(6, valSet, addr, priv)
+	engine.GetTip = func() types.BlockHeader { return tip }
+	engine.CheckBlock = func(types.Block) error { return fmt.Errorf("bad state transition") }
+
+	// Every round prevotes nil, so the height never commits until stopped
+	time.AfterFunc(50*time.Millisecond, engine.Stop)
+	if _, err := engine.RunConsensusRound(6, nil); err == nil {
+		t.Fatal("invalid proposal was committed")
+	}
+	if _, round, _ := engine.State.GetState(); round == 0 {
+		t.Error("expected consensus to move past round 0")
+	}
+
+	// A valid proposal built on the tip commits with a certificate
+	engine = consensus.NewBFTEngine(6, valSet, addr, priv)
+	engine.GetTip = func() types.BlockHeader { return tip }
+	engine.CheckBlock = func(types.Block) error { return nil }
+
+	block, err := engine.RunConsensusRound(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Header.PrevBlockHash != tip.Hash || block.Commit == nil {
+		t.Error("committed block not built on the tip or missing its commit")
+	}
+}
+
+func TestBFTProposalPropagation(t *testing.T) {
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	var vals []bft.Validator
+	keys := make(map[[32]byte]ed25519.PrivateKey)
+	for i := 0; i < 2; i++ {
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		var addr [32]byte
+		copy(addr[:], pub)
+		vals = append(vals, bft.Validator{Address: addr, PubKey: pub, VotingPower: 1})
+		keys[addr] = priv
+	}
+
+	// Two validators wired to each other; each needs the other's votes to commit
+	engines := make([]*consensus.BFTEngine, 2)
+	for i := range engines {
+		set := make([]*bft.Validator, len(vals))
+		for j := range vals {
+			v := vals[j]
+			set[j] = &v
+		}
+		engines[i] = consensus.NewBFTEngine(6, bft.NewValidatorSet(set), vals[i].Address, keys[vals[i].Address])
+		engines[i].GetTip = func() types.BlockHeader { return tip }
+		engines[i].CheckBlock = func(types.Block) error { return nil }
+	}
+	for i, e := range engines {
+		peer := engines[1-i]
+		e.BroadcastVote = func(v *bft.Vote) error { peer.ProcessIncomingVote(v); return nil }
+		e.BroadcastProposal = func(p *bft.Proposal) error { peer.ProcessIncomingProposal(p); return nil }
+		e.BroadcastBlockPart = func(p *bft.BlockPart) error { peer.ProcessIncomingBlockPart(p); return nil }
+	}
+
+	results := make(chan *types.Block, 2)
+	for _, e := range engines {
+		go func(e *consensus.BFTEngine) {
+			block, err := e.RunConsensusRound(6, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- block
+		}(e)
+	}
+
+	var hashes [][32]byte
+	for i := 0; i < 2; i++ {
+		select {
+		case block := <-results:
+			if block == nil {
+				t.FailNow()
+			}
+			hashes = append(hashes, block.Header.Hash)
+		case <-time.After(10 * time.Second):
+			for _, e := range engines {
+				e.Stop()
+			}
+			t.Fatal("validators did not commit")
+		}
+	}
+	if hashes[0] != hashes[1] {
+		t.Error("validators committed different blocks")
+	}
+}
+
+func TestBFTWALRecovery(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var addr [32]byte
+	copy(addr[:], pub)
+	newSet := func() *bft.ValidatorSet {
+		return bft.NewValidatorSet([]*bft.Validator{{Address: addr, PubKey: pub, VotingPower: 1}})
+	}
+
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	// Crash in round 2 after locking on a block and prevoting it
+	path := filepath.Join(t.TempDir(), "consensus.wal")
+	wal, err := consensus.OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := [32]byte{0x10}
+	prevote := &bft.Vote{Type: bft.VoteTypePrevote, Height: 6, Round: 2, BlockHash: locked, ValidatorAddress: addr}
+	prevote.Sign(priv)
+	wal.Write(consensus.WALRecord{Type: consensus.WALRecordStep, Height: 6, Round: 2, Step: bft.RoundStepPrecommit,
+		LockedRound: 2, LockedBlock: locked, ValidRound: 2, ValidBlock: locked}, true)
+	wal.Write(consensus.WALRecord{Type: consensus.WALRecordOwnVote, Height: 6, Round: 2, Vote: prevote}, true)
+	wal.Close()
+
+	// A torn write at the tail must not hide the records before it
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.Write([]byte{0xde, 0xad, 0xbe})
+	f.Close()
+
+	wal, err = consensus.OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+
+	engine := consensus.NewBFTEngine(6, newSet(), addr, priv)
+	engine.GetTip = func() types.BlockHeader { return tip }
+	engine.CheckBlock = func(types.Block) error { return nil }
+	engine.WAL = wal
+	if err := consensus.NewBFTAdapter(engine).Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, round, _ := engine.State.GetState(); round != 2 {
+		t.Fatalf("restored round %d, expected 2", round)
+	}
+	if lockedRound, lockedBlock := engine.State.GetLock(); lockedRound != 2 || lockedBlock != locked {
+		t.Fatal("lock not restored")
+	}
+
+	// Round 3 may not sign for a new block while locked; round 4 commits after the nil polka unlocks
+	block, err := engine.RunConsensusRound(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Commit.Round != 4 {
+		t.Errorf("committed in round %d, expected 4", block.Commit.Round)
+	}
+}
+
+func TestBFTGossipedEvidenceIsIncluded(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	badPub, badPriv, _ := ed25519.GenerateKey(nil)
+	var addr, badAddr [32]byte
+	copy(addr[:], pub)
+	copy(badAddr[:], badPub)
+	valSet := bft.NewValidatorSet([]*bft.Validator{
+		{Address: addr, PubKey: pub, VotingPower: 10},
+		{Address: badAddr, PubKey: badPub, VotingPower: 1},
+	})
+
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	engine := consensus.NewBFTEngine(6, valSet, addr, priv)
+	engine.GetTip = func() types.BlockHeader { return tip }
+	engine.CheckBlock = func(types.Block) error { return nil }
+
+	// The second validator prevoted for two different blocks at height 5
+	var votes [2]types.EvidenceVote
+	for i, hash := range [][32]byte{{1}, {2}} {
+		vote := &bft.Vote{Type: bft.VoteTypePrevote, Height: 5, BlockHash: hash, Timestamp: time.Now().Unix(), ValidatorAddress: badAddr}
+		vote.Sign(badPriv)
+		votes[i] = types.EvidenceVote{
+			Type: uint8(vote.Type), Height: vote.Height, BlockHash: vote.BlockHash,
+			Timestamp: vote.Timestamp, ValidatorAddress: badAddr, Signature: vote.Signature,
+		}
+	}
+	ev := types.NewDoubleSignEvidence(votes[1], votes[0])
+
+	forged := ev
+	forged.Vote2.Signature[0] ^= 0xff
+	engine.ProcessIncomingEvidence(&forged)
+	engine.ProcessIncomingEvidence(&ev)
+	if engine.EvidencePool.Size() != 1 {
+		t.Fatalf("expected only the valid evidence in the pool, got %d", engine.EvidencePool.Size())
+	}
+
+	block, err := engine.RunConsensusRound(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Evidence) != 1 || block.Evidence[0].Hash() != ev.Hash() {
+		t.Fatalf("block should carry the evidence, got %d items", len(block.Evidence))
+	}
+	if block.Header.EvidenceRoot != types.CalculateEvidenceRoot(block.Evidence) {
+		t.Error("evidence root does not commit to the evidence")
+	}
+}
+
+func TestShardRaceWinners(t *testing.T) {
+	_, minerPriv, _ := ed25519.GenerateKey(nil)
+	_, sorterPriv, _ := ed25519.GenerateKey(nil)
+	tip := types.BlockHeader{Version: 1, Height: 5, Timestamp: time.Now().Unix() - 10, Difficulty: 1}
+	tip.Hash = types.HashBlockHeaderForPoW(tip)
+
+	// A second node sorts every revealed shard; a cheater submits a wrong root
+	agg := consensus.NewAggregator()
+	engine := consensus.NewPoWEngine(4, minerPriv, func() types.BlockHeader { return tip })
+	engine.Race = &consensus.Race{
+		Aggregator: agg,
+		Window:     20 * time.Millisecond,
+		Publish: func(msg *consensus.RaceMessage) error {
+			proof, err := consensus.SolveRaceTask(msg.Task, sorterPriv)
+			if err != nil {
+				return err
+			}
+			forged := *proof
+			forged.Proof[0] ^= 0xff
+			forged.Sign(sorterPriv)
+			if err := agg.SubmitProof(forged.Height, &forged); err == nil {
+				t.Error("proof with a wrong root accepted")
+			}
+			return agg.SubmitProof(proof.Height, proof)
+		},
+	}
+
+	block, err := engine.RunConsensusRound(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var miner, sorter [32]byte
+	copy(miner[:], minerPriv.Public().(ed25519.PublicKey))
+	copy(sorter[:], sorterPriv.Public().(ed25519.PublicKey))
+	for slot := uint8(0); slot < 10; slot++ {
+		mine := consensus.BlockShardProof(block, slot)
+		mine.NodeID = miner
+		theirs := consensus.BlockShardProof(block, slot)
+		theirs.NodeID = sorter
+		expected := miner
+		if a, b := theirs.Ticket(), mine.Ticket(); bytes.Compare(a[:], b[:]) < 0 {
+			expected = sorter
+		}
+
+		if block.Header.WinningNodes[slot] != expected {
+			t.Errorf("slot %d: winner is not the lowest ticket", slot)
+		}
+		if !consensus.BlockShardProof(block, slot).Verify() {
+			t.Errorf("slot %d: recorded winner signature does not verify", slot)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sync"
 	"time"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/mempool"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
@@ -17,6 +19,100 @@ import (
 // SECURITY: Now uses true VRF (Signature of PoW Hash) to prevent entropy prediction
 func MineBlock(txs []types.Transaction, prevBlock types.BlockHeader, difficulty uint64, stopChan chan struct{},
 	minerPubKey [32]byte, minerPrivKey ed25519.PrivateKey) (*types.Block, error) {
+	return mineBlock(txs, prevBlock, difficulty, stopChan, minerPubKey, minerPrivKey, time.Now)
+}
+
+// SealHeader mines the header after prevBlock and derives its VRF seed, leaving the shards to fill
+// Aggregation rounds use it: sorters can only start once the seed is known.
+func SealHeader(prevBlock types.BlockHeader, difficulty uint64, stopChan chan struct{},
+	minerPubKey [32]byte, minerPrivKey ed25519.PrivateKey) (types.BlockHeader, error) {
+	return sealHeader(prevBlock, difficulty, stopChan, minerPubKey, minerPrivKey, time.Now)
+}
+
+// mineBlock is MineBlock with header timestamps taken from now
+func mineBlock(txs []types.Transaction, prevBlock types.BlockHeader, difficulty uint64, stopChan chan struct{},
+	minerPubKey [32]byte, minerPrivKey ed25519.PrivateKey, now func() time.Time) (*types.Block, error) {
+	header, err := sealHeader(prevBlock, difficulty, stopChan, minerPubKey, minerPrivKey, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 6. NOW select algorithm (post-mining, determined by signature entropy)
+	seed := header.VRFSeed
+	algo := SelectAlgorithm(seed, header.Height)
+	fmt.Printf("  [VRF] Signed Block Seed: %x... (Algo: %s)\n", seed[:4], algo)
+
+	// 7. Shard the mempool
+	shardingMgr := mempool.NewShardingManager()
+	for _, tx := range txs {
+		shardingMgr.AddTransaction(tx)
+	}
+
+	// 8. Run Sorting Race in PARALLEL (10 Shards)
+	var wg sync.WaitGroup
+	var shardResults [10]types.ShardData
+	var shardRoots [10][32]byte
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(shardID int) {
+			defer wg.Done()
+
+			shardTxs := shardingMgr.GetSlot(uint8(shardID))
+
+			// Run sorting with DERIVED algorithm (each shard uses a unique seed variation)
+			sorted, root := StartRaceSimplified(shardTxs, ShardSeed(seed, uint8(shardID)), algo)
+
+			// The miner enters every slot; a network race may hand slots to faster sorters
+			proof := &ShardProof{Height: header.Height, SlotID: uint8(shardID), Seed: seed, Proof: root}
+			proof.Sign(minerPrivKey)
+
+			shardResults[shardID] = types.ShardData{
+				NodeID:    proof.NodeID,
+				TxData:    sorted,
+				ShardRoot: root,
+				Signature: proof.Signature,
+			}
+			shardRoots[shardID] = root
+		}(i)
+	}
+	wg.Wait()
+
+	// 9. Calculate Global Merkle Root from 10 Shard Roots
+	var allRoots [][32]byte
+	for _, r := range shardRoots {
+		allRoots = append(allRoots, r)
+	}
+	globalMerkleRoot := utils.CalculateMerkleRoot(allRoots)
+
+	// 10. Update header with the sorting results
+	header.MerkleRoot = globalMerkleRoot
+	header.ShardRoots = shardRoots // Distributed Validation Support
+	for i := range header.WinningNodes {
+		header.WinningNodes[i] = minerPubKey
+	}
+	// Hash was already set during PoW
+
+	// 11. Construct Full Block
+	block := &types.Block{
+		Header: header,
+		Shards: shardResults,
+	}
+
+	// 12. Commit to the erasure-coded shard bodies (availability sampling)
+	if header.Version >= types.HeaderVersionDataAvailability {
+		if err := availability.Commit(block); err != nil {
+			return nil, fmt.Errorf("data commitment failed: %w", err)
+		}
+	}
+	return block, nil
+}
+
+// sealHeader mines the header after prevBlock and derives its VRF seed
+// The returned header carries the PoW hash, seed and miner identity but no
+// shards yet: the seed only exists once the PoW is found.
+func sealHeader(prevBlock types.BlockHeader, difficulty uint64, stopChan chan struct{},
+	minerPubKey [32]byte, minerPrivKey ed25519.PrivateKey, now func() time.Time) (types.BlockHeader, error) {
 	// 1. Prepare base data
 	var nonce uint64 = 0
 	// target := big.NewInt(0).SetUint64(difficulty)
@@ -34,16 +130,16 @@ func MineBlock(txs []types.Transaction, prevBlock types.BlockHeader, difficulty
 		// Check for interrupt
 		select {
 		case <-stopChan:
-			return nil, fmt.Errorf("mining interrupted")
+			return types.BlockHeader{}, fmt.Errorf("mining interrupted")
 		default:
 		}
 
 		// 2. Create candidate header (without algorithm/Merkle yet)
 		header := types.BlockHeader{
-			Version:       1,
+			Version:       types.HeaderVersionAt(prevBlock.Height + 1),
 			PrevBlockHash: prevBlock.Hash,
 			MerkleRoot:    [32]byte{}, // Will be filled after algorithm selection
-			Timestamp:     time.Now().Unix(),
+			Timestamp:     now().Unix(),
 			Height:        prevBlock.Height + 1,
 			Nonce:         nonce,
 			Difficulty:    difficulty,
@@ -63,72 +159,24 @@ func MineBlock(txs []types.Transaction, prevBlock types.BlockHeader, difficulty
 			// PoW SUCCESS! Block hash meets difficulty target
 			header.Hash = blockHash // Store the PoW hash immediately
 
-			// 5. SECURITY: Derive VRF seed from Miner's Signature of the PoW hash
-			// signature = Sign(PrivKey, BlockHash)
-			// Seed = SHA256(signature)
-			// This is a true VRF: unpredictable by others, verifiable by all.
-			signature := ed25519.Sign(minerPrivKey, blockHash[:])
-			seed := sha256.Sum256(signature)
-
-			// 6. NOW select algorithm (post-mining, determined by signature entropy)
-			algo := utils.SelectAlgorithm(seed)
-			fmt.Printf("  [VRF] Signed Block Seed: %x... (Algo: %s)\n", seed[:4], algo)
-
-			// 7. Shard the mempool
-			shardingMgr := mempool.NewShardingManager()
-			for _, tx := range txs {
-				shardingMgr.AddTransaction(tx)
+			// 5. SECURITY: Derive VRF seed from an ECVRF proof (RFC 9381) of the PoW hash
+			// proof = Prove(PrivKey, BlockHash)
+			// Seed = ProofToHash(proof)[:32]
+			// Unpredictable by others, unique for the key and verifiable by all.
+			// Before the ECVRF fork the seed is SHA256(Sign(PrivKey, BlockHash)).
+			if header.Version == types.HeaderVersionLegacyVRF {
+				copy(header.MinerSignature[:], ed25519.Sign(minerPrivKey, blockHash[:]))
+				header.VRFSeed = sha256.Sum256(header.MinerSignature[:])
+			} else {
+				proof, err := utils.VRFProve(minerPrivKey, blockHash[:])
+				if err != nil {
+					return types.BlockHeader{}, fmt.Errorf("VRF prove failed: %w", err)
+				}
+				header.VRFProof = proof
+				header.VRFSeed = ecvrfSeed(proof)
 			}
-
-			// 8. Run Sorting Race in PARALLEL (10 Shards)
-			var wg sync.WaitGroup
-			var shardResults [10]types.ShardData
-			var shardRoots [10][32]byte
-
-			for i := 0; i < 10; i++ {
-				wg.Add(1)
-				go func(shardID int) {
-					defer wg.Done()
-
-					shardTxs := shardingMgr.GetSlot(uint8(shardID))
-
-					// Each shard uses unique seed variation
-					shardSeed := sha256.Sum256(append(seed[:], byte(shardID)))
-
-					// Run sorting with DERIVED algorithm
-					sorted, root := StartRaceSimplified(shardTxs, shardSeed, algo)
-
-					shardResults[shardID] = types.ShardData{
-						NodeID:    [32]byte{byte(shardID)},
-						TxData:    sorted,
-						ShardRoot: root,
-					}
-					shardRoots[shardID] = root
-				}(i)
-			}
-			wg.Wait()
-
-			// 9. Calculate Global Merkle Root from 10 Shard Roots
-			var allRoots [][32]byte
-			for _, r := range shardRoots {
-				allRoots = append(allRoots, r)
-			}
-			globalMerkleRoot := utils.CalculateMerkleRoot(allRoots)
-
-			// 10. Update header with VRF data
-			header.VRFSeed = seed
 			header.MinerPubKey = minerPubKey
-			copy(header.MinerSignature[:], signature)
-			header.MerkleRoot = globalMerkleRoot
-			header.ShardRoots = shardRoots // Distributed Validation Support
-			// Hash was already set at line 53 during PoW
-
-			// 11. Construct Full Block
-			block := &types.Block{
-				Header: header,
-				Shards: shardResults,
-			}
-			return block, nil
+			return header, nil
 		}
 
 		// 7. Increment Nonce and try again
@@ -143,34 +191,21 @@ func StartRaceSimplified(mempool []types.Transaction, seed [32]byte, algo string
 	sortableData := make([]SortableTransaction, len(mempool))
 	for i, tx := range mempool {
 		sortableData[i] = SortableTransaction{
-			Tx:  tx,
+			Tx:  &mempool[i],
 			Key: utils.MixHash(tx.ID, seed),
 		}
 	}
 
 	var sorted []SortableTransaction
-	switch algo {
-	case "QUICK_SORT":
-		sorted = QuickSort(sortableData)
-	case "MERGE_SORT":
-		sorted = MergeSort(sortableData)
-	case "HEAP_SORT":
-		sorted = HeapSort(sortableData)
-	case "RADIX_SORT":
-		sorted = RadixSort(sortableData)
-	case "TIM_SORT":
-		sorted = TimSort(sortableData)
-	case "INTRO_SORT":
-		sorted = IntroSort(sortableData)
-	case "SHELL_SORT":
-		sorted = ShellSort(sortableData)
-	default:
-		sorted = QuickSort(sortableData)
+	if len(sortableData) >= parallelSortThreshold {
+		sorted = ParallelSort(sortableData, algo, runtime.NumCPU())
+	} else {
+		sorted = SortByAlgorithm(sortableData, algo)
 	}
 
 	result := make([]types.Transaction, len(sorted))
 	for i, st := range sorted {
-		result[i] = st.Tx
+		result[i] = *st.Tx
 	}
 
 	var txHashes [][32]byte
@@ -188,5 +223,5 @@ func CalculateBlockHash(h types.BlockHeader) [32]byte {
 }
 
 // Functions moved to pkg/utils/consensus_utils.go to avoid import cycle
-// - SelectAlgorithm
 // - MixHash
+// Algorithm selection lives in the versioned registry (algorithms.go)
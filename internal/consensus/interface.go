@@ -1,6 +1,10 @@
 package consensus
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
@@ -35,6 +39,14 @@ type Engine interface {
 // This ensures that our specific BFT implementation adheres to the formal contract.
 type BFTAdapter struct {
 	engine *BFTEngine
+
+	// LoadCommit returns a persisted commit certificate (e.g. Blockchain.GetCommit)
+	// Consulted when the engine no longer caches the commit for a height.
+	LoadCommit func(height uint64) (*types.Commit, error)
+
+	// ValidatorsAt returns the validator set active at height
+	// Defaults to the engine's current validator set.
+	ValidatorsAt func(height uint64) *bft.ValidatorSet
 }
 
 // Ensure BFTAdapter implements Engine
@@ -45,8 +57,8 @@ func NewBFTAdapter(engine *BFTEngine) *BFTAdapter {
 }
 
 func (a *BFTAdapter) Initialize() error {
-	// Logic to start BFT listeners if not already started
-	return nil
+	// Recover round and lock state from before a crash
+	return a.engine.ReplayWAL()
 }
 
 func (a *BFTAdapter) RunConsensusRound(height uint64, txs []types.Transaction) (*types.Block, error) {
@@ -54,15 +66,37 @@ func (a *BFTAdapter) RunConsensusRound(height uint64, txs []types.Transaction) (
 }
 
 func (a *BFTAdapter) ValidateBlockHeader(header *types.BlockHeader) error {
-	// TODO: Implement header validation logic specific to BFT (validators, round, etc.)
-	return nil
+	if header.Hash == ([32]byte{}) {
+		return fmt.Errorf("header has no block hash")
+	}
+	if header.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+		return fmt.Errorf("block timestamp too far in future")
+	}
+	return a.VerifySeal(header)
 }
 
+// VerifySeal checks the block's commit certificate against the validator set active at its height
 func (a *BFTAdapter) VerifySeal(header *types.BlockHeader) error {
-	// TODO: Verify quorum signatures
-	return nil
+	commit := a.engine.GetCommit(header.Height)
+	if commit == nil && a.LoadCommit != nil {
+		loaded, err := a.LoadCommit(header.Height)
+		if err != nil {
+			return err
+		}
+		commit = loaded
+	}
+	if commit == nil {
+		return fmt.Errorf("no commit certificate for height %d", header.Height)
+	}
+
+	valSet := a.engine.Validators
+	if a.ValidatorsAt != nil {
+		valSet = a.ValidatorsAt(header.Height)
+	}
+	return bft.VerifyBlockCommit(valSet, *header, commit)
 }
 
 func (a *BFTAdapter) Stop() error {
+	a.engine.Stop()
 	return nil
 }
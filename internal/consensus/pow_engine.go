@@ -0,0 +1,195 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// MaxFutureBlockTime is how far (in seconds) a header timestamp may run ahead of the local clock
+const MaxFutureBlockTime = 600
+
+// PoWEngine runs PoSSR (PoW + VRF seed + sorting race) behind the generic Engine interface
+type PoWEngine struct {
+	difficulty   uint64
+	minerPubKey  [32]byte
+	minerPrivKey ed25519.PrivateKey
+
+	// getTip returns the header new blocks are built on (usually Blockchain.GetTip)
+	getTip func() types.BlockHeader
+
+	// Race hands shards to the network's fastest sorters (nil = the miner keeps every slot)
+	Race *Race
+	// Aggregation builds blocks from shards the sorters supply (takes precedence over Race)
+	Aggregation *Aggregation
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// Ensure PoWEngine implements Engine
+var _ Engine = (*PoWEngine)(nil)
+
+// NewPoWEngine creates a PoSSR engine mining at difficulty with the given key
+func NewPoWEngine(difficulty uint64, minerPrivKey ed25519.PrivateKey, getTip func() types.BlockHeader) *PoWEngine {
+	var minerPubKey [32]byte
+	copy(minerPubKey[:], minerPrivKey.Public().(ed25519.PublicKey))
+
+	return &PoWEngine{
+		difficulty:   difficulty,
+		minerPubKey:  minerPubKey,
+		minerPrivKey: minerPrivKey,
+		getTip:       getTip,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+func (e *PoWEngine) Initialize() error {
+	if e.difficulty == 0 {
+		return fmt.Errorf("difficulty must be greater than 0")
+	}
+	if e.getTip == nil {
+		return fmt.Errorf("no chain tip source configured")
+	}
+	return nil
+}
+
+// RunConsensusRound mines a block at height on top of the current tip
+func (e *PoWEngine) RunConsensusRound(height uint64, txs []types.Transaction) (*types.Block, error) {
+	prev := e.getTip()
+	if height != prev.Height+1 {
+		return nil, fmt.Errorf("cannot mine height %d on tip %d", height, prev.Height)
+	}
+
+	if e.Aggregation != nil {
+		header, err := sealHeader(prev, e.difficulty, e.stopChan, e.minerPubKey, e.minerPrivKey, time.Now)
+		if err != nil {
+			return nil, err
+		}
+		block, err := e.Aggregation.Run(header, txs, e.minerPrivKey, e.stopChan)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.VerifySeal(&block.Header); err != nil {
+			return nil, fmt.Errorf("aggregated block has invalid seal: %w", err)
+		}
+		return block, nil
+	}
+
+	block, err := MineBlock(txs, prev, e.difficulty, e.stopChan, e.minerPubKey, e.minerPrivKey)
+	if err != nil {
+		return nil, err
+	}
+	if e.Race != nil {
+		if err := e.Race.Run(block, e.stopChan); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.VerifySeal(&block.Header); err != nil {
+		return nil, fmt.Errorf("mined block has invalid seal: %w", err)
+	}
+	return block, nil
+}
+
+// ValidateBlockHeader checks the header fields, its link to the tip and its seal
+func (e *PoWEngine) ValidateBlockHeader(header *types.BlockHeader) error {
+	if header.Version == 0 {
+		return fmt.Errorf("invalid header version: %d", header.Version)
+	}
+	if header.Difficulty == 0 {
+		return fmt.Errorf("header difficulty must be greater than 0")
+	}
+	if header.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+		return fmt.Errorf("block timestamp too far in future")
+	}
+
+	// Headers extending our tip must link to it and keep a sane timestamp
+	if e.getTip != nil {
+		tip := e.getTip()
+		if header.Height == tip.Height+1 {
+			if header.PrevBlockHash != types.HashBlockHeaderForPoW(tip) {
+				return fmt.Errorf("header %d does not extend the current tip", header.Height)
+			}
+			if header.Timestamp < tip.Timestamp {
+				return fmt.Errorf("header timestamp %d before parent %d", header.Timestamp, tip.Timestamp)
+			}
+		}
+	}
+
+	return e.VerifySeal(header)
+}
+
+// VerifySeal checks the PoW target, the stored hash and the VRF seed of header
+func (e *PoWEngine) VerifySeal(header *types.BlockHeader) error {
+	if header.Hash != types.HashBlockHeaderForPoW(*header) {
+		return fmt.Errorf("header hash does not match contents")
+	}
+	return VerifyPoWSeal(header)
+}
+
+// Stop interrupts any block being mined
+func (e *PoWEngine) Stop() error {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+	return nil
+}
+
+// VerifyPoWSeal checks that header meets its difficulty target and that the
+// VRF seed was derived from the miner's proof over the PoW hash
+func VerifyPoWSeal(header *types.BlockHeader) error {
+	if header.Difficulty == 0 {
+		return fmt.Errorf("header difficulty must be greater than 0")
+	}
+	// Old seed schemes stay valid only before their replacement activates
+	if want := types.HeaderVersionAt(header.Height); header.Version != want {
+		return fmt.Errorf("header version %d at height %d, expected %d", header.Version, header.Height, want)
+	}
+
+	powHash := types.HashBlockHeaderForPoW(*header)
+	hashInt := new(big.Int).SetBytes(powHash[:])
+	maxVal := new(big.Int).Exp(big.NewInt(2), big.NewInt(256), nil)
+	targetVal := new(big.Int).Div(maxVal, new(big.Int).SetUint64(header.Difficulty))
+	if hashInt.Cmp(targetVal) != -1 {
+		return fmt.Errorf("block hash does not meet difficulty target")
+	}
+
+	switch header.Version {
+	case types.HeaderVersionLegacyVRF:
+		// Seed MUST be H(Signature(Miner, PoWHash))
+		if !ed25519.Verify(ed25519.PublicKey(header.MinerPubKey[:]), powHash[:], header.MinerSignature[:]) {
+			return fmt.Errorf("invalid miner signature (VRF proof failed)")
+		}
+		expectedSeed := sha256.Sum256(header.MinerSignature[:])
+		if expectedSeed != header.VRFSeed {
+			return fmt.Errorf("VRF seed mismatch: does not match signature entropy")
+		}
+	case types.HeaderVersionECVRF, types.HeaderVersionDataAvailability:
+		// Seed MUST be ProofToHash(Prove(Miner, PoWHash))[:32]
+		if _, err := utils.VRFVerify(header.MinerPubKey[:], powHash[:], header.VRFProof); err != nil {
+			return fmt.Errorf("invalid miner VRF proof: %w", err)
+		}
+		if ecvrfSeed(header.VRFProof) != header.VRFSeed {
+			return fmt.Errorf("VRF seed mismatch: does not match proof output")
+		}
+	default:
+		return fmt.Errorf("unsupported header version: %d", header.Version)
+	}
+	return nil
+}
+
+// ecvrfSeed returns the block seed of an ECVRF proof: the first half of its output
+func ecvrfSeed(proof [utils.VRFProofSize]byte) [32]byte {
+	var seed [32]byte
+	beta, err := utils.VRFProofToHash(proof)
+	if err != nil {
+		return seed
+	}
+	copy(seed[:], beta[:32])
+	return seed
+}
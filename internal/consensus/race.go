@@ -0,0 +1,164 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// shardProofTag domain-separates shard proof signatures
+var shardProofTag = []byte("rnr-shard-proof-v1")
+
+// raceTicketTag domain-separates race tickets
+var raceTicketTag = []byte("rnr-race-ticket-v1")
+
+// RaceTask reveals a sealed header's seed and one shard's transactions for the sorting race
+type RaceTask struct {
+	Header types.BlockHeader // PoW-sealed header carrying the VRF seed
+	SlotID uint8
+	Txs    []types.Transaction // Shard contents (any order)
+}
+
+// ShardSubmission is a sorter's entry in an aggregation round: its proof and the sorted shard
+type ShardSubmission struct {
+	Proof *ShardProof
+	Txs   []types.Transaction
+}
+
+// RaceMessage is gossiped on the proofs topic: a task or round from a miner,
+// or a proof or shard from a sorter
+type RaceMessage struct {
+	Task  *RaceTask          `json:",omitempty"`
+	Proof *ShardProof        `json:",omitempty"`
+	Round *types.BlockHeader `json:",omitempty"` // Sealed header of an aggregation round
+	Shard *ShardSubmission   `json:",omitempty"`
+}
+
+// ShardSeed derives the sorting seed of slot from a block's VRF seed
+func ShardSeed(seed [32]byte, slot uint8) [32]byte {
+	return sha256.Sum256(append(seed[:], slot))
+}
+
+// SortShard sorts a shard's transactions for slot of the block at height under seed
+// and returns them with their root
+func SortShard(txs []types.Transaction, seed [32]byte, slot uint8, height uint64) ([]types.Transaction, [32]byte) {
+	return StartRaceSimplified(txs, ShardSeed(seed, slot), SelectAlgorithm(seed, height))
+}
+
+// SignBytes returns the signed part of the proof: tag | height | slot | seed | root
+func (p *ShardProof) SignBytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(shardProofTag)
+	binary.Write(&buf, binary.BigEndian, p.Height)
+	buf.WriteByte(p.SlotID)
+	buf.Write(p.Seed[:])
+	buf.Write(p.Proof[:])
+	return buf.Bytes()
+}
+
+// Sign sets NodeID to the key's public key and signs the proof
+func (p *ShardProof) Sign(privKey ed25519.PrivateKey) {
+	copy(p.NodeID[:], privKey.Public().(ed25519.PublicKey))
+	copy(p.Signature[:], ed25519.Sign(privKey, p.SignBytes()))
+}
+
+// Verify checks the proof was signed by NodeID
+func (p *ShardProof) Verify() bool {
+	return ed25519.Verify(ed25519.PublicKey(p.NodeID[:]), p.SignBytes(), p.Signature[:])
+}
+
+// Ticket is the proof's lottery ticket for its slot; among correct proofs the lowest wins
+// It commits to the submitted sort result and the sorter, so anyone can recompute it.
+// Re-signing one sort under more keys only buys more tickets for keys that are
+// eligible, i.e. staked (Aggregator.Eligible, checked again when blocks are applied).
+func (p *ShardProof) Ticket() [32]byte {
+	var buf bytes.Buffer
+	buf.Write(raceTicketTag)
+	buf.Write(p.Seed[:])
+	buf.WriteByte(p.SlotID)
+	buf.Write(p.Proof[:])
+	buf.Write(p.NodeID[:])
+	return sha256.Sum256(buf.Bytes())
+}
+
+// BlockShardProof returns the proof recorded in block for slot (its race winner)
+func BlockShardProof(block *types.Block, slot uint8) *ShardProof {
+	shard := block.Shards[slot]
+	return &ShardProof{
+		Height:    block.Header.Height,
+		SlotID:    slot,
+		NodeID:    shard.NodeID,
+		Seed:      block.Header.VRFSeed,
+		Proof:     block.Header.ShardRoots[slot],
+		Signature: shard.Signature,
+	}
+}
+
+// SolveRaceTask checks the task's seal, sorts its shard and returns our signed proof
+func SolveRaceTask(task *RaceTask, privKey ed25519.PrivateKey) (*ShardProof, error) {
+	if task.SlotID >= 10 {
+		return nil, fmt.Errorf("invalid slot ID: %d", task.SlotID)
+	}
+	if err := VerifyPoWSeal(&task.Header); err != nil {
+		return nil, fmt.Errorf("invalid race header: %w", err)
+	}
+
+	_, root := SortShard(task.Txs, task.Header.VRFSeed, task.SlotID, task.Header.Height)
+	proof := &ShardProof{
+		Height:    task.Header.Height,
+		SlotID:    task.SlotID,
+		Seed:      task.Header.VRFSeed,
+		Proof:     root,
+		Timestamp: time.Now().Unix(),
+	}
+	proof.Sign(privKey)
+	return proof, nil
+}
+
+// Race runs the network sorting race for blocks this node mines
+// The miner reveals every shard with its sealed header; each slot goes to the
+// sorter with the lowest ticket among correct proofs from eligible sorters
+// received within Window. The miner's own proofs take part (its identity is bound
+// by the block's PoW), so a slot nobody else solves stays with it.
+type Race struct {
+	Aggregator *Aggregator
+	Publish    func(*RaceMessage) error // Gossips race tasks (e.g. on the proofs topic)
+	Window     time.Duration
+}
+
+// Run reveals block's shards, collects proofs for Window and records the winners in block
+func (r *Race) Run(block *types.Block, stopChan chan struct{}) error {
+	height := block.Header.Height
+	r.Aggregator.StartRace(height, block.Header.VRFSeed, block.Header.ShardRoots)
+
+	for slot := uint8(0); slot < 10; slot++ {
+		if err := r.Aggregator.SubmitProof(height, BlockShardProof(block, slot)); err != nil {
+			return fmt.Errorf("own proof for slot %d rejected: %w", slot, err)
+		}
+		if r.Publish != nil {
+			task := &RaceTask{Header: block.Header, SlotID: slot, Txs: block.Shards[slot].TxData}
+			if err := r.Publish(&RaceMessage{Task: task}); err != nil {
+				fmt.Printf("[RACE] Warning: Failed to publish slot %d: %v\n", slot, err)
+			}
+		}
+	}
+
+	select {
+	case <-time.After(r.Window):
+	case <-stopChan:
+		return fmt.Errorf("mining interrupted")
+	}
+
+	for slot, proof := range r.Aggregator.GetProofs(height) {
+		block.Header.WinningNodes[slot] = proof.NodeID
+		block.Shards[slot].NodeID = proof.NodeID
+		block.Shards[slot].Signature = proof.Signature
+	}
+	fmt.Printf("[RACE] Height %d winners decided\n", height)
+	return nil
+}
@@ -1,6 +1,9 @@
 package consensus
 
 import (
+	"encoding/binary"
+	"sync"
+
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
@@ -8,7 +11,7 @@ import (
 // Addressing debat/9.txt: "Sorting GC Pressure"
 type SortableTransaction struct {
 	Tx  *types.Transaction // Changed to pointer for Zero-Copy sorting
-	Key string             // MixHash(TxID, Seed)
+	Key [32]byte           // MixHash(TxID, Seed), compared as a big-endian number
 }
 
 // ByKey implements sort.Interface for []SortableTransaction based on Key field
@@ -16,7 +19,43 @@ type ByKey []SortableTransaction
 
 func (a ByKey) Len() int           { return len(a) }
 func (a ByKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByKey) Less(i, j int) bool { return a[i].Key < a[j].Key }
+func (a ByKey) Less(i, j int) bool { return keyLess(&a[i].Key, &a[j].Key) }
+
+// keyLess orders keys byte-wise, eight bytes at a time
+func keyLess(a, b *[32]byte) bool {
+	for i := 0; i < 32; i += 8 {
+		x, y := binary.BigEndian.Uint64(a[i:]), binary.BigEndian.Uint64(b[i:])
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// scratchPool recycles the buffers of MergeSort, RadixSort, TimSort and ParallelSort
+var scratchPool = sync.Pool{New: func() interface{} { return new([]SortableTransaction) }}
+
+func getScratch(n int) *[]SortableTransaction {
+	buf := scratchPool.Get().(*[]SortableTransaction)
+	if cap(*buf) < n {
+		*buf = make([]SortableTransaction, n)
+	}
+	*buf = (*buf)[:n]
+	return buf
+}
+
+func putScratch(buf *[]SortableTransaction) {
+	clear(*buf) // Don't keep transactions alive
+	scratchPool.Put(buf)
+}
+
+// SortByAlgorithm sorts data in place with the registered algorithm called algo (QUICK_SORT if unknown)
+func SortByAlgorithm(data []SortableTransaction, algo string) []SortableTransaction {
+	if alg, ok := Algorithms.Lookup(algo); ok {
+		return alg.Sort(data)
+	}
+	return QuickSort(data)
+}
 
 // =============================================================================
 // 1. QUICK SORT - Divide and Conquer
@@ -45,7 +84,7 @@ func partition(arr []SortableTransaction, low, high int) int {
 	i := low - 1
 
 	for j := low; j < high; j++ {
-		if arr[j].Key < pivot {
+		if keyLess(&arr[j].Key, &pivot) {
 			i++
 			arr[i], arr[j] = arr[j], arr[i]
 		}
@@ -59,48 +98,50 @@ func partition(arr []SortableTransaction, low, high int) int {
 // =============================================================================
 
 // MergeSort implements the merge sort algorithm
-// Complexity: O(n log n) guaranteed, Space: O(n)
-// Stable sorting algorithm
+// Complexity: O(n log n) guaranteed, Space: O(n) (pooled scratch buffer)
+// Stable sorting algorithm, sorts data in place
 func MergeSort(data []SortableTransaction) []SortableTransaction {
 	if len(data) <= 1 {
 		return data
 	}
 
-	result := make([]SortableTransaction, len(data))
-	copy(result, data)
-	return mergeSortRecursive(result)
+	buf := getScratch(len(data))
+	defer putScratch(buf)
+	copy(*buf, data)
+	mergeSortInto(*buf, data)
+	return data
 }
 
-func mergeSortRecursive(arr []SortableTransaction) []SortableTransaction {
-	if len(arr) <= 1 {
-		return arr
+// mergeSortInto sorts src into dst; both hold the same elements on entry
+// The halves are sorted with the roles swapped, so no copies are needed.
+func mergeSortInto(src, dst []SortableTransaction) {
+	if len(dst) <= 1 {
+		return
 	}
 
-	mid := len(arr) / 2
-	left := mergeSortRecursive(arr[:mid])
-	right := mergeSortRecursive(arr[mid:])
-
-	return merge(left, right)
+	mid := len(dst) / 2
+	mergeSortInto(dst[:mid], src[:mid])
+	mergeSortInto(dst[mid:], src[mid:])
+	merge(src[:mid], src[mid:], dst)
 }
 
-func merge(left, right []SortableTransaction) []SortableTransaction {
-	result := make([]SortableTransaction, 0, len(left)+len(right))
-	i, j := 0, 0
+// merge merges the sorted runs left and right into out (stable)
+func merge(left, right, out []SortableTransaction) {
+	i, j, k := 0, 0, 0
 
 	for i < len(left) && j < len(right) {
-		if left[i].Key <= right[j].Key {
-			result = append(result, left[i])
+		if !keyLess(&right[j].Key, &left[i].Key) {
+			out[k] = left[i]
 			i++
 		} else {
-			result = append(result, right[j])
+			out[k] = right[j]
 			j++
 		}
+		k++
 	}
 
-	result = append(result, left[i:]...)
-	result = append(result, right[j:]...)
-
-	return result
+	k += copy(out[k:], left[i:])
+	copy(out[k:], right[j:])
 }
 
 // =============================================================================
@@ -131,86 +172,81 @@ func HeapSort(data []SortableTransaction) []SortableTransaction {
 }
 
 func heapify(arr []SortableTransaction, n, i int) {
-	largest := i
-	left := 2*i + 1
-	right := 2*i + 2
+	for {
+		largest := i
+		left := 2*i + 1
+		right := 2*i + 2
 
-	if left < n && arr[left].Key > arr[largest].Key {
-		largest = left
-	}
+		if left < n && keyLess(&arr[largest].Key, &arr[left].Key) {
+			largest = left
+		}
 
-	if right < n && arr[right].Key > arr[largest].Key {
-		largest = right
-	}
+		if right < n && keyLess(&arr[largest].Key, &arr[right].Key) {
+			largest = right
+		}
 
-	if largest != i {
+		if largest == i {
+			return
+		}
 		arr[i], arr[largest] = arr[largest], arr[i]
-		heapify(arr, n, largest)
+		i = largest
 	}
 }
 
 // =============================================================================
-// 4. RADIX SORT - Non-Comparison Based (for strings)
+// 4. RADIX SORT - Non-Comparison Based (for fixed-width keys)
 // =============================================================================
 
-// RadixSort implements radix sort for string keys
-// Complexity: O(d*n) where d is max key length, Space: O(n+k)
-// Stable, non-comparison based
+// radixBytes is how many leading key bytes RadixSort distributes on
+// Keys are hashes, so ties beyond them are rare and fixed by a final insertion pass.
+const radixBytes = 8
+
+// RadixSort implements LSD radix sort over the leading key bytes
+// Complexity: O(radixBytes*n), Space: O(n) (pooled scratch buffer)
+// Stable, non-comparison based, sorts data in place
 func RadixSort(data []SortableTransaction) []SortableTransaction {
-	if len(data) <= 1 {
+	n := len(data)
+	if n <= 1 {
 		return data
 	}
 
-	result := make([]SortableTransaction, len(data))
-	copy(result, data)
+	buf := getScratch(n)
+	defer putScratch(buf)
 
-	// Find max length
-	maxLen := 0
-	for _, item := range result {
-		if len(item.Key) > maxLen {
-			maxLen = len(item.Key)
+	// Sort from least significant to most significant byte
+	src, dst := data, *buf
+	for pos := radixBytes - 1; pos >= 0; pos-- {
+		var count [256]int
+		for i := range src {
+			count[src[i].Key[pos]]++
+		}
+		if count[src[0].Key[pos]] == n {
+			continue // Every key has the same byte here
 		}
-	}
-
-	// Sort from least significant to most significant character
-	for pos := maxLen - 1; pos >= 0; pos-- {
-		result = countingSortByChar(result, pos)
-	}
-
-	return result
-}
-
-func countingSortByChar(arr []SortableTransaction, pos int) []SortableTransaction {
-	n := len(arr)
-	output := make([]SortableTransaction, n)
-	count := make([]int, 256) // ASCII characters
 
-	// Count occurrences
-	for _, item := range arr {
-		char := getCharAt(item.Key, pos)
-		count[char]++
-	}
+		// Starting offset of each byte value
+		offset := 0
+		for b := range count {
+			c := count[b]
+			count[b] = offset
+			offset += c
+		}
 
-	// Calculate cumulative count
-	for i := 1; i < 256; i++ {
-		count[i] += count[i-1]
+		// Build output array (forwards keeps it stable)
+		for i := range src {
+			b := src[i].Key[pos]
+			dst[count[b]] = src[i]
+			count[b]++
+		}
+		src, dst = dst, src
 	}
-
-	// Build output array (backwards for stability)
-	for i := n - 1; i >= 0; i-- {
-		char := getCharAt(arr[i].Key, pos)
-		output[count[char]-1] = arr[i]
-		count[char]--
+	if &src[0] != &data[0] {
+		copy(data, src)
 	}
 
-	return output
-}
-
-func getCharAt(s string, pos int) byte {
-	if pos >= len(s) {
-		return 0 // Null character for padding
-	}
-	return s[pos]
+	// Order keys sharing their leading bytes
+	insertionSort(data, 0, n-1)
+	return data
 }
 
 // =============================================================================
@@ -220,13 +256,13 @@ func getCharAt(s string, pos int) byte {
 const minMerge = 32
 
 // TimSort implements a simplified version of Timsort
-// Complexity: O(n log n), Space: O(n)
-// Stable, optimized for real-world data (used in Python)
+// Complexity: O(n log n), Space: O(n) (pooled scratch buffer)
+// Stable, optimized for real-world data (used in Python), sorts data in place
 func TimSort(data []SortableTransaction) []SortableTransaction {
-	result := make([]SortableTransaction, len(data))
-	copy(result, data)
-
-	n := len(result)
+	n := len(data)
+	if n <= 1 {
+		return data
+	}
 
 	// Sort individual subarrays of size minMerge using insertion sort
 	for start := 0; start < n; start += minMerge {
@@ -234,8 +270,14 @@ func TimSort(data []SortableTransaction) []SortableTransaction {
 		if end > n {
 			end = n
 		}
-		insertionSort(result, start, end-1)
+		insertionSort(data, start, end-1)
 	}
+	if n <= minMerge {
+		return data
+	}
+
+	buf := getScratch(n)
+	defer putScratch(buf)
 
 	// Merge sorted runs
 	size := minMerge
@@ -247,20 +289,20 @@ func TimSort(data []SortableTransaction) []SortableTransaction {
 				end = n - 1
 			}
 			if mid < end {
-				mergeRuns(result, start, mid, end)
+				mergeRuns(data, start, mid, end, *buf)
 			}
 		}
 		size *= 2
 	}
 
-	return result
+	return data
 }
 
 func insertionSort(arr []SortableTransaction, left, right int) {
 	for i := left + 1; i <= right; i++ {
 		key := arr[i]
 		j := i - 1
-		for j >= left && arr[j].Key > key.Key {
+		for j >= left && keyLess(&key.Key, &arr[j].Key) {
 			arr[j+1] = arr[j]
 			j--
 		}
@@ -268,40 +310,15 @@ func insertionSort(arr []SortableTransaction, left, right int) {
 	}
 }
 
-func mergeRuns(arr []SortableTransaction, left, mid, right int) {
-	len1 := mid - left + 1
-	len2 := right - mid
-
-	leftArr := make([]SortableTransaction, len1)
-	rightArr := make([]SortableTransaction, len2)
-
-	copy(leftArr, arr[left:left+len1])
-	copy(rightArr, arr[mid+1:mid+1+len2])
-
-	i, j, k := 0, 0, left
-
-	for i < len1 && j < len2 {
-		if leftArr[i].Key <= rightArr[j].Key {
-			arr[k] = leftArr[i]
-			i++
-		} else {
-			arr[k] = rightArr[j]
-			j++
-		}
-		k++
-	}
-
-	for i < len1 {
-		arr[k] = leftArr[i]
-		i++
-		k++
+// mergeRuns merges arr[left..mid] and arr[mid+1..right], moving only the left run to scratch
+func mergeRuns(arr []SortableTransaction, left, mid, right int, scratch []SortableTransaction) {
+	if !keyLess(&arr[mid+1].Key, &arr[mid].Key) {
+		return // Already in order
 	}
 
-	for j < len2 {
-		arr[k] = rightArr[j]
-		j++
-		k++
-	}
+	leftArr := scratch[:mid-left+1]
+	copy(leftArr, arr[left:mid+1])
+	merge(leftArr, arr[mid+1:right+1], arr[left:right+1])
 }
 
 // =============================================================================
@@ -347,37 +364,7 @@ func introSortRecursive(arr []SortableTransaction, low, high, depthLimit int) {
 }
 
 func heapSortRange(arr []SortableTransaction, low, high int) {
-	n := high - low + 1
-
-	// Build heap
-	for i := n/2 - 1; i >= 0; i-- {
-		heapifyRange(arr, low, n, i)
-	}
-
-	// Extract elements
-	for i := n - 1; i > 0; i-- {
-		arr[low], arr[low+i] = arr[low+i], arr[low]
-		heapifyRange(arr, low, i, 0)
-	}
-}
-
-func heapifyRange(arr []SortableTransaction, offset, n, i int) {
-	largest := i
-	left := 2*i + 1
-	right := 2*i + 2
-
-	if left < n && arr[offset+left].Key > arr[offset+largest].Key {
-		largest = left
-	}
-
-	if right < n && arr[offset+right].Key > arr[offset+largest].Key {
-		largest = right
-	}
-
-	if largest != i {
-		arr[offset+i], arr[offset+largest] = arr[offset+largest], arr[offset+i]
-		heapifyRange(arr, offset, n, largest)
-	}
+	HeapSort(arr[low : high+1])
 }
 
 func logBase2(n int) int {
@@ -407,7 +394,7 @@ func ShellSort(arr []SortableTransaction) []SortableTransaction {
 		for i := gap; i < n; i++ {
 			temp := arr[i]
 			j := i
-			for ; j >= gap && arr[j-gap].Key > temp.Key; j -= gap {
+			for ; j >= gap && keyLess(&temp.Key, &arr[j-gap].Key); j -= gap {
 				arr[j] = arr[j-gap]
 			}
 			arr[j] = temp
@@ -415,3 +402,72 @@ func ShellSort(arr []SortableTransaction) []SortableTransaction {
 	}
 	return arr
 }
+
+// =============================================================================
+// PARALLEL SORT - Chunks sorted concurrently, then merged
+// =============================================================================
+
+// parallelSortThreshold is the shard size from which StartRaceSimplified sorts in parallel
+const parallelSortThreshold = 8192
+
+// ParallelSort sorts data in place: chunks are sorted with algo on up to workers
+// goroutines, then merged pairwise in parallel rounds
+// Keys are totally ordered, so the result is the same as sorting with algo alone.
+func ParallelSort(data []SortableTransaction, algo string, workers int) []SortableTransaction {
+	n := len(data)
+	if workers > n/minMerge {
+		workers = n / minMerge
+	}
+	if workers <= 1 {
+		return SortByAlgorithm(data, algo)
+	}
+
+	// Sort the chunks; bounds[i]..bounds[i+1] is run i
+	chunk := (n + workers - 1) / workers
+	var bounds []int
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+		bounds = append(bounds, lo)
+		wg.Add(1)
+		go func(part []SortableTransaction) {
+			defer wg.Done()
+			SortByAlgorithm(part, algo)
+		}(data[lo:hi])
+	}
+	bounds = append(bounds, n)
+	wg.Wait()
+
+	buf := getScratch(n)
+	defer putScratch(buf)
+
+	// Merge neighbouring runs until one is left, alternating between data and scratch
+	src, dst := data, *buf
+	for len(bounds) > 2 {
+		var next []int
+		for i := 0; i+1 < len(bounds); i += 2 {
+			lo := bounds[i]
+			next = append(next, lo)
+			if i+2 >= len(bounds) {
+				copy(dst[lo:], src[lo:]) // Odd run out
+				continue
+			}
+			mid, hi := bounds[i+1], bounds[i+2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				merge(src[lo:mid], src[mid:hi], dst[lo:hi])
+			}()
+		}
+		wg.Wait()
+		bounds = append(next, n)
+		src, dst = dst, src
+	}
+	if &src[0] != &data[0] {
+		copy(data, src)
+	}
+	return data
+}
@@ -1,6 +1,9 @@
 package consensus
 
 import (
+	"encoding/binary"
+	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
@@ -18,10 +21,28 @@ func createTestTransactions(n int) []types.Transaction {
 	return txs
 }
 
+// Helper to create n transactions with distinct IDs, keyed for seed
+func createSortableData(n int, seed [32]byte) []SortableTransaction {
+	txs := make([]types.Transaction, n)
+	data := make([]SortableTransaction, n)
+	for i := range txs {
+		binary.BigEndian.PutUint64(txs[i].ID[:], uint64(i))
+		data[i] = SortableTransaction{Tx: &txs[i], Key: utils.MixHash(txs[i].ID, seed)}
+	}
+	return data
+}
+
+// Helper to build a sort key from a readable prefix
+func key(s string) [32]byte {
+	var k [32]byte
+	copy(k[:], s)
+	return k
+}
+
 // Helper to check if array is sorted by keys
 func isSorted(data []SortableTransaction) bool {
 	for i := 1; i < len(data); i++ {
-		if data[i-1].Key > data[i].Key {
+		if keyLess(&data[i].Key, &data[i-1].Key) {
 			return false
 		}
 	}
@@ -31,10 +52,10 @@ func isSorted(data []SortableTransaction) bool {
 // Test QuickSort
 func TestQuickSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "gamma"},
-		{Key: "alpha"},
-		{Key: "beta"},
-		{Key: "delta"},
+		{Key: key("gamma")},
+		{Key: key("alpha")},
+		{Key: key("beta")},
+		{Key: key("delta")},
 	}
 
 	sorted := QuickSort(data)
@@ -43,7 +64,7 @@ func TestQuickSort(t *testing.T) {
 		t.Error("QuickSort did not sort correctly")
 	}
 
-	if sorted[0].Key != "alpha" || sorted[3].Key != "gamma" {
+	if sorted[0].Key != key("alpha") || sorted[3].Key != key("gamma") {
 		t.Errorf("QuickSort order incorrect: got %v", sorted)
 	}
 }
@@ -51,10 +72,10 @@ func TestQuickSort(t *testing.T) {
 // Test MergeSort
 func TestMergeSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "zebra"},
-		{Key: "apple"},
-		{Key: "mango"},
-		{Key: "banana"},
+		{Key: key("zebra")},
+		{Key: key("apple")},
+		{Key: key("mango")},
+		{Key: key("banana")},
 	}
 
 	sorted := MergeSort(data)
@@ -63,7 +84,7 @@ func TestMergeSort(t *testing.T) {
 		t.Error("MergeSort did not sort correctly")
 	}
 
-	if sorted[0].Key != "apple" || sorted[3].Key != "zebra" {
+	if sorted[0].Key != key("apple") || sorted[3].Key != key("zebra") {
 		t.Errorf("MergeSort order incorrect")
 	}
 }
@@ -71,10 +92,10 @@ func TestMergeSort(t *testing.T) {
 // Test HeapSort
 func TestHeapSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "dog"},
-		{Key: "cat"},
-		{Key: "elephant"},
-		{Key: "ant"},
+		{Key: key("dog")},
+		{Key: key("cat")},
+		{Key: key("elephant")},
+		{Key: key("ant")},
 	}
 
 	sorted := HeapSort(data)
@@ -83,7 +104,7 @@ func TestHeapSort(t *testing.T) {
 		t.Error("HeapSort did not sort correctly")
 	}
 
-	if sorted[0].Key != "ant" || sorted[3].Key != "elephant" {
+	if sorted[0].Key != key("ant") || sorted[3].Key != key("elephant") {
 		t.Errorf("HeapSort order incorrect")
 	}
 }
@@ -91,10 +112,10 @@ func TestHeapSort(t *testing.T) {
 // Test RadixSort
 func TestRadixSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "xyz"},
-		{Key: "abc"},
-		{Key: "mno"},
-		{Key: "def"},
+		{Key: key("xyz")},
+		{Key: key("abc")},
+		{Key: key("mno")},
+		{Key: key("def")},
 	}
 
 	sorted := RadixSort(data)
@@ -103,7 +124,7 @@ func TestRadixSort(t *testing.T) {
 		t.Error("RadixSort did not sort correctly")
 	}
 
-	if sorted[0].Key != "abc" || sorted[3].Key != "xyz" {
+	if sorted[0].Key != key("abc") || sorted[3].Key != key("xyz") {
 		t.Errorf("RadixSort order incorrect")
 	}
 }
@@ -111,10 +132,10 @@ func TestRadixSort(t *testing.T) {
 // Test TimSort
 func TestTimSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "99"},
-		{Key: "11"},
-		{Key: "55"},
-		{Key: "33"},
+		{Key: key("99")},
+		{Key: key("11")},
+		{Key: key("55")},
+		{Key: key("33")},
 	}
 
 	sorted := TimSort(data)
@@ -127,10 +148,10 @@ func TestTimSort(t *testing.T) {
 // Test IntroSort
 func TestIntroSort(t *testing.T) {
 	data := []SortableTransaction{
-		{Key: "omega"},
-		{Key: "alpha"},
-		{Key: "theta"},
-		{Key: "beta"},
+		{Key: key("omega")},
+		{Key: key("alpha")},
+		{Key: key("theta")},
+		{Key: key("beta")},
 	}
 
 	sorted := IntroSort(data)
@@ -139,7 +160,7 @@ func TestIntroSort(t *testing.T) {
 		t.Error("IntroSort did not sort correctly")
 	}
 
-	if sorted[0].Key != "alpha" || sorted[3].Key != "theta" {
+	if sorted[0].Key != key("alpha") || sorted[3].Key != key("theta") {
 		t.Errorf("IntroSort order incorrect")
 	}
 }
@@ -170,6 +191,8 @@ func TestAllAlgorithmsProduceSameResult(t *testing.T) {
 		"RADIX_SORT": RadixSort(prepareData()),
 		"TIM_SORT":   TimSort(prepareData()),
 		"INTRO_SORT": IntroSort(prepareData()),
+		"SHELL_SORT": ShellSort(prepareData()),
+		"PARALLEL":   ParallelSort(prepareData(), "MERGE_SORT", 4),
 	}
 
 	// Compare all results
@@ -184,14 +207,38 @@ func TestAllAlgorithmsProduceSameResult(t *testing.T) {
 		for i := range result {
 			if result[i].Key != baseline[i].Key {
 				t.Errorf("%s produced different order at index %d", name, i)
-				t.Logf("  Expected: %s", baseline[i].Key)
-				t.Logf("  Got: %s", result[i].Key)
+				t.Logf("  Expected: %x", baseline[i].Key)
+				t.Logf("  Got: %x", result[i].Key)
 				break
 			}
 		}
 	}
 
-	t.Logf("✅ All 6 algorithms produced identical results")
+	t.Logf("✅ All 7 algorithms and the parallel sort produced identical results")
+}
+
+// Test fixed-width keys keep the order of the former string keys, sequentially and in parallel
+func TestParallelSortMatchesSequential(t *testing.T) {
+	data := createSortableData(20000, [32]byte{0x42})
+
+	// Former behaviour: keys as strings, compared byte-wise
+	expected := make([]SortableTransaction, len(data))
+	copy(expected, data)
+	sort.Slice(expected, func(i, j int) bool { return string(expected[i].Key[:]) < string(expected[j].Key[:]) })
+
+	for _, algo := range []string{"QUICK_SORT", "MERGE_SORT", "HEAP_SORT", "RADIX_SORT", "TIM_SORT", "INTRO_SORT", "SHELL_SORT"} {
+		for _, workers := range []int{1, 3, 8} {
+			result := make([]SortableTransaction, len(data))
+			copy(result, data)
+			ParallelSort(result, algo, workers)
+
+			for i := range result {
+				if result[i].Tx != expected[i].Tx {
+					t.Fatalf("%s with %d workers: different order at index %d", algo, workers, i)
+				}
+			}
+		}
+	}
 }
 
 // Test algorithm selection is deterministic
@@ -206,21 +253,17 @@ func TestAlgorithmSelectionDeterministic(t *testing.T) {
 		{3, "RADIX_SORT"},
 		{4, "TIM_SORT"},
 		{5, "INTRO_SORT"},
-		{6, "SHELL_SORT"},  // % 7 = 6
-		{7, "QUICK_SORT"},  // % 7 = 0
-		{14, "QUICK_SORT"}, // % 7 = 0
-		{255, "TIM_SORT"},  // 255 % 7 = 3 (Wait: 255 = 36*7 + 3) -> RADIX?
-		// Let'scalc: 255 / 7 = 36 rem 3. So 3 is RADIX.
-		// Wait, 4 is TIM.
-		// Let's verify calculator: 255 - (36*7) = 255 - 252 = 3.
-		// Case 3 is RADIX_SORT.
+		{6, "SHELL_SORT"},   // % 7 = 6
+		{7, "QUICK_SORT"},   // % 7 = 0
+		{14, "QUICK_SORT"},  // % 7 = 0
+		{255, "RADIX_SORT"}, // 255 = 36*7 + 3
 	}
 
 	for _, tc := range testCases {
 		seed := [32]byte{}
 		seed[31] = tc.lastByte
 
-		result := utils.SelectAlgorithm(seed)
+		result := SelectAlgorithm(seed, 0)
 
 		if result != tc.expected {
 			t.Errorf("Seed byte %d: expected %s, got %s", tc.lastByte, tc.expected, result)
@@ -228,6 +271,41 @@ func TestAlgorithmSelectionDeterministic(t *testing.T) {
 	}
 }
 
+// Test new algorithms only join the race from their activation height
+func TestAlgorithmRegistryForks(t *testing.T) {
+	registry := defaultAlgorithms()
+	seed := [32]byte{}
+	seed[31] = 7 // Version 1: 7 % 7 = QUICK_SORT, version 2: 7 % 8 = the new algorithm
+
+	if err := registry.Register(SortAlgorithm{Name: "QUICK_SORT", Sort: QuickSort, ActivationHeight: 100}); err == nil {
+		t.Error("duplicate algorithm name accepted")
+	}
+	if err := registry.Register(SortAlgorithm{Name: "BLOCK_SORT", Sort: MergeSort, ActivationHeight: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(SortAlgorithm{Name: "LATE_SORT", Sort: HeapSort, ActivationHeight: 50}); err == nil {
+		t.Error("algorithm activating before the latest fork accepted")
+	}
+
+	for _, tc := range []struct {
+		height   uint64
+		version  int
+		expected string
+	}{
+		{0, 1, "QUICK_SORT"},
+		{99, 1, "QUICK_SORT"},
+		{100, 2, "BLOCK_SORT"},
+	} {
+		if v := registry.Version(tc.height); v != tc.version {
+			t.Errorf("height %d: version %d, expected %d", tc.height, v, tc.version)
+		}
+		alg, err := registry.Select(seed, tc.height)
+		if err != nil || alg.Name != tc.expected {
+			t.Errorf("height %d: selected %s (%v), expected %s", tc.height, alg.Name, err, tc.expected)
+		}
+	}
+}
+
 // Test StartRace with different seeds produces different algorithms
 func TestStartRaceUsesCorrectAlgorithm(t *testing.T) {
 	txs := createTestTransactions(10)
@@ -248,7 +326,7 @@ func TestStartRaceUsesCorrectAlgorithm(t *testing.T) {
 		seed := [32]byte{}
 		seed[31] = tc.seedByte
 
-		algo := utils.SelectAlgorithm(seed)
+		algo := SelectAlgorithm(seed, 0)
 		if algo != tc.expected {
 			t.Errorf("Seed %d should select %s, got %s", tc.seedByte, tc.expected, algo)
 		}
@@ -394,3 +472,47 @@ func BenchmarkIntroSort(b *testing.B) {
 		IntroSort(dataCopy)
 	}
 }
+
+// Shard-sized benchmarks: a 1 MB shard holds a few thousand transactions
+var benchShardSizes = []int{4096, 65536}
+
+// BenchmarkStringKeys sorts with the former string keys (allocated per transaction) as a baseline
+func BenchmarkStringKeys(b *testing.B) {
+	type stringKeyed struct {
+		Tx  *types.Transaction
+		Key string
+	}
+	for _, n := range benchShardSizes {
+		data := createSortableData(n, [32]byte{0x42})
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				keyed := make([]stringKeyed, n)
+				for j := range data {
+					keyed[j] = stringKeyed{Tx: data[j].Tx, Key: string(data[j].Key[:])}
+				}
+				sort.Slice(keyed, func(x, y int) bool { return keyed[x].Key < keyed[y].Key })
+			}
+		})
+	}
+}
+
+// BenchmarkShardSort sorts shard-sized inputs with each algorithm, sequentially and in parallel
+func BenchmarkShardSort(b *testing.B) {
+	algos := []string{"QUICK_SORT", "MERGE_SORT", "HEAP_SORT", "RADIX_SORT", "TIM_SORT", "INTRO_SORT", "SHELL_SORT"}
+	for _, n := range benchShardSizes {
+		data := createSortableData(n, [32]byte{0x42})
+		work := make([]SortableTransaction, n)
+		for _, algo := range algos {
+			for _, workers := range []int{1, 8} {
+				b.Run(fmt.Sprintf("%s/n=%d/workers=%d", algo, n, workers), func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						copy(work, data)
+						ParallelSort(work, algo, workers)
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package consensus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// WALRecordType identifies what a WAL record holds
+type WALRecordType string
+
+const (
+	WALRecordStep      WALRecordType = "step"       // Step transition with lock state
+	WALRecordProposal  WALRecordType = "proposal"   // Accepted proposal and its block
+	WALRecordOwnVote   WALRecordType = "own_vote"   // Vote we signed (written before broadcast)
+	WALRecordVote      WALRecordType = "vote"       // Vote received from a peer
+	WALRecordEndHeight WALRecordType = "end_height" // Height committed
+)
+
+// maxWALRecordSize bounds a single record (proposal blocks dominate)
+const maxWALRecordSize = bft.BlockPartSize * bft.MaxBlockParts
+
+// WALRecord is one entry of the consensus write-ahead log
+type WALRecord struct {
+	Type   WALRecordType
+	Height uint64
+	Round  int32
+
+	// WALRecordStep
+	Step        bft.RoundStep `json:",omitempty"`
+	LockedRound int32         `json:",omitempty"`
+	LockedBlock [32]byte
+	ValidRound  int32 `json:",omitempty"`
+	ValidBlock  [32]byte
+
+	// WALRecordProposal
+	Proposal *bft.Proposal `json:",omitempty"`
+	Block    *types.Block  `json:",omitempty"`
+
+	// WALRecordOwnVote, WALRecordVote
+	Vote *bft.Vote `json:",omitempty"`
+}
+
+// WAL is an append-only log of consensus messages, written before they are acted on
+// Each record is framed as crc32(data) | len(data) | data (JSON).
+type WAL struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenWAL opens (creating if needed) the write-ahead log at path
+// A torn record left by a crash is cut off so new records stay readable.
+func OpenWAL(path string) (*WAL, error) {
+	_, validSize, err := readWALFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > validSize {
+		fmt.Printf("[WAL] Truncating torn tail (%d bytes)\n", info.Size()-validSize)
+		if err := file.Truncate(validSize); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate WAL: %w", err)
+		}
+	}
+	return &WAL{path: path, file: file}, nil
+}
+
+// Write appends rec; with sync it is flushed to disk before returning
+func (w *WAL) Write(rec WALRecord, sync bool) error {
+	frame, err := encodeWALRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(frame); err != nil {
+		return fmt.Errorf("failed to write WAL: %w", err)
+	}
+	if sync {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadAll returns every intact record; a torn record at the tail (crash mid-write) ends the log
+func (w *WAL) ReadAll() ([]WALRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	records, _, err := readWALFile(w.path)
+	return records, err
+}
+
+// Compact drops records below height, atomically replacing the log
+func (w *WAL) Compact(height uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	records, _, err := readWALFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create WAL: %w", err)
+	}
+	for _, rec := range records {
+		if rec.Height < height {
+			continue
+		}
+		frame, err := encodeWALRecord(rec)
+		if err == nil {
+			_, err = tmp.Write(frame)
+		}
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact WAL: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		return fmt.Errorf("failed to replace WAL: %w", err)
+	}
+
+	// Reopen so appends go to the new file
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen WAL: %w", err)
+	}
+	w.file.Close()
+	w.file = file
+	return nil
+}
+
+// Close closes the log file
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func encodeWALRecord(rec WALRecord) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode WAL record: %w", err)
+	}
+	if len(data) > maxWALRecordSize {
+		return nil, fmt.Errorf("WAL record too large: %d bytes", len(data))
+	}
+
+	frame := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint32(frame[0:4], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	copy(frame[8:], data)
+	return frame, nil
+}
+
+// readWALFile decodes records up to the first damaged one and returns the size of the intact prefix
+func readWALFile(path string) ([]WALRecord, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	defer file.Close()
+
+	var records []WALRecord
+	var validSize int64
+	r := bufio.NewReader(file)
+	for {
+		var prefix [8]byte
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			break // EOF or torn prefix
+		}
+		checksum := binary.BigEndian.Uint32(prefix[0:4])
+		length := binary.BigEndian.Uint32(prefix[4:8])
+		if length > maxWALRecordSize {
+			break
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			break
+		}
+
+		var rec WALRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			break
+		}
+		records = append(records, rec)
+		validSize += int64(len(prefix) + len(data))
+	}
+
+	return records, validSize, nil
+}
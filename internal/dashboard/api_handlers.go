@@ -27,6 +27,33 @@ func (s *Server) handleBlockchainInfo(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// handleFinality returns the finalized block and the checkpoint heights
+func (s *Server) handleFinality(w http.ResponseWriter, r *http.Request) {
+	ft := s.bc.GetFinalityTracker()
+	rec := ft.GetFinalizedRecord()
+
+	precommits := 0
+	if rec.Commit != nil {
+		precommits = len(rec.Commit.Signatures)
+	}
+	checkpoints := ft.GetCheckpointHeights()
+	var latestCheckpoint uint64
+	if len(checkpoints) > 0 {
+		latestCheckpoint = checkpoints[len(checkpoints)-1]
+	}
+
+	data := map[string]interface{}{
+		"finalizedHeight":  ft.GetFinalizedHeight(),
+		"finalizedHash":    fmt.Sprintf("%x", ft.GetFinalizedHash()),
+		"precommits":       precommits,
+		"checkpoints":      checkpoints,
+		"latestCheckpoint": latestCheckpoint,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
 // handleNetworkInfo returns network statistics
 func (s *Server) handleNetworkInfo(w http.ResponseWriter, r *http.Request) {
 	mempool := s.source.GetMempoolShard()
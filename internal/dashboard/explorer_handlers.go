@@ -1,12 +1,16 @@
 package dashboard
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/state"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // RNRScan Explorer API Handlers
@@ -98,8 +102,12 @@ func (s *Server) handleTxList(w http.ResponseWriter, r *http.Request) {
 			"from":   fmt.Sprintf("%x", tx.Sender[:8]),
 			"to":     fmt.Sprintf("%x", tx.Receiver[:8]),
 			"amount": tx.Amount,
+			"type":   tx.Type,
 			"status": "pending",
 		}
+		if tx.Type == types.TxTypeMultiTransfer {
+			addMultiTransferInfo(txInfo, tx)
+		}
 		txList = append(txList, txInfo)
 	}
 
@@ -153,6 +161,44 @@ func (s *Server) handleAddressInfo(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(addressInfo)
 }
 
+// handleAccountKeys returns the current signing key and rotation history of an account
+// Path: /api/keys/<hex account id>
+func (s *Server) handleAccountKeys(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 4 {
+		http.Error(w, "Invalid account path", http.StatusBadRequest)
+		return
+	}
+
+	idBytes, err := hex.DecodeString(pathParts[3])
+	if err != nil || len(idBytes) != 32 {
+		http.Error(w, "Account must be a 32-byte hex identifier", http.StatusBadRequest)
+		return
+	}
+	var id [32]byte
+	copy(id[:], idBytes)
+
+	acc, err := s.stateManager.GetAccount(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var history []string
+	for _, key := range acc.KeyHistory {
+		history = append(history, fmt.Sprintf("%x", key))
+	}
+	currentKey := acc.BoundKey(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"account":    fmt.Sprintf("%x", id),
+		"currentKey": fmt.Sprintf("%x", currentKey),
+		"rotated":    acc.PubKey != nil,
+		"keyHistory": history,
+	})
+}
+
 // handleSearch performs universal search (blocks/tx/address)
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
@@ -187,3 +233,28 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// addMultiTransferInfo lists the outputs and fee of a batch transfer in txInfo
+func addMultiTransferInfo(txInfo map[string]interface{}, tx types.Transaction) {
+	outputs, err := tx.Outputs()
+	if err != nil {
+		txInfo["error"] = err.Error()
+		return
+	}
+
+	var outList []map[string]interface{}
+	for _, out := range outputs {
+		outInfo := map[string]interface{}{
+			"to":     fmt.Sprintf("%x", out.Receiver[:8]),
+			"amount": out.Amount,
+		}
+		if !out.IsNative() {
+			outInfo["token"] = fmt.Sprintf("%x", out.Token[:8])
+		}
+		outList = append(outList, outInfo)
+	}
+
+	txInfo["to"] = fmt.Sprintf("%d recipients", len(outputs))
+	txInfo["outputs"] = outList
+	txInfo["fee"] = state.MultiTransferFee(len(outputs))
+}
@@ -46,6 +46,7 @@ func StartServer(port string, chain *blockchain.Blockchain, p2p MempoolSource, w
 	http.HandleFunc("/api/blockchain", srv.handleBlockchainInfo)
 	http.HandleFunc("/api/network", srv.handleNetworkInfo)
 	http.HandleFunc("/api/blocks/recent", srv.handleRecentBlocks)      // Wallet info
+	http.HandleFunc("/api/finality", srv.handleFinality) // Finalized height and checkpoints
 	http.HandleFunc("/api/wallet/send", srv.handleSendTx) // NEW: Send transaction
 	http.HandleFunc("/api/mining", srv.handleMining)      // Mining status
 
@@ -56,6 +57,7 @@ func StartServer(port string, chain *blockchain.Blockchain, p2p MempoolSource, w
 	http.HandleFunc("/api/tx/", srv.handleTxDetail)         // TX detail
 	http.HandleFunc("/api/address/", srv.handleAddressInfo) // Address info
 	http.HandleFunc("/api/search", srv.handleSearch)        // Universal search
+	http.HandleFunc("/api/keys/", srv.handleAccountKeys)    // Key rotation history
 
 	fmt.Printf("[DASH] Dashboard available at http://localhost:%s\n", port)
 	go http.ListenAndServe(":"+port, nil)
@@ -74,6 +76,8 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		"tps":         tps,
 		"lastHash":    fmt.Sprintf("%x", tip.MerkleRoot), // Using Merkle Root as visual proxy
 		"isGenesis":   (s.Wallet != nil),
+
+		"finalizedHeight": s.bc.GetFinalityTracker().GetFinalizedHeight(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -239,9 +243,8 @@ func (s *Server) handleSendTx(w http.ResponseWriter, r *http.Request) {
 	var receiver [32]byte
 	copy(receiver[:], recipientBytes)
 
-	// Get sender's public key
-	var sender [32]byte
-	copy(sender[:], s.Wallet.PublicKey)
+	// Get sender's account (follows key rotations)
+	sender := s.Wallet.AccountID()
 
 	// Get current nonce from state (SECURE: Sequential, no collision)
 	account, err := s.stateManager.GetAccount(sender)
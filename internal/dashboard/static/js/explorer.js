@@ -14,6 +14,10 @@ async function fetchStats() {
         document.getElementById('latestBlock').innerText = `#${data.height}`;
         document.getElementById('txCount').innerText = `${data.mempoolSize} Pending`; // Using mempool as proxy for activity
 
+        const finality = await (await fetch('/api/finality')).json();
+        const checkpoint = finality.latestCheckpoint ? ` (checkpoint #${finality.latestCheckpoint})` : '';
+        document.getElementById('finalizedBlock').innerText = `#${finality.finalizedHeight}${checkpoint}`;
+
     } catch (error) {
         console.error('Error fetching stats:', error);
     }
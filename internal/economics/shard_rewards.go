@@ -41,6 +41,19 @@ func AssignShards(validators [][32]byte, totalShards int) *ShardAssignment {
 	return assignment
 }
 
+// AssignWinners credits each shard to the node that won its sorting race
+// Shards without a winner (zero key) stay unassigned.
+func AssignWinners(winners [][32]byte) *ShardAssignment {
+	assignment := NewShardAssignment(len(winners))
+	for shardID, winner := range winners {
+		if winner == ([32]byte{}) {
+			continue
+		}
+		assignment.ValidatorShards[winner] = append(assignment.ValidatorShards[winner], shardID)
+	}
+	return assignment
+}
+
 // GetShardCount returns number of shards assigned to a validator
 func (sa *ShardAssignment) GetShardCount(validator [32]byte) int {
 	return len(sa.ValidatorShards[validator])
@@ -51,6 +64,16 @@ func (sa *ShardAssignment) GetShards(validator [32]byte) []int {
 	return sa.ValidatorShards[validator]
 }
 
+// IsAssigned reports whether shardID is assigned to validator
+func (sa *ShardAssignment) IsAssigned(validator [32]byte, shardID int) bool {
+	for _, id := range sa.ValidatorShards[validator] {
+		if id == shardID {
+			return true
+		}
+	}
+	return false
+}
+
 // PrintAssignment prints shard assignment for debugging
 func (sa *ShardAssignment) PrintAssignment() {
 	fmt.Println("\n📦 Shard Assignment:")
@@ -2,9 +2,25 @@ package finality
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
+// Record is a finalized block and the precommits that justified it
+type Record struct {
+	Height uint64        `json:"height"`
+	Hash   [32]byte      `json:"hash"`
+	Commit *types.Commit `json:"commit,omitempty"` // nil when finalized without a BFT commit
+}
+
+// Store persists finalization records across restarts
+type Store interface {
+	SaveFinality(rec Record, checkpoint bool) error
+	LoadFinality() (latest *Record, checkpoints []Record, err error)
+}
+
 // FinalityTracker tracks finalized blocks (irreversible commits)
 type FinalityTracker struct {
 	mu sync.RWMutex
@@ -12,10 +28,13 @@ type FinalityTracker struct {
 	// Finalized state
 	FinalizedHeight uint64
 	FinalizedHash   [32]byte
+	finalized       Record // Latest record, with its commit
 
 	// Checkpoints (every N blocks)
 	Checkpoints        map[uint64][32]byte
 	CheckpointInterval uint64
+
+	store Store // nil = in memory only
 }
 
 // NewFinalityTracker creates a new finality tracker
@@ -27,22 +46,60 @@ func NewFinalityTracker(checkpointInterval uint64) *FinalityTracker {
 	}
 }
 
+// SetStore loads the persisted finalization records and persists new ones to store
+func (ft *FinalityTracker) SetStore(store Store) error {
+	latest, checkpoints, err := store.LoadFinality()
+	if err != nil {
+		return fmt.Errorf("failed to load finality records: %v", err)
+	}
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	ft.store = store
+	for _, cp := range checkpoints {
+		ft.Checkpoints[cp.Height] = cp.Hash
+	}
+	if latest != nil && latest.Height > ft.FinalizedHeight {
+		ft.FinalizedHeight = latest.Height
+		ft.FinalizedHash = latest.Hash
+		ft.finalized = *latest
+	}
+	return nil
+}
+
 // MarkFinalized marks a block as finalized (irreversible)
 // This happens when a block receives 2/3+ precommit votes
 func (ft *FinalityTracker) MarkFinalized(height uint64, hash [32]byte) error {
+	return ft.Finalize(Record{Height: height, Hash: hash})
+}
+
+// Finalize marks the block of rec as finalized and persists rec
+func (ft *FinalityTracker) Finalize(rec Record) error {
 	ft.mu.Lock()
 	defer ft.mu.Unlock()
 
+	height, hash := rec.Height, rec.Hash
+
 	// Can only advance finality forward
 	if height <= ft.FinalizedHeight {
 		return fmt.Errorf("cannot finalize height %d: already at %d", height, ft.FinalizedHeight)
 	}
 
+	// Persist first: finality must never be lost across a restart
+	checkpoint := height%ft.CheckpointInterval == 0
+	if ft.store != nil {
+		if err := ft.store.SaveFinality(rec, checkpoint); err != nil {
+			return fmt.Errorf("failed to persist finality at height %d: %v", height, err)
+		}
+	}
+
 	ft.FinalizedHeight = height
 	ft.FinalizedHash = hash
+	ft.finalized = rec
 
 	// Add checkpoint if at interval
-	if height%ft.CheckpointInterval == 0 {
+	if checkpoint {
 		ft.Checkpoints[height] = hash
 		fmt.Printf("[Finality] Checkpoint created at height %d\n", height)
 	}
@@ -77,6 +134,27 @@ func (ft *FinalityTracker) GetFinalizedHash() [32]byte {
 	return ft.FinalizedHash
 }
 
+// GetFinalizedRecord returns the latest finalization record
+func (ft *FinalityTracker) GetFinalizedRecord() Record {
+	ft.mu.RLock()
+	defer ft.mu.RUnlock()
+
+	return ft.finalized
+}
+
+// GetCheckpointHeights returns the heights of all checkpoints, ascending
+func (ft *FinalityTracker) GetCheckpointHeights() []uint64 {
+	ft.mu.RLock()
+	defer ft.mu.RUnlock()
+
+	heights := make([]uint64, 0, len(ft.Checkpoints))
+	for height := range ft.Checkpoints {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
 // GetCheckpoint returns checkpoint hash at given height
 func (ft *FinalityTracker) GetCheckpoint(height uint64) ([32]byte, bool) {
 	ft.mu.RLock()
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // PublishVote broadcasts a BFT vote to the network
@@ -37,6 +38,48 @@ func (n *GossipSubNode) PublishProposal(proposal *bft.Proposal) error {
 	return nil
 }
 
+// PublishBlockPart broadcasts one part of a proposed block to the network
+func (n *GossipSubNode) PublishBlockPart(part *bft.BlockPart) error {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block part: %w", err)
+	}
+
+	if err := n.partTopic.Publish(n.ctx, data); err != nil {
+		return fmt.Errorf("failed to publish block part: %w", err)
+	}
+
+	return nil
+}
+
+// PublishEvidence broadcasts double-sign evidence to the network
+func (n *GossipSubNode) PublishEvidence(ev *types.DoubleSignEvidence) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal evidence: %w", err)
+	}
+
+	if err := n.evidenceTopic.Publish(n.ctx, data); err != nil {
+		return fmt.Errorf("failed to publish evidence: %w", err)
+	}
+
+	return nil
+}
+
+// PublishFraudProof broadcasts a shard fraud proof to the network
+func (n *GossipSubNode) PublishFraudProof(proof *types.FraudProof) error {
+	data, err := json.Marshal(proof)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fraud proof: %w", err)
+	}
+
+	if err := n.fraudTopic.Publish(n.ctx, data); err != nil {
+		return fmt.Errorf("failed to publish fraud proof: %w", err)
+	}
+
+	return nil
+}
+
 // ListenForVotes listens for incoming BFT votes
 func (n *GossipSubNode) ListenForVotes(handler func(*bft.Vote)) {
 	go func() {
@@ -90,3 +133,84 @@ func (n *GossipSubNode) ListenForProposals(handler func(*bft.Proposal)) {
 		}
 	}()
 }
+
+// ListenForBlockParts listens for incoming BFT proposal block parts
+func (n *GossipSubNode) ListenForBlockParts(handler func(*bft.BlockPart)) {
+	go func() {
+		for {
+			msg, err := n.partSub.Next(n.ctx)
+			if err != nil {
+				fmt.Printf("[P2P] Block part subscription error: %v\n", err)
+				return
+			}
+
+			// Ignore our own messages
+			if msg.ReceivedFrom == n.host.ID() {
+				continue
+			}
+
+			var part bft.BlockPart
+			if err := json.Unmarshal(msg.Data, &part); err != nil {
+				fmt.Printf("[P2P] Failed to decode block part: %v\n", err)
+				continue
+			}
+
+			// Call handler
+			handler(&part)
+		}
+	}()
+}
+
+// ListenForEvidence listens for incoming double-sign evidence
+func (n *GossipSubNode) ListenForEvidence(handler func(*types.DoubleSignEvidence)) {
+	go func() {
+		for {
+			msg, err := n.evidenceSub.Next(n.ctx)
+			if err != nil {
+				fmt.Printf("[P2P] Evidence subscription error: %v\n", err)
+				return
+			}
+
+			// Ignore our own messages
+			if msg.ReceivedFrom == n.host.ID() {
+				continue
+			}
+
+			var ev types.DoubleSignEvidence
+			if err := json.Unmarshal(msg.Data, &ev); err != nil {
+				fmt.Printf("[P2P] Failed to decode evidence: %v\n", err)
+				continue
+			}
+
+			// Call handler
+			handler(&ev)
+		}
+	}()
+}
+
+// ListenForFraudProofs listens for incoming shard fraud proofs
+func (n *GossipSubNode) ListenForFraudProofs(handler func(*types.FraudProof)) {
+	go func() {
+		for {
+			msg, err := n.fraudSub.Next(n.ctx)
+			if err != nil {
+				fmt.Printf("[P2P] Fraud proof subscription error: %v\n", err)
+				return
+			}
+
+			// Ignore our own messages
+			if msg.ReceivedFrom == n.host.ID() {
+				continue
+			}
+
+			var proof types.FraudProof
+			if err := json.Unmarshal(msg.Data, &proof); err != nil {
+				fmt.Printf("[P2P] Failed to decode fraud proof: %v\n", err)
+				continue
+			}
+
+			// Call handler
+			handler(&proof)
+		}
+	}()
+}
@@ -23,8 +23,11 @@ const (
 	TopicShardPrefix  = "rnr/shard/"       // + shardID (e.g. rnr/shard/0/1.0.0)
 	TopicTransactions = "rnr/transactions/1.0.0"
 	TopicProofs       = "rnr/proofs/1.0.0"
-	TopicVotes        = "rnr/votes/1.0.0"     // BFT votes (prevote/precommit)
-	TopicProposals    = "rnr/proposals/1.0.0" // BFT block proposals
+	TopicVotes        = "rnr/votes/1.0.0"      // BFT votes (prevote/precommit)
+	TopicProposals    = "rnr/proposals/1.0.0"  // BFT block proposals
+	TopicBlockParts   = "rnr/blockparts/1.0.0" // BFT proposal block parts
+	TopicEvidence     = "rnr/evidence/1.0.0"   // Validator misbehaviour evidence
+	TopicFraud        = "rnr/fraud/1.0.0"      // Shard fraud proofs
 )
 
 // GossipSubNode wraps LibP2P host with GossipSub
@@ -40,6 +43,9 @@ type GossipSubNode struct {
 	proofTopic    *pubsub.Topic
 	voteTopic     *pubsub.Topic // BFT votes
 	proposalTopic *pubsub.Topic // BFT proposals
+	partTopic     *pubsub.Topic // BFT proposal block parts
+	evidenceTopic *pubsub.Topic // Slashing evidence
+	fraudTopic    *pubsub.Topic // Shard fraud proofs
 
 	headerSub   *pubsub.Subscription
 	shardSubs   map[int]*pubsub.Subscription
@@ -47,6 +53,9 @@ type GossipSubNode struct {
 	proofSub    *pubsub.Subscription
 	voteSub     *pubsub.Subscription // BFT votes subscription
 	proposalSub *pubsub.Subscription // BFT proposals subscription
+	partSub     *pubsub.Subscription // BFT block parts subscription
+	evidenceSub *pubsub.Subscription // Slashing evidence subscription
+	fraudSub    *pubsub.Subscription // Shard fraud proof subscription
 
 	shardConfig config.ShardConfig
 
@@ -191,6 +200,36 @@ func (n *GossipSubNode) joinTopics() error {
 		return err
 	}
 
+	// Join BFT block parts topic
+	n.partTopic, err = n.pubsub.Join(TopicBlockParts)
+	if err != nil {
+		return err
+	}
+	n.partSub, err = n.partTopic.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	// Join evidence topic
+	n.evidenceTopic, err = n.pubsub.Join(TopicEvidence)
+	if err != nil {
+		return err
+	}
+	n.evidenceSub, err = n.evidenceTopic.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	// Join fraud proof topic
+	n.fraudTopic, err = n.pubsub.Join(TopicFraud)
+	if err != nil {
+		return err
+	}
+	n.fraudSub, err = n.fraudTopic.Subscribe()
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("✅ Subscribed to GossipSub topics (including BFT consensus)")
 	return nil
 }
@@ -363,6 +402,56 @@ func (n *GossipSubNode) GetMempoolShard() []types.Transaction {
 	return txs
 }
 
+// GetMempoolForHeight returns the transactions whose validity window includes height
+func (n *GossipSubNode) GetMempoolForHeight(height uint64) []types.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	txs := make([]types.Transaction, 0, len(n.Mempool))
+	for _, tx := range n.Mempool {
+		if tx.CheckValidityWindow(height) == nil {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// EvictExpired drops transactions that can no longer be included at height
+// Returns the number of evicted transactions.
+func (n *GossipSubNode) EvictExpired(height uint64) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	kept := n.Mempool[:0]
+	for _, tx := range n.Mempool {
+		if !tx.IsExpiredAt(height) {
+			kept = append(kept, tx)
+		}
+	}
+	evicted := len(n.Mempool) - len(kept)
+	n.Mempool = kept
+	if evicted > 0 {
+		fmt.Printf("🗑️ Evicted %d expired transactions from mempool\n", evicted)
+	}
+	return evicted
+}
+
+// RemoveFromMempool removes the given transactions (e.g., after they were included in a block)
+func (n *GossipSubNode) RemoveFromMempool(txs []types.Transaction) {
+	included := make(map[[32]byte]bool, len(txs))
+	for _, tx := range txs {
+		included[tx.ID] = true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	kept := n.Mempool[:0]
+	for _, tx := range n.Mempool {
+		if !included[tx.ID] {
+			kept = append(kept, tx)
+		}
+	}
+	n.Mempool = kept
+}
+
 // ClearMempool wipes the mempool
 func (n *GossipSubNode) ClearMempool() {
 	n.mu.Lock()
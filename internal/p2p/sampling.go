@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/availability"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SampleProtocol is the stream protocol for data availability samples
+// Samples are requested over direct streams rather than gossip, so a producer
+// cannot see which chunks other nodes ask for and answer only those.
+const SampleProtocol = "/rnr/da-sample/1.0.0"
+
+// samplePeerAttempts is how many peers are asked for one chunk before giving up
+const samplePeerAttempts = 3
+
+// sampleReply is the response envelope on a sample stream
+type sampleReply struct {
+	Response *availability.SampleResponse `json:",omitempty"`
+	Error    string                       `json:",omitempty"`
+}
+
+// SetSampleHandler serves data availability samples to peers
+func (n *GossipSubNode) SetSampleHandler(handler func(*availability.SampleRequest) (*availability.SampleResponse, error)) {
+	n.host.SetStreamHandler(SampleProtocol, func(s network.Stream) {
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(params.DataSampleTimeout * time.Second))
+
+		var req availability.SampleRequest
+		if err := json.NewDecoder(io.LimitReader(s, 1024)).Decode(&req); err != nil {
+			s.Reset()
+			return
+		}
+
+		var reply sampleReply
+		resp, err := handler(&req)
+		if err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Response = resp
+		}
+		if err := json.NewEncoder(s).Encode(&reply); err != nil {
+			s.Reset()
+		}
+	})
+}
+
+// RequestSample asks random connected peers for a chunk until one returns it
+// The chunk is not verified here (see availability.VerifySample).
+func (n *GossipSubNode) RequestSample(req *availability.SampleRequest) (*availability.SampleResponse, error) {
+	peers := n.host.Network().Peers()
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no peers to sample from")
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > samplePeerAttempts {
+		peers = peers[:samplePeerAttempts]
+	}
+
+	var lastErr error
+	for _, p := range peers {
+		ctx, cancel := context.WithTimeout(n.ctx, params.DataSampleTimeout*time.Second)
+		resp, err := n.requestSampleFrom(ctx, p, req)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// requestSampleFrom sends req on a new stream to one peer and reads its reply
+func (n *GossipSubNode) requestSampleFrom(ctx context.Context, p peer.ID, req *availability.SampleRequest) (*availability.SampleResponse, error) {
+	s, err := n.host.NewStream(ctx, p, SampleProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(req); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("failed to send sample request: %w", err)
+	}
+	s.CloseWrite()
+
+	var reply sampleReply
+	if err := json.NewDecoder(io.LimitReader(s, params.MaxMessageSize)).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("failed to read sample from %s: %w", p, err)
+	}
+	if reply.Response == nil {
+		return nil, fmt.Errorf("peer %s: %s", p, reply.Error)
+	}
+	return reply.Response, nil
+}
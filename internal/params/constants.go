@@ -8,6 +8,16 @@ const (
 	ShardSize      = 1 * 1024 * 1024  // 1 MB per shard
 	NumShards      = 10               // 10 Shards
 
+	// Sorting race
+	ShardRaceWindow = 2 // Seconds a miner collects shard proofs after revealing its seed
+	ShardDeadline   = 4 // Seconds an aggregator waits for a slot before filling it with an empty shard
+
+	// Data availability
+	DataChunkSize       = 512 // Minimum bytes per erasure-coded chunk
+	MaxDataChunks       = 128 // Data chunks per shard (extended to twice as many)
+	DataSamplesPerShard = 16  // Samples per unvalidated shard (a withheld shard passes with p < 2^-16)
+	DataSampleTimeout   = 5   // Seconds to wait for one sample
+
 	// Tokenomics (5 Billion Supply, 7% Decay / 3.5M Blocks)
 	TotalSupply     = 5000000000
 	InitialReward   = 100.0   // 10 koin x 10 node
@@ -15,10 +25,28 @@ const (
 	DecayRate       = 0.07    // 7%
 
 	// Storage
-	PruningWindow = 100 // Keep 100 blocks (Hardened from 25)
+	PruningWindow    = 100    // Keep 100 blocks (Hardened from 25)
+	AccountCacheSize = 100000 // Max accounts kept in the state LRU cache
+	StateUndoDepth   = 100    // Recent blocks whose state changes can be rolled back
 
 	// Transaction Fees (Anti-Spam)
-	MinTxFee = 1 // Minimum 1 unit (0.000001 RNR) per transaction
+	MinTxFee               = 1 // Minimum 1 unit (0.000001 RNR) per transaction
+	MultiTransferOutputFee = 1 // Additional fee per output of a batch transfer
+
+	// Staking
+	MinValidatorStake = 1000 // Minimum bonded stake to enter the active set
+	MaxValidators     = 100  // Size of the active validator set
+	EpochLength       = 100  // Blocks per validator epoch
+	UnbondingPeriod   = 1000 // Default blocks before unbonded stake is returned (still slashable meanwhile)
+	CommissionChange  = 500  // Max commission rate change per edit (basis points, once per epoch)
+
+	// Slashing
+	DoubleSignSlashPercent = 100 // Share of bonded and unbonding stake burned for double-signing
+	MaxEvidencePerBlock    = 16  // Evidence items a block may carry
+	SignedBlocksWindow     = 200 // Blocks in a validator's liveness window
+	DowntimeThreshold      = 100 // Missed blocks in the window that get a validator jailed
+	DowntimeSlashPercent   = 1   // Share of bonded stake burned for downtime
+	DowntimeJailBlocks     = 600 // Blocks a validator jailed for downtime must wait to unjail
 
 	// Network
 	// Network
@@ -1,12 +1,15 @@
 package rpc
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/blockchain"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/state"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // Server provides JSON-RPC API
@@ -49,9 +52,10 @@ func NewServer(chain *blockchain.Blockchain, state *state.Manager, port string)
 
 // Start starts the RPC server
 func (s *Server) Start() {
-	http.HandleFunc("/", s.handleRequest)
+	mux := http.NewServeMux() // The dashboard owns the default mux
+	mux.HandleFunc("/", s.handleRequest)
 	fmt.Printf("🌐 RPC Server listening on http://localhost:%s\n", s.port)
-	go http.ListenAndServe(":"+s.port, nil)
+	go http.ListenAndServe(":"+s.port, mux)
 }
 
 func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +83,12 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		result, err = s.sendRawTransaction(req.Params)
 	case "eth_getBlockByNumber":
 		result, err = s.getBlockByNumber(req.Params)
+	case "rnr_decodeTransaction":
+		result, err = s.decodeTransaction(req.Params)
+	case "rnr_getValidatorUptime":
+		result, err = s.getValidatorUptime(req.Params)
+	case "rnr_getFinality":
+		result, err = s.getFinality()
 	default:
 		s.sendError(w, -32601, "Method not found", req.ID)
 		return
@@ -126,6 +136,55 @@ func (s *Server) getBlockByNumber(params []interface{}) (interface{}, error) {
 	}, nil
 }
 
+// decodeTransaction explains a JSON-encoded transaction, listing every output
+// of batch transfers together with the fee they will be charged
+func (s *Server) decodeTransaction(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, fmt.Errorf("missing transaction data")
+	}
+	raw, ok := params[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("transaction must be a JSON string")
+	}
+
+	var tx types.Transaction
+	if err := json.Unmarshal([]byte(raw), &tx); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %v", err)
+	}
+
+	outputs, err := tx.Outputs()
+	if err != nil {
+		return nil, err
+	}
+
+	fee := tx.Fee
+	if tx.Type == types.TxTypeMultiTransfer {
+		fee = state.MultiTransferFee(len(outputs))
+	}
+
+	var outList []map[string]interface{}
+	for _, out := range outputs {
+		outInfo := map[string]interface{}{
+			"to":     fmt.Sprintf("0x%x", out.Receiver),
+			"amount": out.Amount,
+		}
+		if !out.IsNative() {
+			outInfo["token"] = fmt.Sprintf("0x%x", out.Token)
+		}
+		outList = append(outList, outInfo)
+	}
+
+	return map[string]interface{}{
+		"hash":    fmt.Sprintf("0x%x", types.HashTransaction(tx)),
+		"type":    tx.Type,
+		"from":    fmt.Sprintf("0x%x", tx.Sender),
+		"nonce":   tx.Nonce,
+		"amount":  tx.Amount,
+		"fee":     fee,
+		"outputs": outList,
+	}, nil
+}
+
 func (s *Server) sendResult(w http.ResponseWriter, result interface{}, id interface{}) {
 	resp := RPCResponse{
 		JSONRPC: "2.0",
@@ -145,3 +204,73 @@ func (s *Server) sendError(w http.ResponseWriter, code int, message string, id i
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// getValidatorUptime reports signing liveness over the last SignedBlocksWindow commits
+// An optional operator or consensus key (hex) selects a single validator.
+func (s *Server) getValidatorUptime(params []interface{}) (interface{}, error) {
+	var filter []byte
+	if len(params) > 0 {
+		str, ok := params[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("validator must be a hex string")
+		}
+		var err error
+		filter, err = hex.DecodeString(strings.TrimPrefix(str, "0x"))
+		if err != nil || len(filter) != 32 {
+			return nil, fmt.Errorf("invalid validator key: %s", str)
+		}
+	}
+
+	uptimes, err := s.state.ValidatorUptimes()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []map[string]interface{}
+	for _, u := range uptimes {
+		if filter != nil && string(filter) != string(u.Operator[:]) && string(filter) != string(u.ConsensusKey[:]) {
+			continue
+		}
+		list = append(list, map[string]interface{}{
+			"operator":     fmt.Sprintf("0x%x", u.Operator),
+			"consensusKey": fmt.Sprintf("0x%x", u.ConsensusKey),
+			"moniker":      u.Moniker,
+			"active":       u.Active,
+			"jailed":       u.Jailed,
+			"jailedUntil":  u.JailedUntil,
+			"counted":      u.Counted,
+			"missedBlocks": u.MissedBlocks,
+			"uptime":       u.Uptime,
+		})
+	}
+	if filter != nil && len(list) == 0 {
+		return nil, fmt.Errorf("validator not found")
+	}
+	return list, nil
+}
+
+// getFinality reports the finalized block, the precommits that justified it and the checkpoints
+func (s *Server) getFinality() (interface{}, error) {
+	ft := s.chain.GetFinalityTracker()
+	rec := ft.GetFinalizedRecord()
+
+	checkpoints := []map[string]interface{}{}
+	for _, height := range ft.GetCheckpointHeights() {
+		hash, _ := ft.GetCheckpoint(height)
+		checkpoints = append(checkpoints, map[string]interface{}{
+			"height": height,
+			"hash":   fmt.Sprintf("0x%x", hash),
+		})
+	}
+
+	result := map[string]interface{}{
+		"finalizedHeight": ft.GetFinalizedHeight(),
+		"finalizedHash":   fmt.Sprintf("0x%x", ft.GetFinalizedHash()),
+		"checkpoints":     checkpoints,
+	}
+	if rec.Commit != nil {
+		result["commitRound"] = rec.Commit.Round
+		result["precommits"] = len(rec.Commit.Signatures)
+	}
+	return result, nil
+}
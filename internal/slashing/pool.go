@@ -0,0 +1,122 @@
+package slashing
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EvidencePool holds verified evidence until a block includes it
+type EvidencePool struct {
+	mu sync.Mutex
+
+	pending map[[32]byte]DoubleSignEvidence // evidence hash -> evidence
+	order   [][32]byte                      // Pending hashes, oldest first
+
+	// Evidence already included in a block (hash -> inclusion height)
+	committed map[[32]byte]uint64
+
+	// Blocks evidence stays executable on-chain (the network's unbonding period)
+	unbondingPeriod uint64
+}
+
+// NewEvidencePool creates an empty evidence pool for a chain with the given unbonding period
+func NewEvidencePool(unbondingPeriod uint64) *EvidencePool {
+	return &EvidencePool{
+		pending:         make(map[[32]byte]DoubleSignEvidence),
+		committed:       make(map[[32]byte]uint64),
+		unbondingPeriod: unbondingPeriod,
+	}
+}
+
+// AddEvidence verifies ev and adds it to the pool
+// Returns false (without error) if the evidence is already known.
+func (p *EvidencePool) AddEvidence(ev DoubleSignEvidence) (bool, error) {
+	if err := ev.Verify(); err != nil {
+		return false, fmt.Errorf("invalid evidence: %w", err)
+	}
+	hash := ev.Hash()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.pending[hash]; ok {
+		return false, nil
+	}
+	if _, ok := p.committed[hash]; ok {
+		return false, nil
+	}
+
+	p.pending[hash] = ev
+	p.order = append(p.order, hash)
+
+	validator := ev.Validator()
+	fmt.Printf("[Slashing] Evidence added to pool: %s by %x at height %d\n", DoubleSign, validator[:4], ev.Height())
+	return true, nil
+}
+
+// PendingEvidence returns up to max pending items, oldest first and at most one per validator
+func (p *EvidencePool) PendingEvidence(max int) []DoubleSignEvidence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var evidence []DoubleSignEvidence
+	seen := make(map[[32]byte]bool)
+	for _, hash := range p.order {
+		if len(evidence) >= max {
+			break
+		}
+		ev := p.pending[hash]
+		if seen[ev.Validator()] {
+			continue
+		}
+		seen[ev.Validator()] = true
+		evidence = append(evidence, ev)
+	}
+	return evidence
+}
+
+// MarkCommitted removes evidence included in the block at height
+// Records older than the unbonding period are forgotten, as such evidence can no longer be executed.
+func (p *EvidencePool) MarkCommitted(evidence []DoubleSignEvidence, height uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range evidence {
+		hash := evidence[i].Hash()
+		p.committed[hash] = height
+		p.removeLocked(hash)
+	}
+
+	for hash, h := range p.committed {
+		if h+p.unbondingPeriod < height {
+			delete(p.committed, hash)
+		}
+	}
+}
+
+// RemoveEvidence drops pending evidence (e.g. rejected by the chain state)
+func (p *EvidencePool) RemoveEvidence(ev DoubleSignEvidence) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(ev.Hash())
+}
+
+// Size returns the number of pending evidence items
+func (p *EvidencePool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+func (p *EvidencePool) removeLocked(hash [32]byte) {
+	if _, ok := p.pending[hash]; !ok {
+		return
+	}
+	delete(p.pending, hash)
+	for i, h := range p.order {
+		if h == hash {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
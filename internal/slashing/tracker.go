@@ -4,6 +4,9 @@ import (
 	"crypto/ed25519"
 	"fmt"
 	"sync"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 // SlashingCondition represents the type of slashable offense
@@ -35,19 +38,11 @@ type Evidence struct {
 }
 
 // DoubleSignEvidence proves that a validator signed two different blocks at same height
-type DoubleSignEvidence struct {
-	Vote1 Vote // First conflicting vote
-	Vote2 Vote // Second conflicting vote
-}
+// It is the evidence type carried in block bodies.
+type DoubleSignEvidence = types.DoubleSignEvidence
 
-// Vote represents a simplified vote for evidence (matches bft.Vote structure)
-type Vote struct {
-	Height           uint64
-	Round            int32
-	BlockHash        [32]byte
-	Signature        [64]byte
-	ValidatorAddress [32]byte
-}
+// Vote is a signed vote as carried by evidence (matches bft.Vote structure)
+type Vote = types.EvidenceVote
 
 // DowntimeEvidence proves that a validator missed too many votes
 type DowntimeEvidence struct {
@@ -88,9 +83,9 @@ func NewSlashingTracker() *SlashingTracker {
 		slashed:  make(map[[32]byte]SlashInfo),
 
 		// Default slashing amounts
-		DoubleSignSlashAmount: 100, // 100% stake slashed (tombstoned)
-		DowntimeSlashAmount:   1,   // 1% stake slashed (warning)
-		DowntimeThreshold:     100, // Miss 100 votes in window
+		DoubleSignSlashAmount: params.DoubleSignSlashPercent, // Stake slashed (tombstoned)
+		DowntimeSlashAmount:   params.DowntimeSlashPercent,   // Stake slashed (warning)
+		DowntimeThreshold:     params.DowntimeThreshold,      // Missed votes in window
 	}
 }
 
@@ -178,34 +173,16 @@ func (st *SlashingTracker) GetEvidence(validator [32]byte) []Evidence {
 	return st.evidence[validator]
 }
 
-// VerifyDoubleSignEvidence verifies double-sign evidence
+// VerifyDoubleSignEvidence verifies double-sign evidence against the validator's public key
 func VerifyDoubleSignEvidence(evidence DoubleSignEvidence, pubKey ed25519.PublicKey) bool {
-	// Check same height, different blocks
-	if evidence.Vote1.Height != evidence.Vote2.Height {
-		return false
-	}
-
-	if evidence.Vote1.BlockHash == evidence.Vote2.BlockHash {
-		return false
-	}
-
-	// Check same validator
-	if evidence.Vote1.ValidatorAddress != evidence.Vote2.ValidatorAddress {
+	// The validator address is its consensus key
+	validator := evidence.Validator()
+	if len(pubKey) != ed25519.PublicKeySize || string(pubKey) != string(validator[:]) {
 		return false
 	}
 
-	// Verify signatures (simplified - in real impl would verify full vote structure)
-	// vote1Bytes := serializeVote(evidence.Vote1)
-	// vote2Bytes := serializeVote(evidence.Vote2)
-	//
-	// if !ed25519.Verify(pubKey, vote1Bytes, evidence.Vote1.Signature[:]) {
-	// 	return false
-	// }
-	// if !ed25519.Verify(pubKey, vote2Bytes, evidence.Vote2.Signature[:]) {
-	// 	return false
-	// }
-
-	return true
+	// Same height/round/step, different blocks, both signatures valid
+	return evidence.Verify() == nil
 }
 
 // VerifyDowntimeEvidence verifies downtime evidence
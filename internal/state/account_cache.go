@@ -0,0 +1,62 @@
+package state
+
+import (
+	"container/list"
+)
+
+// accountCache is a size-bounded LRU cache of committed account states.
+// It is not safe for concurrent use; Manager guards it with its own mutex.
+type accountCache struct {
+	capacity int
+	entries  map[[32]byte]*list.Element
+	order    *list.List // Front = most recently used
+}
+
+type accountCacheEntry struct {
+	pubkey  [32]byte
+	account Account
+}
+
+// newAccountCache creates an LRU cache holding at most capacity accounts
+func newAccountCache(capacity int) *accountCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &accountCache{
+		capacity: capacity,
+		entries:  make(map[[32]byte]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns a copy of the cached account and marks it as recently used
+func (c *accountCache) get(pubkey [32]byte) (Account, bool) {
+	elem, ok := c.entries[pubkey]
+	if !ok {
+		return Account{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*accountCacheEntry).account, true
+}
+
+// put stores a copy of the account, evicting the least recently used entry if full
+func (c *accountCache) put(pubkey [32]byte, acc Account) {
+	if elem, ok := c.entries[pubkey]; ok {
+		elem.Value.(*accountCacheEntry).account = acc
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[pubkey] = c.order.PushFront(&accountCacheEntry{pubkey: pubkey, account: acc})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*accountCacheEntry).pubkey)
+	}
+}
+
+// len returns the number of cached accounts
+func (c *accountCache) len() int {
+	return c.order.Len()
+}
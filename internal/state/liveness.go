@@ -0,0 +1,137 @@
+package state
+
+import (
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// ValidatorUptime summarizes a validator's liveness for monitoring
+type ValidatorUptime struct {
+	Operator     [32]byte
+	ConsensusKey [32]byte
+	Moniker      string
+	Active       bool // Member of the current epoch's active set
+	Jailed       bool
+	JailedUntil  uint64  // Earliest height an unjail is accepted
+	Counted      uint64  // Commits in the window so far (at most SignedBlocksWindow)
+	MissedBlocks uint64  // Commits missed within the window
+	Uptime       float64 // Share of counted commits signed (1 before any are counted)
+}
+
+// UpdateLiveness records which active validators signed lastCommit, the previous block's commit
+// A validator that misses DowntimeThreshold of the last SignedBlocksWindow commits
+// is jailed and slashed (see jailForDowntime). Commits are taken from the block
+// being applied, so every node counts the same signatures.
+func (m *Manager) UpdateLiveness(lastCommit *types.Commit) error {
+	if lastCommit == nil {
+		return nil
+	}
+	epoch, err := m.stakingState.ActiveEpoch()
+	if err != nil || epoch == nil {
+		return err
+	}
+
+	signed := make(map[[32]byte]bool, len(lastCommit.Signatures))
+	for _, sig := range lastCommit.Signatures {
+		vote := lastCommit.Vote(sig)
+		if vote.VerifySignature() {
+			signed[sig.ValidatorAddress] = true
+		}
+	}
+
+	height := m.currentHeight()
+	validators := append([]ActiveValidator(nil), epoch.Validators...) // Jailing restages the epoch
+	for _, v := range validators {
+		info, err := m.stakingState.GetSigningInfo(v.Operator)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			// Start with the next commit: this one may predate joining the set
+			m.stakingState.stageSigningInfo(v.Operator, newSigningInfo(lastCommit.Height+1))
+			continue
+		}
+		if lastCommit.Height < info.StartHeight {
+			continue
+		}
+
+		idx := info.IndexOffset % params.SignedBlocksWindow
+		wasMissed := info.Missed[idx/8]&(1<<(idx%8)) != 0
+		missed := !signed[v.ConsensusKey]
+		switch {
+		case missed && !wasMissed:
+			info.Missed[idx/8] |= 1 << (idx % 8)
+			info.MissedBlocks++
+		case !missed && wasMissed:
+			info.Missed[idx/8] &^= 1 << (idx % 8)
+			info.MissedBlocks--
+		}
+		info.IndexOffset++
+
+		// Only judged once a full window has been tracked
+		if missed && info.IndexOffset >= params.SignedBlocksWindow && info.MissedBlocks >= params.DowntimeThreshold {
+			if err := m.jailForDowntime(v.Operator, height); err != nil {
+				return err
+			}
+			info = newSigningInfo(height + 1)
+		}
+		m.stakingState.stageSigningInfo(v.Operator, info)
+	}
+	return nil
+}
+
+// newSigningInfo returns an empty liveness record starting at startHeight
+func newSigningInfo(startHeight uint64) *SigningInfo {
+	return &SigningInfo{
+		StartHeight: startHeight,
+		Missed:      make([]byte, (params.SignedBlocksWindow+7)/8),
+	}
+}
+
+// ValidatorUptimes returns the liveness of every validator, sorted by operator
+func (m *Manager) ValidatorUptimes() ([]ValidatorUptime, error) {
+	records, err := m.stakingState.Validators()
+	if err != nil {
+		return nil, err
+	}
+	epoch, err := m.stakingState.ActiveEpoch()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[[32]byte]bool)
+	if epoch != nil {
+		for _, v := range epoch.Validators {
+			active[v.Operator] = true
+		}
+	}
+
+	uptimes := make([]ValidatorUptime, 0, len(records))
+	for _, rec := range records {
+		u := ValidatorUptime{
+			Operator:     rec.Operator,
+			ConsensusKey: rec.ConsensusKey,
+			Moniker:      rec.Moniker,
+			Active:       active[rec.Operator],
+			Jailed:       rec.Jailed,
+			JailedUntil:  rec.JailedUntil,
+			Uptime:       1,
+		}
+
+		info, err := m.stakingState.GetSigningInfo(rec.Operator)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			u.Counted = info.IndexOffset
+			if u.Counted > params.SignedBlocksWindow {
+				u.Counted = params.SignedBlocksWindow
+			}
+			u.MissedBlocks = info.MissedBlocks
+			if u.Counted > 0 {
+				u.Uptime = float64(u.Counted-u.MissedBlocks) / float64(u.Counted)
+			}
+		}
+		uptimes = append(uptimes, u)
+	}
+	return uptimes, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -13,42 +14,78 @@ import (
 type Account struct {
 	Balance uint64
 	Nonce   uint64
+
+	// Key rotation: current signing key (nil = the account identifier itself)
+	// and the keys it replaced, oldest first
+	PubKey     *[32]byte  `json:",omitempty"`
+	KeyHistory [][32]byte `json:",omitempty"`
+}
+
+// BoundKey returns the public key allowed to sign for the account identified by id
+func (a *Account) BoundKey(id [32]byte) [32]byte {
+	if a.PubKey != nil {
+		return *a.PubKey
+	}
+	return id
 }
 
 // Manager manages account state, contracts, and tokens
 type Manager struct {
 	db    *leveldb.DB
-	cache map[[32]byte]*Account
+	cache *accountCache         // Bounded LRU of committed accounts
+	dirty map[[32]byte]*Account // Uncommitted changes of the block being applied
 	mu    sync.RWMutex
 
 	// Sub-managers
 	contractState *ContractState
 	tokenState    *TokenState
+	multisigState *MultisigState
+	stakingState  *StakingState
 	executor      interface{} // vm.ContractExecutor (avoid circular import)
+
+	height          uint64 // Height of the block being applied (set by BeginBlock)
+	unbondingPeriod uint64 // Blocks unbonded stake stays locked (SetUnbondingPeriod)
 }
 
 // NewManager creates a new state manager
 func NewManager(db *leveldb.DB) *Manager {
+	return NewManagerWithCacheSize(db, params.AccountCacheSize)
+}
+
+// NewManagerWithCacheSize creates a state manager whose account cache holds at most cacheSize entries
+func NewManagerWithCacheSize(db *leveldb.DB, cacheSize int) *Manager {
 	contractState := NewContractState(db)
 	tokenState := NewTokenState(db)
+	multisigState := NewMultisigState(db)
 
 	return &Manager{
-		db:            db,
-		cache:         make(map[[32]byte]*Account),
-		contractState: contractState,
-		tokenState:    tokenState,
-		executor:      nil, // Set later via SetExecutor to avoid circular import
+		db:              db,
+		cache:           newAccountCache(cacheSize),
+		dirty:           make(map[[32]byte]*Account),
+		contractState:   contractState,
+		tokenState:      tokenState,
+		multisigState:   multisigState,
+		stakingState:    NewStakingState(db),
+		executor:        nil, // Set later via SetExecutor to avoid circular import
+		unbondingPeriod: params.UnbondingPeriod,
 	}
 }
 
 // GetAccount retrieves account state
+// The returned account is a copy: mutating it has no effect until UpdateAccount is called.
 func (m *Manager) GetAccount(pubkey [32]byte) (*Account, error) {
-	m.mu.RLock()
-	if acc, ok := m.cache[pubkey]; ok {
-		m.mu.RUnlock()
-		return acc, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Pending changes of the current block take precedence
+	if acc, ok := m.dirty[pubkey]; ok {
+		accCopy := *acc
+		return &accCopy, nil
+	}
+
+	if acc, ok := m.cache.get(pubkey); ok {
+		return &acc, nil
 	}
-	m.mu.RUnlock()
 
 	// Load from DB
 	key := append([]byte("account-"), pubkey[:]...)
@@ -67,23 +104,113 @@ func (m *Manager) GetAccount(pubkey [32]byte) (*Account, error) {
 	}
 
 	// Cache it
-	m.mu.Lock()
-	m.cache[pubkey] = &acc
-	m.mu.Unlock()
+	m.cache.put(pubkey, acc)
 
 	return &acc, nil
 }
 
-// UpdateAccount saves account state
+// UpdateAccount stages account state in the dirty set
+// Changes are only written to disk by Commit and are dropped by Discard.
 func (m *Manager) UpdateAccount(pubkey [32]byte, acc *Account) error {
+	if acc == nil {
+		return fmt.Errorf("nil account for %x", pubkey[:4])
+	}
+
 	m.mu.Lock()
-	m.cache[pubkey] = acc
-	m.mu.Unlock()
+	defer m.mu.Unlock()
 
-	// Persist to DB
-	key := append([]byte("account-"), pubkey[:]...)
-	data, _ := json.Marshal(acc)
-	return m.db.Put(key, data, nil)
+	accCopy := *acc
+	m.dirty[pubkey] = &accCopy
+	return nil
+}
+
+// Commit persists all staged account changes atomically and moves them into the cache
+func (m *Manager) Commit() error {
+	return m.CommitWith(nil)
+}
+
+// CommitWith is Commit with writes (e.g. the block the changes belong to) added
+// to the same batch, so chain data and state are persisted together or not at all
+func (m *Manager) CommitWith(writes *leveldb.Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch := new(leveldb.Batch)
+	for pubkey, acc := range m.dirty {
+		key := append([]byte("account-"), pubkey[:]...)
+		data, err := json.Marshal(acc)
+		if err != nil {
+			return fmt.Errorf("failed to encode account %x: %v", pubkey[:4], err)
+		}
+		batch.Put(key, data)
+	}
+
+	promoteMultisig, err := m.multisigState.stageCommit(batch)
+	if err != nil {
+		return err
+	}
+	promoteTokens, err := m.tokenState.stageCommit(batch)
+	if err != nil {
+		return err
+	}
+	promoteStaking, err := m.stakingState.stageCommit(batch, m.height)
+	if err != nil {
+		return err
+	}
+
+	// Keep the pre-images so the block can be rolled back (fraud proofs)
+	if batch.Len() > 0 {
+		if err := m.stageUndo(batch, m.height); err != nil {
+			return err
+		}
+	}
+	if writes != nil {
+		if err := writes.Replay(batch); err != nil {
+			return err
+		}
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	if err := m.db.Write(batch, nil); err != nil {
+		return err
+	}
+	promoteMultisig()
+	promoteTokens()
+	promoteStaking()
+
+	for pubkey, acc := range m.dirty {
+		m.cache.put(pubkey, *acc)
+	}
+	m.dirty = make(map[[32]byte]*Account)
+
+	return nil
+}
+
+// Discard drops all staged account changes (e.g. when a block fails to apply)
+func (m *Manager) Discard() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dirty = make(map[[32]byte]*Account)
+	m.multisigState.discard()
+	m.tokenState.discard()
+	m.stakingState.discard()
+}
+
+// DirtyCount returns the number of accounts with uncommitted changes
+func (m *Manager) DirtyCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.dirty)
+}
+
+// CachedCount returns the number of accounts held in the LRU cache
+func (m *Manager) CachedCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cache.len()
 }
 
 // ApplyTransaction validates and applies a transaction to state
@@ -110,37 +237,193 @@ func (m *Manager) ApplyTransaction(tx types.Transaction) error {
 			return fmt.Errorf("invalid nonce: expected %d, got %d", sender.Nonce+1, tx.Nonce)
 		}
 
-		// Check balance
-		if sender.Balance < tx.Amount {
+		// Check balance (unbonding Amount comes out of stake, not balance)
+		if !types.PaidFromStake(tx.Type) && sender.Balance < tx.Amount {
 			return fmt.Errorf("insufficient balance: has %d, needs %d", sender.Balance, tx.Amount)
 		}
 	}
 
+	// Block rewards of a bonded validator are shared with its delegators
+	if isCoinbase && tx.Type == types.TxTypeRNRTransfer {
+		operator, ok, err := m.stakingState.OperatorOf(tx.Receiver)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return m.allocateReward(operator, tx.Amount)
+		}
+	}
+
+	// Batch transfers pay their outputs instead of Receiver
+	if tx.Type == types.TxTypeMultiTransfer {
+		return m.applyMultiTransfer(tx, sender)
+	}
+
+	// Key rotation only rebinds the signing key
+	if tx.Type == types.TxTypeKeyRotate {
+		return m.applyKeyRotate(tx, sender)
+	}
+
+	// Staking moves funds between balance and stake
+	if types.IsStakingTx(tx.Type) {
+		return m.applyStaking(tx, sender)
+	}
+
+	// Register multisig policy (Receiver must be the derived address)
+	if tx.Type == types.TxTypeMultisigRegister {
+		if err := m.applyMultisigRegister(tx); err != nil {
+			return err
+		}
+	}
+
+	// Debit sender first: accounts are copies, so the receiver must be
+	// loaded after the sender is staged (handles Sender == Receiver)
+	sender.Balance -= tx.Amount
+	sender.Nonce++
+	if err := m.UpdateAccount(tx.Sender, sender); err != nil {
+		return err
+	}
+
 	// Get receiver account
 	receiver, err := m.GetAccount(tx.Receiver)
 	if err != nil {
 		return err
 	}
+	receiver.Balance += tx.Amount
 
-	// Apply changes
-	sender.Balance -= tx.Amount
+	return m.UpdateAccount(tx.Receiver, receiver)
+}
+
+// GetContractState returns the contract state manager
+func (m *Manager) GetContractState() *ContractState {
+	return m.contractState
+}
+
+// applyMultisigRegister stages the policy carried in a TxTypeMultisigRegister payload
+func (m *Manager) applyMultisigRegister(tx types.Transaction) error {
+	var payload types.MultisigRegisterPayload
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid multisig register payload: %v", err)
+	}
+
+	policy := &types.MultisigPolicy{Threshold: payload.Threshold, PubKeys: payload.PubKeys}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if policy.Address() != tx.Receiver {
+		return fmt.Errorf("multisig register receiver does not match policy address")
+	}
+
+	_, err := m.multisigState.RegisterPolicy(policy)
+	return err
+}
+
+// applyKeyRotate binds the Sender account to the key carried in a TxTypeKeyRotate payload
+func (m *Manager) applyKeyRotate(tx types.Transaction, sender *Account) error {
+	payload, err := types.DecodeKeyRotate(tx)
+	if err != nil {
+		return err
+	}
+	if m.multisigState.HasPolicy(tx.Sender) {
+		return fmt.Errorf("multisig accounts cannot rotate keys")
+	}
+
+	oldKey := sender.BoundKey(tx.Sender)
+	newKey := payload.NewPubKey
+
+	// Copy history: the account may share its backing array with the cache
+	history := make([][32]byte, 0, len(sender.KeyHistory)+1)
+	history = append(history, sender.KeyHistory...)
+	sender.KeyHistory = append(history, oldKey)
+	sender.PubKey = &newKey
 	sender.Nonce++
-	receiver.Balance += tx.Amount
 
-	// Save both accounts
-	if err := m.UpdateAccount(tx.Sender, sender); err != nil {
+	return m.UpdateAccount(tx.Sender, sender)
+}
+
+// MultiTransferFee returns the fee charged for a batch transfer with n outputs
+func MultiTransferFee(n int) uint64 {
+	return params.MinTxFee + uint64(n)*params.MultiTransferOutputFee
+}
+
+// applyMultiTransfer pays every output of a TxTypeMultiTransfer batch
+// All balances are checked before anything is staged, so the batch applies
+// completely or not at all. The size-scaled fee is burned.
+func (m *Manager) applyMultiTransfer(tx types.Transaction, sender *Account) error {
+	payload, err := types.DecodeMultiTransfer(tx)
+	if err != nil {
 		return err
 	}
-	if err := m.UpdateAccount(tx.Receiver, receiver); err != nil {
+
+	if tx.Sender == [32]byte{} {
+		return fmt.Errorf("coinbase cannot be a multi-transfer")
+	}
+
+	// 1. Check RNR balance (native outputs + fee)
+	fee := MultiTransferFee(len(payload.Outputs))
+	if tx.Amount+fee < tx.Amount || sender.Balance < tx.Amount+fee {
+		return fmt.Errorf("insufficient balance: has %d, needs %d (incl. fee %d)",
+			sender.Balance, tx.Amount+fee, fee)
+	}
+
+	// 2. Check token balances
+	tokenTotals := make(map[[32]byte]uint64)
+	for _, out := range payload.Outputs {
+		if out.IsNative() {
+			continue
+		}
+		total := tokenTotals[out.Token] + out.Amount
+		if total < out.Amount {
+			return fmt.Errorf("token output total overflows for %x", out.Token[:4])
+		}
+		tokenTotals[out.Token] = total
+	}
+	for tokenAddr, total := range tokenTotals {
+		if balance := m.tokenState.GetBalance(tokenAddr, tx.Sender); balance < total {
+			return fmt.Errorf("insufficient token balance for %x: has %d, needs %d",
+				tokenAddr[:4], balance, total)
+		}
+	}
+
+	// 3. Debit sender (single nonce increment)
+	sender.Balance -= tx.Amount + fee
+	sender.Nonce++
+	if err := m.UpdateAccount(tx.Sender, sender); err != nil {
 		return err
 	}
+	for tokenAddr, total := range tokenTotals {
+		m.tokenState.stageBalance(tokenAddr, tx.Sender, m.tokenState.GetBalance(tokenAddr, tx.Sender)-total)
+	}
+
+	// 4. Credit outputs
+	for _, out := range payload.Outputs {
+		if !out.IsNative() {
+			balance := m.tokenState.GetBalance(out.Token, out.Receiver)
+			m.tokenState.stageBalance(out.Token, out.Receiver, balance+out.Amount)
+			continue
+		}
+
+		receiver, err := m.GetAccount(out.Receiver)
+		if err != nil {
+			return err
+		}
+		receiver.Balance += out.Amount
+		if err := m.UpdateAccount(out.Receiver, receiver); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// GetContractState returns the contract state manager
-func (m *Manager) GetContractState() *ContractState {
-	return m.contractState
+// GetMultisigState returns the multisig policy manager
+func (m *Manager) GetMultisigState() *MultisigState {
+	return m.multisigState
+}
+
+// GetStakingState returns the staking state manager
+func (m *Manager) GetStakingState() *StakingState {
+	return m.stakingState
 }
 
 // GetTokenState returns the token state manager
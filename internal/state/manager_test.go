@@ -0,0 +1,531 @@
+package state
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func newTestManager(t *testing.T, cacheSize int) *Manager {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewManagerWithCacheSize(db, cacheSize)
+}
+
+func TestAccountCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newAccountCache(2)
+	c.put([32]byte{1}, Account{Balance: 1})
+	c.put([32]byte{2}, Account{Balance: 2})
+
+	// Touch 1 so that 2 becomes the eviction candidate
+	c.get([32]byte{1})
+	c.put([32]byte{3}, Account{Balance: 3})
+
+	if c.len() != 2 {
+		t.Fatalf("expected 2 cached accounts, got %d", c.len())
+	}
+	if _, ok := c.get([32]byte{2}); ok {
+		t.Error("least recently used account should have been evicted")
+	}
+	if _, ok := c.get([32]byte{1}); !ok {
+		t.Error("recently used account should still be cached")
+	}
+}
+
+func TestGetAccountReturnsCopy(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice := [32]byte{0xa}
+
+	if err := m.UpdateAccount(alice, &Account{Balance: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	acc, _ := m.GetAccount(alice)
+	acc.Balance = 0 // Mutation without UpdateAccount must not leak
+
+	again, _ := m.GetAccount(alice)
+	if again.Balance != 100 {
+		t.Errorf("cached balance corrupted: got %d, want 100", again.Balance)
+	}
+}
+
+func TestDiscardDropsFailedBlock(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice, bob := [32]byte{0xa}, [32]byte{0xb}
+
+	m.UpdateAccount(alice, &Account{Balance: 100})
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// First tx succeeds, second fails (bad nonce) -> block is discarded
+	tx1 := types.Transaction{Sender: alice, Receiver: bob, Amount: 40, Nonce: 1}
+	tx2 := types.Transaction{Sender: alice, Receiver: bob, Amount: 40, Nonce: 5}
+	if err := m.ApplyTransaction(tx1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyTransaction(tx2); err == nil {
+		t.Fatal("expected nonce error")
+	}
+	m.Discard()
+
+	acc, _ := m.GetAccount(alice)
+	if acc.Balance != 100 || acc.Nonce != 0 {
+		t.Errorf("discarded changes leaked: balance %d nonce %d", acc.Balance, acc.Nonce)
+	}
+	if m.DirtyCount() != 0 {
+		t.Errorf("dirty set not cleared: %d entries", m.DirtyCount())
+	}
+}
+
+func TestCommitPersistsBeyondCache(t *testing.T) {
+	m := newTestManager(t, 1)
+	alice, bob := [32]byte{0xa}, [32]byte{0xb}
+
+	m.UpdateAccount(alice, &Account{Balance: 7})
+	m.UpdateAccount(bob, &Account{Balance: 9})
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.CachedCount() != 1 {
+		t.Errorf("cache exceeded capacity: %d entries", m.CachedCount())
+	}
+
+	// Both must be readable regardless of which one was evicted
+	a, _ := m.GetAccount(alice)
+	b, _ := m.GetAccount(bob)
+	if a.Balance != 7 || b.Balance != 9 {
+		t.Errorf("unexpected balances after eviction: alice %d bob %d", a.Balance, b.Balance)
+	}
+}
+
+func TestRollbackRestoresPreviousBlock(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice, bob := [32]byte{0xa}, [32]byte{0xb}
+
+	m.BeginBlock(1)
+	m.UpdateAccount(alice, &Account{Balance: 100})
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.BeginBlock(2)
+	m.UpdateAccount(alice, &Account{Balance: 60, Nonce: 1})
+	m.UpdateAccount(bob, &Account{Balance: 40})
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := m.GetAccount(alice)
+	b, _ := m.GetAccount(bob)
+	if a.Balance != 100 || a.Nonce != 0 {
+		t.Errorf("alice not restored: balance %d nonce %d", a.Balance, a.Nonce)
+	}
+	if b.Balance != 0 {
+		t.Errorf("bob should not exist after rollback, balance %d", b.Balance)
+	}
+	if _, err := m.db.Get(append([]byte("account-"), bob[:]...), nil); err != leveldb.ErrNotFound {
+		t.Errorf("bob's account should be deleted from disk, got %v", err)
+	}
+}
+
+func TestMultiTransferIsAtomic(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice, bob, carol := [32]byte{0xa}, [32]byte{0xb}, [32]byte{0xc}
+	token := [32]byte{0x70}
+
+	m.UpdateAccount(alice, &Account{Balance: 100})
+	m.tokenState.stageBalance(token, alice, 5)
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	newBatch := func(tokenAmount uint64) types.Transaction {
+		payload, _ := json.Marshal(types.MultiTransferPayload{Outputs: []types.TransferOutput{
+			{Receiver: bob, Amount: 30},
+			{Receiver: carol, Amount: 20},
+			{Receiver: carol, Amount: tokenAmount, Token: token},
+		}})
+		return types.Transaction{Type: types.TxTypeMultiTransfer, Sender: alice, Amount: 50, Nonce: 1, Payload: payload}
+	}
+
+	// Token output exceeds balance: nothing may be applied
+	if err := m.ApplyTransaction(newBatch(6)); err == nil {
+		t.Fatal("expected insufficient token balance")
+	}
+	if acc, _ := m.GetAccount(bob); acc.Balance != 0 {
+		t.Fatalf("partial batch applied: bob has %d", acc.Balance)
+	}
+
+	if err := m.ApplyTransaction(newBatch(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	fee := MultiTransferFee(3)
+	a, _ := m.GetAccount(alice)
+	b, _ := m.GetAccount(bob)
+	c, _ := m.GetAccount(carol)
+	if a.Balance != 100-50-fee || a.Nonce != 1 {
+		t.Errorf("sender: balance %d nonce %d", a.Balance, a.Nonce)
+	}
+	if b.Balance != 30 || c.Balance != 20 {
+		t.Errorf("receivers: bob %d carol %d", b.Balance, c.Balance)
+	}
+	if got := m.tokenState.GetBalance(token, carol); got != 5 {
+		t.Errorf("carol token balance %d, want 5", got)
+	}
+	if got := m.tokenState.GetBalance(token, alice); got != 0 {
+		t.Errorf("alice token balance %d, want 0", got)
+	}
+}
+
+func TestKeyRotationRebindsAccount(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice := [32]byte{0xa}
+	key1, key2 := [32]byte{0x1}, [32]byte{0x2}
+
+	rotate := func(signer, newKey [32]byte, nonce uint64) error {
+		payload, _ := json.Marshal(types.KeyRotatePayload{NewPubKey: newKey})
+		return m.ApplyTransaction(types.Transaction{
+			Type: types.TxTypeKeyRotate, Sender: alice, PubKey: signer, Nonce: nonce, Payload: payload,
+		})
+	}
+
+	if err := rotate([32]byte{}, key1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotate(key1, key2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	acc, _ := m.GetAccount(alice)
+	if acc.BoundKey(alice) != key2 {
+		t.Errorf("account bound to %x, want %x", acc.BoundKey(alice), key2)
+	}
+	if len(acc.KeyHistory) != 2 || acc.KeyHistory[0] != alice || acc.KeyHistory[1] != key1 {
+		t.Errorf("unexpected key history: %x", acc.KeyHistory)
+	}
+	if acc.Nonce != 2 {
+		t.Errorf("nonce %d, want 2", acc.Nonce)
+	}
+}
+
+func TestStakingBondUnbondAndEpoch(t *testing.T) {
+	m := newTestManager(t, 10)
+	alice, bob := [32]byte{0xa}, [32]byte{0xb}
+	aliceKey := [32]byte{0x1}
+	m.UpdateAccount(alice, &Account{Balance: 5000})
+	m.UpdateAccount(bob, &Account{Balance: 5000})
+
+	bond := func(sender, key [32]byte, amount, nonce uint64) error {
+		payload, _ := json.Marshal(types.StakeBondPayload{ConsensusKey: key})
+		return m.ApplyTransaction(types.Transaction{
+			Type: types.TxTypeStakeBond, Sender: sender, Amount: amount, Nonce: nonce, Payload: payload,
+		})
+	}
+
+	m.BeginBlock(1)
+	if err := bond(alice, aliceKey, 2000, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bond(bob, aliceKey, 2000, 1); err == nil {
+		t.Fatal("bond reusing another validator's consensus key should fail")
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if acc, _ := m.GetAccount(alice); acc.Balance != 3000 {
+		t.Errorf("alice balance %d after bond, want 3000", acc.Balance)
+	}
+
+	// The consensus key index is rebuilt when staking state is reloaded from disk
+	m.GetStakingState().reset()
+	if op, ok, err := m.GetStakingState().OperatorOf(aliceKey); err != nil || !ok || op != alice {
+		t.Errorf("consensus key maps to %x (%v, %v), want alice", op[:4], ok, err)
+	}
+
+	m.BeginBlock(params.EpochLength)
+	if err := m.EndBlock(params.EpochLength); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	epoch, err := m.GetStakingState().ActiveEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epoch == nil || len(epoch.Validators) != 1 || epoch.Validators[0].ConsensusKey != aliceKey || epoch.Validators[0].Power != 2000 {
+		t.Fatalf("unexpected epoch: %+v", epoch)
+	}
+	if history, err := m.GetStakingState().EpochHistory(); err != nil || len(history) != 1 || history[0].From != params.EpochLength+1 {
+		t.Errorf("epoch not recorded as validating from the block after its boundary: %+v (%v)", history, err)
+	}
+
+	// Unbonding below the minimum drops alice from the next epoch; funds return once matured
+	unbondHeight := uint64(params.EpochLength + 1)
+	m.BeginBlock(unbondHeight)
+	if err := m.ApplyTransaction(types.Transaction{
+		Type: types.TxTypeStakeUnbond, Sender: alice, Amount: 1500, Nonce: 2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	nextEpoch := uint64(2 * params.EpochLength)
+	if err := m.EndBlock(nextEpoch); err != nil {
+		t.Fatal(err)
+	}
+	m.Commit()
+	if epoch, _ := m.GetStakingState().ActiveEpoch(); len(epoch.Validators) != 0 {
+		t.Errorf("expected empty active set, got %d validators", len(epoch.Validators))
+	}
+	if acc, _ := m.GetAccount(alice); acc.Balance != 3000 {
+		t.Errorf("unbonded stake released early: balance %d", acc.Balance)
+	}
+
+	matured := (unbondHeight + params.UnbondingPeriod + params.EpochLength - 1) / params.EpochLength * params.EpochLength
+	if err := m.EndBlock(matured); err != nil {
+		t.Fatal(err)
+	}
+	m.Commit()
+	if acc, _ := m.GetAccount(alice); acc.Balance != 4500 {
+		t.Errorf("alice balance %d after unbonding matured, want 4500", acc.Balance)
+	}
+	if op, ok, _ := m.GetStakingState().OperatorOf(aliceKey); !ok || op != alice {
+		t.Error("unbonded validator lost its consensus key (its evidence must stay executable)")
+	}
+}
+
+func TestDelegationRewardsAndUndelegation(t *testing.T) {
+	m := newTestManager(t, 10)
+	m.SetUnbondingPeriod(50)
+	operator, delegator := [32]byte{0xa}, [32]byte{0xd}
+	consensusKey := [32]byte{0x1}
+	m.UpdateAccount(operator, &Account{Balance: 5000})
+	m.UpdateAccount(delegator, &Account{Balance: 5000})
+
+	bondPayload, _ := json.Marshal(types.StakeBondPayload{ConsensusKey: consensusKey, CommissionRate: 1000})
+	delegation, _ := json.Marshal(types.DelegationPayload{Validator: operator})
+
+	m.BeginBlock(1)
+	txs := []types.Transaction{
+		{Type: types.TxTypeStakeBond, Sender: operator, Amount: 1000, Nonce: 1, Payload: bondPayload},
+		{Type: types.TxTypeDelegate, Sender: delegator, Amount: 3000, Nonce: 1, Payload: delegation},
+		// Block reward paid to the validator's consensus key: 10% commission, rest split 1:3
+		{Type: types.TxTypeRNRTransfer, Receiver: consensusKey, Amount: 1000},
+	}
+	for _, tx := range txs {
+		if err := m.ApplyTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.EndBlock(params.EpochLength); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	ss := m.GetStakingState()
+	if epoch, _ := ss.ActiveEpoch(); len(epoch.Validators) != 1 || epoch.Validators[0].Power != 4000 {
+		t.Fatalf("unexpected epoch: %+v", epoch)
+	}
+	d, _ := ss.GetDelegation(operator, delegator)
+	if d == nil || d.Amount != 3000 || d.Rewards != 675 {
+		t.Fatalf("unexpected delegation: %+v", d)
+	}
+	if acc, _ := m.GetAccount(operator); acc.Balance != 4000+325 {
+		t.Errorf("operator balance %d, want %d", acc.Balance, 4000+325)
+	}
+
+	// Withdraw rewards and undelegate everything
+	m.BeginBlock(params.EpochLength + 1)
+	for nonce, txType := range []int{types.TxTypeWithdrawRewards, types.TxTypeUndelegate} {
+		tx := types.Transaction{Type: txType, Sender: delegator, Nonce: uint64(nonce + 2), Payload: delegation}
+		if txType == types.TxTypeUndelegate {
+			tx.Amount = 3000
+		}
+		if err := m.ApplyTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := m.GetAccount(delegator); acc.Balance != 2000+675 {
+		t.Errorf("delegator balance %d after withdraw, want %d", acc.Balance, 2000+675)
+	}
+
+	// Released at the first epoch boundary after the unbonding period
+	if err := m.EndBlock(2 * params.EpochLength); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := m.GetAccount(delegator); acc.Balance != 5000+675 {
+		t.Errorf("delegator balance %d after unbonding, want %d", acc.Balance, 5000+675)
+	}
+	if d, _ := ss.GetDelegation(operator, delegator); d != nil {
+		t.Errorf("empty delegation should be removed, got %+v", d)
+	}
+	if rec, _ := ss.GetValidator(operator); len(rec.Delegators) != 0 || rec.Delegated != 0 {
+		t.Errorf("validator still tracks delegators: %+v", rec)
+	}
+}
+
+func TestDoubleSignEvidenceSlashesAndTombstones(t *testing.T) {
+	m := newTestManager(t, 10)
+	operator, delegator := [32]byte{0xa}, [32]byte{0xd}
+	consPub, consPriv, _ := ed25519.GenerateKey(nil)
+	var consensusKey [32]byte
+	copy(consensusKey[:], consPub)
+	m.UpdateAccount(operator, &Account{Balance: 5000})
+	m.UpdateAccount(delegator, &Account{Balance: 5000})
+
+	bondPayload, _ := json.Marshal(types.StakeBondPayload{ConsensusKey: consensusKey})
+	delegation, _ := json.Marshal(types.DelegationPayload{Validator: operator})
+	m.BeginBlock(1)
+	for _, tx := range []types.Transaction{
+		{Type: types.TxTypeStakeBond, Sender: operator, Amount: 2000, Nonce: 1, Payload: bondPayload},
+		{Type: types.TxTypeDelegate, Sender: delegator, Amount: 1000, Nonce: 1, Payload: delegation},
+	} {
+		if err := m.ApplyTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.EndBlock(params.EpochLength)
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Conflicting precommits at height 50
+	var votes [2]types.EvidenceVote
+	for i := range votes {
+		votes[i] = types.EvidenceVote{Type: 2, Height: 50, BlockHash: [32]byte{byte(i + 1)}, ValidatorAddress: consensusKey}
+		copy(votes[i].Signature[:], ed25519.Sign(consPriv, votes[i].SignBytes()))
+	}
+	ev := types.NewDoubleSignEvidence(votes[0], votes[1])
+
+	m.BeginBlock(params.EpochLength + 1)
+	if err := m.ApplyEvidence(ev); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyEvidence(ev); err == nil {
+		t.Error("evidence against a tombstoned validator should be rejected")
+	}
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	ss := m.GetStakingState()
+	rec, _ := ss.GetValidator(operator)
+	if !rec.Jailed || !rec.Tombstoned || rec.Power() != 0 {
+		t.Errorf("validator not slashed and tombstoned: %+v", rec)
+	}
+	if d, _ := ss.GetDelegation(operator, delegator); d != nil && d.Amount != 0 {
+		t.Errorf("delegation not slashed: %+v", d)
+	}
+	if epoch, _ := ss.ActiveEpoch(); len(epoch.Validators) != 0 {
+		t.Errorf("slashed validator still active: %+v", epoch.Validators)
+	}
+	if err := m.ApplyTransaction(types.Transaction{Type: types.TxTypeUnjail, Sender: operator, Nonce: 2}); err == nil {
+		t.Error("tombstoned validator must not unjail")
+	}
+}
+
+func TestDowntimeJailsAfterMissedWindow(t *testing.T) {
+	m := newTestManager(t, 10)
+	live, idle := [32]byte{0xa}, [32]byte{0xb}
+	livePub, livePriv, _ := ed25519.GenerateKey(nil)
+	var liveKey [32]byte
+	copy(liveKey[:], livePub)
+	m.UpdateAccount(live, &Account{Balance: 5000})
+	m.UpdateAccount(idle, &Account{Balance: 5000})
+
+	m.BeginBlock(1)
+	for i, v := range []struct {
+		operator, key [32]byte
+	}{{live, liveKey}, {idle, [32]byte{0xee}}} {
+		payload, _ := json.Marshal(types.StakeBondPayload{ConsensusKey: v.key})
+		if err := m.ApplyTransaction(types.Transaction{Type: types.TxTypeStakeBond, Sender: v.operator, Amount: 2000 + uint64(i), Nonce: 1, Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.EndBlock(params.EpochLength)
+	if err := m.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the live validator signs; the first commit just starts tracking
+	start := uint64(params.EpochLength + 1)
+	for h := start; h <= start+params.SignedBlocksWindow; h++ {
+		commit := &types.Commit{Height: h - 1, BlockHash: [32]byte{byte(h)}}
+		sig := types.CommitSig{ValidatorAddress: liveKey, Timestamp: int64(h)}
+		vote := commit.Vote(sig)
+		copy(sig.Signature[:], ed25519.Sign(livePriv, vote.SignBytes()))
+		commit.Signatures = []types.CommitSig{sig}
+
+		m.BeginBlock(h)
+		if err := m.UpdateLiveness(commit); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ss := m.GetStakingState()
+	rec, _ := ss.GetValidator(idle)
+	if !rec.Jailed || rec.Tombstoned || rec.JailedUntil != start+params.SignedBlocksWindow+params.DowntimeJailBlocks {
+		t.Errorf("idle validator not jailed for downtime: %+v", rec)
+	}
+	if want := uint64(2001 - 2001*params.DowntimeSlashPercent/100); rec.Stake != want {
+		t.Errorf("idle stake = %d, want %d", rec.Stake, want)
+	}
+	if epoch, _ := ss.ActiveEpoch(); len(epoch.Validators) != 1 || epoch.Validators[0].Operator != live {
+		t.Errorf("active set after jailing: %+v", epoch.Validators)
+	}
+
+	uptimes, err := m.ValidatorUptimes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range uptimes {
+		switch u.Operator {
+		case live:
+			if !u.Active || u.Counted != params.SignedBlocksWindow || u.MissedBlocks != 0 || u.Uptime != 1 {
+				t.Errorf("live validator uptime: %+v", u)
+			}
+		case idle:
+			if u.Active || !u.Jailed || u.MissedBlocks != 0 {
+				t.Errorf("jailed validator uptime (window resets): %+v", u)
+			}
+		}
+	}
+}
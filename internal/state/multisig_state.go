@@ -0,0 +1,129 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// MultisigState manages registered M-of-N account policies
+type MultisigState struct {
+	// In-memory cache: address -> policy (committed)
+	policies map[[32]byte]*types.MultisigPolicy
+
+	// Registered in the block being applied (flushed by Manager.Commit)
+	pending map[[32]byte]*types.MultisigPolicy
+
+	mu sync.RWMutex
+	db *leveldb.DB
+}
+
+// NewMultisigState creates a multisig policy manager
+func NewMultisigState(db *leveldb.DB) *MultisigState {
+	return &MultisigState{
+		policies: make(map[[32]byte]*types.MultisigPolicy),
+		pending:  make(map[[32]byte]*types.MultisigPolicy),
+		db:       db,
+	}
+}
+
+func multisigKey(address [32]byte) []byte {
+	return append([]byte("multisig-"), address[:]...)
+}
+
+// GetPolicy returns the policy registered under address (including pending registrations)
+func (ms *MultisigState) GetPolicy(address [32]byte) (*types.MultisigPolicy, error) {
+	ms.mu.RLock()
+	if policy, ok := ms.pending[address]; ok {
+		ms.mu.RUnlock()
+		return policy, nil
+	}
+	if policy, ok := ms.policies[address]; ok {
+		ms.mu.RUnlock()
+		return policy, nil
+	}
+	ms.mu.RUnlock()
+
+	// Load from DB
+	data, err := ms.db.Get(multisigKey(address), nil)
+	if err != nil {
+		return nil, fmt.Errorf("no multisig policy for %x: %v", address[:4], err)
+	}
+
+	var policy types.MultisigPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	ms.policies[address] = &policy
+	ms.mu.Unlock()
+
+	return &policy, nil
+}
+
+// HasPolicy checks if a multisig policy is registered under address
+func (ms *MultisigState) HasPolicy(address [32]byte) bool {
+	_, err := ms.GetPolicy(address)
+	return err == nil
+}
+
+// RegisterPolicy stages a new policy under its derived address
+func (ms *MultisigState) RegisterPolicy(policy *types.MultisigPolicy) ([32]byte, error) {
+	if err := policy.Validate(); err != nil {
+		return [32]byte{}, err
+	}
+
+	address := policy.Address()
+	if ms.HasPolicy(address) {
+		return address, fmt.Errorf("multisig policy already registered: %x", address[:4])
+	}
+
+	ms.mu.Lock()
+	ms.pending[address] = policy
+	ms.mu.Unlock()
+
+	return address, nil
+}
+
+// stageCommit writes pending policies into batch and returns a function that
+// promotes them to the committed cache once the batch has been written
+func (ms *MultisigState) stageCommit(batch *leveldb.Batch) (func(), error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for address, policy := range ms.pending {
+		data, err := json.Marshal(policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode multisig policy %x: %v", address[:4], err)
+		}
+		batch.Put(multisigKey(address), data)
+	}
+
+	return func() {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		for address, policy := range ms.pending {
+			ms.policies[address] = policy
+		}
+		ms.pending = make(map[[32]byte]*types.MultisigPolicy)
+	}, nil
+}
+
+// discard drops pending registrations
+func (ms *MultisigState) discard() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.pending = make(map[[32]byte]*types.MultisigPolicy)
+}
+
+// reset drops the committed cache so policies are reloaded from disk
+func (ms *MultisigState) reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.policies = make(map[[32]byte]*types.MultisigPolicy)
+	ms.pending = make(map[[32]byte]*types.MultisigPolicy)
+}
@@ -0,0 +1,163 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// CheckEvidence checks that ev can be executed in the block at height, without changing state
+func (m *Manager) CheckEvidence(ev types.DoubleSignEvidence, height uint64) error {
+	_, err := m.checkEvidence(ev, height)
+	return err
+}
+
+// checkEvidence verifies ev and returns the record of the offending validator
+// Evidence is only executable while the stake bonded at the offense can still be
+// slashed, i.e. within the unbonding period.
+func (m *Manager) checkEvidence(ev types.DoubleSignEvidence, height uint64) (*ValidatorRecord, error) {
+	if err := ev.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid evidence: %v", err)
+	}
+
+	m.mu.RLock()
+	unbondingPeriod := m.unbondingPeriod
+	m.mu.RUnlock()
+
+	if ev.Height() > height {
+		return nil, fmt.Errorf("evidence from future height %d", ev.Height())
+	}
+	if height-ev.Height() > unbondingPeriod {
+		return nil, fmt.Errorf("evidence from height %d expired", ev.Height())
+	}
+
+	validator := ev.Validator()
+	operator, ok, err := m.stakingState.OperatorOf(validator)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("evidence against unbonded validator %x", validator[:4])
+	}
+	rec, err := m.stakingState.GetValidator(operator)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Tombstoned {
+		return nil, fmt.Errorf("validator %x already slashed for double-signing", validator[:4])
+	}
+	return rec, nil
+}
+
+// ApplyEvidence executes double-sign evidence included in the block being applied
+// DoubleSignSlashPercent of the validator's stake, of its delegations and of any
+// stake that was still bonded at the offense (unbonding since) is burned; the
+// validator is tombstoned and leaves the active set immediately.
+func (m *Manager) ApplyEvidence(ev types.DoubleSignEvidence) error {
+	rec, err := m.checkEvidence(ev, m.currentHeight())
+	if err != nil {
+		return err
+	}
+
+	burned, err := m.slashValidator(rec, params.DoubleSignSlashPercent, ev.Height())
+	if err != nil {
+		return err
+	}
+
+	rec.Jailed = true
+	rec.Tombstoned = true
+	m.stakingState.stageValidator(rec)
+	if err := m.removeFromEpoch(rec.Operator); err != nil {
+		return err
+	}
+
+	fmt.Printf("[Slashing] ⚠️  Validator %x slashed for double-signing at height %d: %d RNR burned, tombstoned\n",
+		rec.ConsensusKey[:4], ev.Height(), burned)
+	return nil
+}
+
+// jailForDowntime jails the validator of operator at height, burning DowntimeSlashPercent of its bonded stake
+func (m *Manager) jailForDowntime(operator [32]byte, height uint64) error {
+	rec, err := m.stakingState.GetValidator(operator)
+	if err != nil {
+		return err
+	}
+	if rec == nil || rec.Jailed {
+		return nil
+	}
+
+	burned, err := m.slashValidator(rec, params.DowntimeSlashPercent, height)
+	if err != nil {
+		return err
+	}
+
+	rec.Jailed = true
+	rec.JailedUntil = height + params.DowntimeJailBlocks
+	m.stakingState.stageValidator(rec)
+	if err := m.removeFromEpoch(rec.Operator); err != nil {
+		return err
+	}
+
+	fmt.Printf("[Slashing] ⚠️  Validator %x jailed for downtime at height %d: %d RNR burned, unjail from %d\n",
+		rec.ConsensusKey[:4], height, burned, rec.JailedUntil)
+	return nil
+}
+
+// slashValidator burns percent of rec's stake and delegations, including stake that
+// started unbonding at or after infractionHeight (it was bonded at the offense)
+// rec is updated in place and must be staged by the caller; returns the amount burned.
+func (m *Manager) slashValidator(rec *ValidatorRecord, percent uint64, infractionHeight uint64) (uint64, error) {
+	slash := func(amount uint64) uint64 {
+		return mulDiv(amount, percent, 100)
+	}
+	var burned uint64
+	slashUnbonding := func(entries []UnbondingEntry) {
+		for i := range entries {
+			if entries[i].CreationHeight < infractionHeight {
+				continue // Already unbonding when the offense happened
+			}
+			cut := slash(entries[i].Amount)
+			entries[i].Amount -= cut
+			burned += cut
+		}
+	}
+
+	cut := slash(rec.Stake)
+	rec.Stake -= cut
+	burned += cut
+	slashUnbonding(rec.Unbonding)
+
+	delegations, err := m.stakingState.Delegations(rec)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range delegations {
+		cut := slash(d.Amount)
+		d.Amount -= cut
+		rec.Delegated -= cut
+		burned += cut
+		slashUnbonding(d.Unbonding)
+		m.stageDelegation(rec, d)
+	}
+	return burned, nil
+}
+
+// removeFromEpoch drops operator from the active validator set of the current epoch
+func (m *Manager) removeFromEpoch(operator [32]byte) error {
+	epoch, err := m.stakingState.ActiveEpoch()
+	if err != nil || epoch == nil {
+		return err
+	}
+
+	next := &ValidatorEpoch{Height: epoch.Height}
+	for _, v := range epoch.Validators {
+		if v.Operator != operator {
+			next.Validators = append(next.Validators, v)
+		}
+	}
+	if len(next.Validators) != len(epoch.Validators) {
+		m.stakingState.stageEpoch(next)
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// BeginBlock sets the height of the block about to be applied
+// Staking uses it for unbonding completion and jail expiry.
+func (m *Manager) BeginBlock(height uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.height = height
+}
+
+// currentHeight returns the height set by BeginBlock
+func (m *Manager) currentHeight() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.height
+}
+
+// SetUnbondingPeriod sets how many blocks unbonded stake stays locked (and slashable)
+// It must be the same on every node of a network.
+func (m *Manager) SetUnbondingPeriod(blocks uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unbondingPeriod = blocks
+}
+
+// UnbondingPeriod returns how many blocks unbonded stake stays locked (and evidence executable)
+func (m *Manager) UnbondingPeriod() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.unbondingPeriod
+}
+
+// newUnbonding returns an unbonding entry for amount starting at the current height
+func (m *Manager) newUnbonding(amount uint64) UnbondingEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return UnbondingEntry{
+		Amount:           amount,
+		CreationHeight:   m.height,
+		CompletionHeight: m.height + m.unbondingPeriod,
+	}
+}
+
+// applyStaking applies a staking transaction from tx.Sender
+func (m *Manager) applyStaking(tx types.Transaction, sender *Account) error {
+	if err := types.CheckStakingTx(tx); err != nil {
+		return err
+	}
+	if tx.Sender == [32]byte{} {
+		return fmt.Errorf("coinbase cannot stake")
+	}
+	if types.IsDelegationTx(tx.Type) {
+		return m.applyDelegation(tx, sender)
+	}
+
+	rec, err := m.stakingState.GetValidator(tx.Sender)
+	if err != nil {
+		return err
+	}
+	height := m.currentHeight()
+
+	switch tx.Type {
+	case types.TxTypeStakeBond:
+		payload, err := types.DecodeStakeBond(tx)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			if payload.ConsensusKey == ([32]byte{}) {
+				return fmt.Errorf("first bond must set a consensus key")
+			}
+			op, taken, err := m.stakingState.OperatorOf(payload.ConsensusKey)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("consensus key already used by validator %x", op[:4])
+			}
+			rec = &ValidatorRecord{
+				Operator:          tx.Sender,
+				ConsensusKey:      payload.ConsensusKey,
+				CommissionRate:    payload.CommissionRate,
+				CommissionUpdated: height,
+			}
+		} else if payload.ConsensusKey != ([32]byte{}) && payload.ConsensusKey != rec.ConsensusKey {
+			return fmt.Errorf("consensus key of validator %x cannot change", tx.Sender[:4])
+		}
+		if payload.Moniker != "" {
+			rec.Moniker = payload.Moniker
+		}
+		if rec.Stake+tx.Amount < rec.Stake {
+			return fmt.Errorf("stake overflows")
+		}
+		rec.Stake += tx.Amount
+		sender.Balance -= tx.Amount
+
+	case types.TxTypeStakeUnbond:
+		if rec == nil {
+			return fmt.Errorf("no validator for %x", tx.Sender[:4])
+		}
+		if tx.Amount > rec.Stake {
+			return fmt.Errorf("unbond %d exceeds stake %d", tx.Amount, rec.Stake)
+		}
+		rec.Stake -= tx.Amount
+		rec.Unbonding = append(rec.Unbonding, m.newUnbonding(tx.Amount))
+
+	case types.TxTypeEditValidator:
+		payload, err := types.DecodeEditValidator(tx)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return fmt.Errorf("no validator for %x", tx.Sender[:4])
+		}
+		rec.Moniker = payload.Moniker
+		if payload.CommissionRate != nil && *payload.CommissionRate != rec.CommissionRate {
+			if err := checkCommissionChange(rec, *payload.CommissionRate, height); err != nil {
+				return err
+			}
+			rec.CommissionRate = *payload.CommissionRate
+			rec.CommissionUpdated = height
+		}
+
+	case types.TxTypeUnjail:
+		if rec == nil || !rec.Jailed {
+			return fmt.Errorf("validator %x is not jailed", tx.Sender[:4])
+		}
+		if rec.Tombstoned {
+			return fmt.Errorf("validator %x was slashed for double-signing and cannot unjail", tx.Sender[:4])
+		}
+		if height < rec.JailedUntil {
+			return fmt.Errorf("validator %x is jailed until height %d", tx.Sender[:4], rec.JailedUntil)
+		}
+		rec.Jailed = false
+		rec.JailedUntil = 0
+	}
+
+	sender.Nonce++
+	if err := m.UpdateAccount(tx.Sender, sender); err != nil {
+		return err
+	}
+	m.stakingState.stageValidator(rec)
+	return nil
+}
+
+// checkCommissionChange limits commission changes to CommissionChange basis points once per epoch
+func checkCommissionChange(rec *ValidatorRecord, rate uint32, height uint64) error {
+	if height < rec.CommissionUpdated+params.EpochLength {
+		return fmt.Errorf("commission of %x changed at height %d, next change allowed at %d",
+			rec.Operator[:4], rec.CommissionUpdated, rec.CommissionUpdated+params.EpochLength)
+	}
+	delta := rate - rec.CommissionRate
+	if rate < rec.CommissionRate {
+		delta = rec.CommissionRate - rate
+	}
+	if delta > params.CommissionChange {
+		return fmt.Errorf("commission change %d exceeds %d basis points", delta, params.CommissionChange)
+	}
+	return nil
+}
+
+// applyDelegation applies a delegate, undelegate or withdraw-rewards transaction from the delegator tx.Sender
+func (m *Manager) applyDelegation(tx types.Transaction, sender *Account) error {
+	payload, err := types.DecodeDelegation(tx)
+	if err != nil {
+		return err
+	}
+	if payload.Validator == tx.Sender {
+		return fmt.Errorf("operators bond to their own validator")
+	}
+
+	rec, err := m.stakingState.GetValidator(payload.Validator)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return fmt.Errorf("no validator for %x", payload.Validator[:4])
+	}
+	d, err := m.stakingState.GetDelegation(payload.Validator, tx.Sender)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		d = &Delegation{Delegator: tx.Sender, Validator: payload.Validator}
+	}
+
+	switch tx.Type {
+	case types.TxTypeDelegate:
+		if rec.Delegated+tx.Amount < rec.Delegated {
+			return fmt.Errorf("delegation overflows")
+		}
+		d.Amount += tx.Amount
+		rec.Delegated += tx.Amount
+		sender.Balance -= tx.Amount
+
+	case types.TxTypeUndelegate:
+		if tx.Amount > d.Amount {
+			return fmt.Errorf("undelegate %d exceeds delegation %d", tx.Amount, d.Amount)
+		}
+		d.Amount -= tx.Amount
+		rec.Delegated -= tx.Amount
+		d.Unbonding = append(d.Unbonding, m.newUnbonding(tx.Amount))
+
+	case types.TxTypeWithdrawRewards:
+		if d.Rewards == 0 {
+			return fmt.Errorf("no rewards to withdraw from %x", payload.Validator[:4])
+		}
+		sender.Balance += d.Rewards
+		d.Rewards = 0
+	}
+
+	sender.Nonce++
+	if err := m.UpdateAccount(tx.Sender, sender); err != nil {
+		return err
+	}
+	m.stageDelegation(rec, d)
+	return nil
+}
+
+// stageDelegation stages d together with the delegator list of its validator rec
+func (m *Manager) stageDelegation(rec *ValidatorRecord, d *Delegation) {
+	if d.isEmpty() {
+		rec.removeDelegator(d.Delegator)
+	} else {
+		rec.addDelegator(d.Delegator)
+	}
+	m.stakingState.stageDelegation(d)
+	m.stakingState.stageValidator(rec)
+}
+
+// allocateReward credits a block reward earned by the validator of operator
+// The operator keeps its commission and the share of its own stake; the rest
+// accrues to delegators pro rata (rounding dust goes to the operator).
+func (m *Manager) allocateReward(operator [32]byte, amount uint64) error {
+	rec, err := m.stakingState.GetValidator(operator)
+	if err != nil {
+		return err
+	}
+	delegations, err := m.stakingState.Delegations(rec)
+	if err != nil {
+		return err
+	}
+
+	commission := mulDiv(amount, uint64(rec.CommissionRate), types.MaxCommissionRate)
+	distributable := amount - commission
+	paid := uint64(0)
+	if power := rec.Power(); power > 0 {
+		for _, d := range delegations {
+			share := mulDiv(distributable, d.Amount, power)
+			if share == 0 {
+				continue
+			}
+			d.Rewards += share
+			paid += share
+			m.stakingState.stageDelegation(d)
+		}
+	}
+
+	acc, err := m.GetAccount(operator)
+	if err != nil {
+		return err
+	}
+	acc.Balance += amount - paid
+	return m.UpdateAccount(operator, acc)
+}
+
+// mulDiv returns a*b/c without intermediate overflow (the result must fit in uint64)
+func mulDiv(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	quo, _ := bits.Div64(hi, lo, c)
+	return quo
+}
+
+// releaseUnbonding removes the matured entries of unbonding and returns their total
+func releaseUnbonding(unbonding []UnbondingEntry, height uint64) (uint64, []UnbondingEntry) {
+	var released uint64
+	var remaining []UnbondingEntry
+	for _, entry := range unbonding {
+		if entry.CompletionHeight <= height {
+			released += entry.Amount
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+	return released, remaining
+}
+
+// credit adds amount to the balance of account
+func (m *Manager) credit(account [32]byte, amount uint64) error {
+	acc, err := m.GetAccount(account)
+	if err != nil {
+		return err
+	}
+	acc.Balance += amount
+	return m.UpdateAccount(account, acc)
+}
+
+// EndBlock runs end-of-block staking logic for the block at height
+// At epoch boundaries matured unbondings are paid out and the active validator
+// set is recomputed: among validators self-bonding at least MinValidatorStake,
+// the MaxValidators largest unjailed powers (self stake plus delegations, ties
+// broken by operator). The node applies it through validator.Manager.ApplyEpoch.
+func (m *Manager) EndBlock(height uint64) error {
+	if height == 0 || height%params.EpochLength != 0 {
+		return nil
+	}
+
+	records, err := m.stakingState.Validators()
+	if err != nil {
+		return err
+	}
+
+	// 1. Release matured unbondings
+	for _, rec := range records {
+		delegations, err := m.stakingState.Delegations(rec)
+		if err != nil {
+			return err
+		}
+		for _, d := range delegations {
+			released, remaining := releaseUnbonding(d.Unbonding, height)
+			if released == 0 {
+				continue
+			}
+			if err := m.credit(d.Delegator, released); err != nil {
+				return err
+			}
+			d.Unbonding = remaining
+			m.stageDelegation(rec, d)
+		}
+
+		released, remaining := releaseUnbonding(rec.Unbonding, height)
+		if released == 0 {
+			continue
+		}
+		if err := m.credit(rec.Operator, released); err != nil {
+			return err
+		}
+		rec.Unbonding = remaining
+		m.stakingState.stageValidator(rec)
+	}
+
+	// 2. Choose the active set
+	var candidates []*ValidatorRecord
+	for _, rec := range records {
+		if !rec.Jailed && rec.Stake >= params.MinValidatorStake {
+			candidates = append(candidates, rec)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Power() != candidates[j].Power() {
+			return candidates[i].Power() > candidates[j].Power()
+		}
+		return bytes.Compare(candidates[i].Operator[:], candidates[j].Operator[:]) < 0
+	})
+	if len(candidates) > params.MaxValidators {
+		candidates = candidates[:params.MaxValidators]
+	}
+
+	epoch := &ValidatorEpoch{Height: height}
+	for _, rec := range candidates {
+		epoch.Validators = append(epoch.Validators, ActiveValidator{
+			Operator:     rec.Operator,
+			ConsensusKey: rec.ConsensusKey,
+			Power:        rec.Power(),
+		})
+	}
+	m.stakingState.stageEpoch(epoch)
+
+	fmt.Printf("[Staking] Epoch at height %d: %d active validators\n", height, len(epoch.Validators))
+	return nil
+}
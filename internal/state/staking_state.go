@@ -0,0 +1,641 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// UnbondingEntry is stake on its way back to an account balance (slashable until released)
+type UnbondingEntry struct {
+	Amount           uint64
+	CreationHeight   uint64 // Height the unbonding started (earlier offenses still slash it)
+	CompletionHeight uint64 // Released at the first epoch boundary at or after this height
+}
+
+// ValidatorRecord is the on-chain state of a validator candidate
+type ValidatorRecord struct {
+	Operator     [32]byte         // Account that bonded the stake
+	ConsensusKey [32]byte         // Ed25519 key signing votes (BFT validator address)
+	Moniker      string           `json:",omitempty"`
+	Stake        uint64           // Self-bonded stake
+	Unbonding    []UnbondingEntry `json:",omitempty"`
+	Jailed       bool             `json:",omitempty"`
+	JailedUntil  uint64           `json:",omitempty"` // Earliest height an unjail is accepted
+	Tombstoned   bool             `json:",omitempty"` // Slashed for double-signing, jailed forever
+
+	// Delegation
+	CommissionRate    uint32     `json:",omitempty"` // Share of rewards kept by the operator (basis points)
+	CommissionUpdated uint64     `json:",omitempty"` // Height of the last commission change
+	Delegated         uint64     `json:",omitempty"` // Sum of delegated stake
+	Delegators        [][32]byte `json:",omitempty"` // Accounts with a delegation record, sorted
+}
+
+// Power returns the voting power the validator would have if active
+func (rec *ValidatorRecord) Power() uint64 {
+	return rec.Stake + rec.Delegated
+}
+
+// addDelegator records delegator in the sorted delegator list
+func (rec *ValidatorRecord) addDelegator(delegator [32]byte) {
+	i := sort.Search(len(rec.Delegators), func(i int) bool {
+		return bytes.Compare(rec.Delegators[i][:], delegator[:]) >= 0
+	})
+	if i < len(rec.Delegators) && rec.Delegators[i] == delegator {
+		return
+	}
+	rec.Delegators = append(rec.Delegators, [32]byte{})
+	copy(rec.Delegators[i+1:], rec.Delegators[i:])
+	rec.Delegators[i] = delegator
+}
+
+// removeDelegator drops delegator from the delegator list
+func (rec *ValidatorRecord) removeDelegator(delegator [32]byte) {
+	for i, d := range rec.Delegators {
+		if d == delegator {
+			rec.Delegators = append(rec.Delegators[:i], rec.Delegators[i+1:]...)
+			return
+		}
+	}
+}
+
+// copyRecord returns a deep copy of rec
+func copyRecord(rec *ValidatorRecord) *ValidatorRecord {
+	recCopy := *rec
+	recCopy.Unbonding = append([]UnbondingEntry(nil), rec.Unbonding...)
+	recCopy.Delegators = append([][32]byte(nil), rec.Delegators...)
+	return &recCopy
+}
+
+// Delegation is stake an account delegated to a validator
+// Undelegated stake stays in Unbonding (and slashable) until released.
+type Delegation struct {
+	Delegator [32]byte
+	Validator [32]byte         // Operator of the validator
+	Amount    uint64           // Bonded amount
+	Rewards   uint64           `json:",omitempty"` // Accrued, not yet withdrawn
+	Unbonding []UnbondingEntry `json:",omitempty"`
+}
+
+// isEmpty returns true once nothing is bonded, unbonding or owed
+func (d *Delegation) isEmpty() bool {
+	return d.Amount == 0 && d.Rewards == 0 && len(d.Unbonding) == 0
+}
+
+// copyDelegation returns a deep copy of d
+func copyDelegation(d *Delegation) *Delegation {
+	dCopy := *d
+	dCopy.Unbonding = append([]UnbondingEntry(nil), d.Unbonding...)
+	return &dCopy
+}
+
+// SigningInfo is a validator's liveness record over the last SignedBlocksWindow commits
+type SigningInfo struct {
+	StartHeight  uint64 // First commit height tracked (after joining or being jailed)
+	IndexOffset  uint64 // Commits tracked so far
+	MissedBlocks uint64 // Commits missed within the window
+	Missed       []byte // Bitmap of missed commits, indexed by IndexOffset % SignedBlocksWindow
+}
+
+// copySigningInfo returns a deep copy of info
+func copySigningInfo(info *SigningInfo) *SigningInfo {
+	infoCopy := *info
+	infoCopy.Missed = append([]byte(nil), info.Missed...)
+	return &infoCopy
+}
+
+// delegationID identifies a delegation by validator and delegator
+type delegationID struct {
+	validator [32]byte
+	delegator [32]byte
+}
+
+// ActiveValidator is one member of an epoch's validator set
+type ActiveValidator struct {
+	Operator     [32]byte
+	ConsensusKey [32]byte
+	Power        uint64
+}
+
+// ValidatorEpoch is the validator set chosen at an epoch boundary
+type ValidatorEpoch struct {
+	Height     uint64
+	Validators []ActiveValidator
+}
+
+// EpochChange is a validator set chosen on-chain and the first height it validates
+type EpochChange struct {
+	From  uint64
+	Epoch *ValidatorEpoch
+}
+
+// StakingState manages validator records and the active set of the current epoch
+type StakingState struct {
+	// In-memory cache: operator -> record (committed)
+	records map[[32]byte]*ValidatorRecord
+
+	// Index of all operators (committed, loaded lazily)
+	operators       map[[32]byte]bool
+	operatorsLoaded bool
+
+	// Index of consensus key -> operator (committed, built lazily from the records)
+	keyOperators       map[[32]byte][32]byte
+	keyOperatorsLoaded bool
+
+	// Active set of the current epoch (committed, loaded lazily)
+	epoch       *ValidatorEpoch
+	epochLoaded bool
+
+	// In-memory cache of delegations (committed)
+	delegations map[delegationID]*Delegation
+
+	// In-memory cache: operator -> liveness record (committed)
+	signingInfos map[[32]byte]*SigningInfo
+
+	// Changed by the block being applied (flushed by Manager.Commit)
+	pending            map[[32]byte]*ValidatorRecord
+	pendingEpoch       *ValidatorEpoch
+	pendingDelegations map[delegationID]*Delegation
+	pendingSigning     map[[32]byte]*SigningInfo
+
+	mu sync.RWMutex
+	db *leveldb.DB
+}
+
+// NewStakingState creates a staking state manager
+func NewStakingState(db *leveldb.DB) *StakingState {
+	return &StakingState{
+		records:            make(map[[32]byte]*ValidatorRecord),
+		operators:          make(map[[32]byte]bool),
+		keyOperators:       make(map[[32]byte][32]byte),
+		delegations:        make(map[delegationID]*Delegation),
+		signingInfos:       make(map[[32]byte]*SigningInfo),
+		pending:            make(map[[32]byte]*ValidatorRecord),
+		pendingDelegations: make(map[delegationID]*Delegation),
+		pendingSigning:     make(map[[32]byte]*SigningInfo),
+		db:                 db,
+	}
+}
+
+var (
+	stakingOperatorsKey = []byte("staking-operators")
+	stakingEpochKey     = []byte("staking-epoch")
+)
+
+// stakingEpochHistoryPrefix + zero-padded height holds the epoch validating blocks from that height on
+const stakingEpochHistoryPrefix = "staking-epoch-from-"
+
+func stakingEpochHistoryKey(from uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", stakingEpochHistoryPrefix, from))
+}
+
+func stakingValidatorKey(operator [32]byte) []byte {
+	return append([]byte("staking-validator-"), operator[:]...)
+}
+
+func stakingSigningKey(operator [32]byte) []byte {
+	return append([]byte("staking-signing-"), operator[:]...)
+}
+
+func stakingDelegationKey(id delegationID) []byte {
+	key := append([]byte("staking-delegation-"), id.validator[:]...)
+	return append(key, id.delegator[:]...)
+}
+
+// GetValidator returns a copy of the record of operator (including staged changes), or nil
+func (ss *StakingState) GetValidator(operator [32]byte) (*ValidatorRecord, error) {
+	ss.mu.RLock()
+	if rec, ok := ss.pending[operator]; ok {
+		ss.mu.RUnlock()
+		return copyRecord(rec), nil
+	}
+	if rec, ok := ss.records[operator]; ok {
+		ss.mu.RUnlock()
+		return copyRecord(rec), nil
+	}
+	ss.mu.RUnlock()
+
+	// Load from DB
+	data, err := ss.db.Get(stakingValidatorKey(operator), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec ValidatorRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+
+	ss.mu.Lock()
+	ss.records[operator] = &rec
+	ss.mu.Unlock()
+
+	return copyRecord(&rec), nil
+}
+
+// GetDelegation returns a copy of the delegation of delegator to validator (including staged changes), or nil
+func (ss *StakingState) GetDelegation(validator, delegator [32]byte) (*Delegation, error) {
+	id := delegationID{validator: validator, delegator: delegator}
+
+	ss.mu.RLock()
+	if d, ok := ss.pendingDelegations[id]; ok {
+		ss.mu.RUnlock()
+		return copyDelegation(d), nil
+	}
+	if d, ok := ss.delegations[id]; ok {
+		ss.mu.RUnlock()
+		return copyDelegation(d), nil
+	}
+	ss.mu.RUnlock()
+
+	data, err := ss.db.Get(stakingDelegationKey(id), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var d Delegation
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	ss.mu.Lock()
+	ss.delegations[id] = &d
+	ss.mu.Unlock()
+
+	return copyDelegation(&d), nil
+}
+
+// Delegations returns copies of the delegations to the validator rec, in delegator order
+func (ss *StakingState) Delegations(rec *ValidatorRecord) ([]*Delegation, error) {
+	delegations := make([]*Delegation, 0, len(rec.Delegators))
+	for _, delegator := range rec.Delegators {
+		d, err := ss.GetDelegation(rec.Operator, delegator)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			delegations = append(delegations, d)
+		}
+	}
+	return delegations, nil
+}
+
+// GetSigningInfo returns a copy of the liveness record of operator (including staged changes), or nil
+func (ss *StakingState) GetSigningInfo(operator [32]byte) (*SigningInfo, error) {
+	ss.mu.RLock()
+	if info, ok := ss.pendingSigning[operator]; ok {
+		ss.mu.RUnlock()
+		return copySigningInfo(info), nil
+	}
+	if info, ok := ss.signingInfos[operator]; ok {
+		ss.mu.RUnlock()
+		return copySigningInfo(info), nil
+	}
+	ss.mu.RUnlock()
+
+	data, err := ss.db.Get(stakingSigningKey(operator), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info SigningInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	ss.mu.Lock()
+	ss.signingInfos[operator] = &info
+	ss.mu.Unlock()
+
+	return copySigningInfo(&info), nil
+}
+
+// Operators returns every operator that ever bonded, sorted
+func (ss *StakingState) Operators() ([][32]byte, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if err := ss.loadOperators(); err != nil {
+		return nil, err
+	}
+
+	operators := make([][32]byte, 0, len(ss.operators)+len(ss.pending))
+	for op := range ss.operators {
+		operators = append(operators, op)
+	}
+	for op := range ss.pending {
+		if !ss.operators[op] {
+			operators = append(operators, op)
+		}
+	}
+	sort.Slice(operators, func(i, j int) bool {
+		return bytes.Compare(operators[i][:], operators[j][:]) < 0
+	})
+	return operators, nil
+}
+
+// Validators returns copies of all validator records, sorted by operator
+func (ss *StakingState) Validators() ([]*ValidatorRecord, error) {
+	operators, err := ss.Operators()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*ValidatorRecord, 0, len(operators))
+	for _, op := range operators {
+		rec, err := ss.GetValidator(op)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// OperatorOf returns the operator whose consensus key is key (including staged bonds)
+// Consensus keys never change and records stay after unbonding (the stake remains
+// slashable), so the index only gains entries, as operators first bond.
+func (ss *StakingState) OperatorOf(key [32]byte) ([32]byte, bool, error) {
+	if err := ss.loadKeyOperators(); err != nil {
+		return [32]byte{}, false, err
+	}
+
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	if op, ok := ss.keyOperators[key]; ok {
+		return op, true, nil
+	}
+	for op, rec := range ss.pending {
+		if rec.ConsensusKey == key {
+			return op, true, nil
+		}
+	}
+	return [32]byte{}, false, nil
+}
+
+// loadKeyOperators builds the consensus key index from the committed records once
+func (ss *StakingState) loadKeyOperators() error {
+	ss.mu.RLock()
+	loaded := ss.keyOperatorsLoaded
+	ss.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	records, err := ss.Validators()
+	if err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, rec := range records {
+		if ss.operators[rec.Operator] { // Staged bonds are indexed on commit
+			ss.keyOperators[rec.ConsensusKey] = rec.Operator
+		}
+	}
+	ss.keyOperatorsLoaded = true
+	return nil
+}
+
+// ActiveEpoch returns the validator set of the current epoch (nil before the first epoch with validators)
+func (ss *StakingState) ActiveEpoch() (*ValidatorEpoch, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.pendingEpoch != nil {
+		return ss.pendingEpoch, nil
+	}
+	if !ss.epochLoaded {
+		data, err := ss.db.Get(stakingEpochKey, nil)
+		if err != nil && err != leveldb.ErrNotFound {
+			return nil, err
+		}
+		if err == nil {
+			var epoch ValidatorEpoch
+			if err := json.Unmarshal(data, &epoch); err != nil {
+				return nil, err
+			}
+			ss.epoch = &epoch
+		}
+		ss.epochLoaded = true
+	}
+	return ss.epoch, nil
+}
+
+// EpochHistory returns every committed change of the active validator set, oldest first
+// A set chosen while applying the block at height h validates blocks from h+1 on.
+func (ss *StakingState) EpochHistory() ([]EpochChange, error) {
+	var history []EpochChange
+	iter := ss.db.NewIterator(util.BytesPrefix([]byte(stakingEpochHistoryPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var from uint64
+		if _, err := fmt.Sscanf(string(iter.Key()[len(stakingEpochHistoryPrefix):]), "%d", &from); err != nil {
+			return nil, fmt.Errorf("corrupt epoch history key %s: %v", iter.Key(), err)
+		}
+		var epoch ValidatorEpoch
+		if err := json.Unmarshal(iter.Value(), &epoch); err != nil {
+			return nil, fmt.Errorf("corrupt epoch history record %s: %v", iter.Key(), err)
+		}
+		history = append(history, EpochChange{From: from, Epoch: &epoch})
+	}
+	return history, iter.Error()
+}
+
+// loadOperators reads the operator index once (caller holds the lock)
+func (ss *StakingState) loadOperators() error {
+	if ss.operatorsLoaded {
+		return nil
+	}
+
+	data, err := ss.db.Get(stakingOperatorsKey, nil)
+	if err != nil && err != leveldb.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		var operators [][32]byte
+		if err := json.Unmarshal(data, &operators); err != nil {
+			return fmt.Errorf("corrupt staking operator index: %v", err)
+		}
+		for _, op := range operators {
+			ss.operators[op] = true
+		}
+	}
+	ss.operatorsLoaded = true
+	return nil
+}
+
+// stageValidator stages rec for the block being applied
+func (ss *StakingState) stageValidator(rec *ValidatorRecord) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.pending[rec.Operator] = copyRecord(rec)
+}
+
+// stageDelegation stages d for the block being applied (an empty delegation is deleted on commit)
+func (ss *StakingState) stageDelegation(d *Delegation) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.pendingDelegations[delegationID{validator: d.Validator, delegator: d.Delegator}] = copyDelegation(d)
+}
+
+// stageSigningInfo stages the liveness record of operator for the block being applied
+func (ss *StakingState) stageSigningInfo(operator [32]byte, info *SigningInfo) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.pendingSigning[operator] = copySigningInfo(info)
+}
+
+// stageEpoch stages the validator set chosen at an epoch boundary
+func (ss *StakingState) stageEpoch(epoch *ValidatorEpoch) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.pendingEpoch = epoch
+}
+
+// stageCommit writes staged records, delegations, signing infos, the operator index and the epoch
+// (also into the epoch history, as validating from height+1) into batch and returns a function
+// that promotes them to the committed cache once the batch has been written
+func (ss *StakingState) stageCommit(batch *leveldb.Batch, height uint64) (func(), error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if err := ss.loadOperators(); err != nil {
+		return nil, err
+	}
+
+	newOperators := false
+	for op, rec := range ss.pending {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode validator %x: %v", op[:4], err)
+		}
+		batch.Put(stakingValidatorKey(op), data)
+		if !ss.operators[op] {
+			newOperators = true
+		}
+	}
+
+	if newOperators {
+		operators := make([][32]byte, 0, len(ss.operators)+len(ss.pending))
+		for op := range ss.operators {
+			operators = append(operators, op)
+		}
+		for op := range ss.pending {
+			if !ss.operators[op] {
+				operators = append(operators, op)
+			}
+		}
+		sort.Slice(operators, func(i, j int) bool {
+			return bytes.Compare(operators[i][:], operators[j][:]) < 0
+		})
+		data, err := json.Marshal(operators)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put(stakingOperatorsKey, data)
+	}
+
+	for id, d := range ss.pendingDelegations {
+		if d.isEmpty() {
+			batch.Delete(stakingDelegationKey(id))
+			continue
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode delegation %x -> %x: %v", id.delegator[:4], id.validator[:4], err)
+		}
+		batch.Put(stakingDelegationKey(id), data)
+	}
+
+	for op, info := range ss.pendingSigning {
+		data, err := json.Marshal(info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode signing info %x: %v", op[:4], err)
+		}
+		batch.Put(stakingSigningKey(op), data)
+	}
+
+	if ss.pendingEpoch != nil {
+		data, err := json.Marshal(ss.pendingEpoch)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put(stakingEpochKey, data)
+		batch.Put(stakingEpochHistoryKey(height+1), data)
+	}
+
+	return func() {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		for op, rec := range ss.pending {
+			ss.records[op] = rec
+			ss.operators[op] = true
+			ss.keyOperators[rec.ConsensusKey] = op
+		}
+		ss.pending = make(map[[32]byte]*ValidatorRecord)
+		for id, d := range ss.pendingDelegations {
+			if d.isEmpty() {
+				delete(ss.delegations, id)
+			} else {
+				ss.delegations[id] = d
+			}
+		}
+		ss.pendingDelegations = make(map[delegationID]*Delegation)
+		for op, info := range ss.pendingSigning {
+			ss.signingInfos[op] = info
+		}
+		ss.pendingSigning = make(map[[32]byte]*SigningInfo)
+		if ss.pendingEpoch != nil {
+			ss.epoch = ss.pendingEpoch
+			ss.epochLoaded = true
+			ss.pendingEpoch = nil
+		}
+	}, nil
+}
+
+// discard drops staged changes
+func (ss *StakingState) discard() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.pending = make(map[[32]byte]*ValidatorRecord)
+	ss.pendingDelegations = make(map[delegationID]*Delegation)
+	ss.pendingSigning = make(map[[32]byte]*SigningInfo)
+	ss.pendingEpoch = nil
+}
+
+// reset drops the committed caches so staking state is reloaded from disk
+func (ss *StakingState) reset() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.records = make(map[[32]byte]*ValidatorRecord)
+	ss.operators = make(map[[32]byte]bool)
+	ss.operatorsLoaded = false
+	ss.keyOperators = make(map[[32]byte][32]byte)
+	ss.keyOperatorsLoaded = false
+	ss.epoch = nil
+	ss.epochLoaded = false
+	ss.delegations = make(map[delegationID]*Delegation)
+	ss.signingInfos = make(map[[32]byte]*SigningInfo)
+	ss.pending = make(map[[32]byte]*ValidatorRecord)
+	ss.pendingDelegations = make(map[delegationID]*Delegation)
+	ss.pendingSigning = make(map[[32]byte]*SigningInfo)
+	ss.pendingEpoch = nil
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
 
 	"github.com/syndtr/goleveldb/leveldb"
@@ -14,6 +15,9 @@ type TokenState struct {
 	allowances map[[32]byte]map[[32]byte]map[[32]byte]uint64 // token -> owner -> spender -> amount
 	mu         sync.RWMutex
 
+	// Balances changed by the block being applied (flushed by Manager.Commit)
+	pending map[[32]byte]map[[32]byte]uint64 // token -> account -> balance
+
 	// Persistent storage
 	db *leveldb.DB
 }
@@ -23,6 +27,7 @@ func NewTokenState(db *leveldb.DB) *TokenState {
 	return &TokenState{
 		balances:   make(map[[32]byte]map[[32]byte]uint64),
 		allowances: make(map[[32]byte]map[[32]byte]map[[32]byte]uint64),
+		pending:    make(map[[32]byte]map[[32]byte]uint64),
 		db:         db,
 	}
 }
@@ -32,6 +37,13 @@ func (ts *TokenState) GetBalance(tokenAddr, account [32]byte) uint64 {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
+	// Staged changes take precedence
+	if tokenBalances, exists := ts.pending[tokenAddr]; exists {
+		if balance, exists := tokenBalances[account]; exists {
+			return balance
+		}
+	}
+
 	// Check cache first
 	if tokenBalances, exists := ts.balances[tokenAddr]; exists {
 		if balance, exists := tokenBalances[account]; exists {
@@ -136,3 +148,63 @@ func (ts *TokenState) GetAllBalances(account [32]byte) map[[32]byte]uint64 {
 
 	return result
 }
+
+// stageBalance records a balance change that is only persisted by Manager.Commit
+func (ts *TokenState) stageBalance(tokenAddr, account [32]byte, balance uint64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, exists := ts.pending[tokenAddr]; !exists {
+		ts.pending[tokenAddr] = make(map[[32]byte]uint64)
+	}
+	ts.pending[tokenAddr][account] = balance
+}
+
+// stageCommit writes staged balances into batch and returns a function that
+// promotes them to the cache once the batch has been written
+func (ts *TokenState) stageCommit(batch *leveldb.Batch) (func(), error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	for tokenAddr, tokenBalances := range ts.pending {
+		for account, balance := range tokenBalances {
+			key := append([]byte("token-balance-"), tokenAddr[:]...)
+			key = append(key, account[:]...)
+			data, err := json.Marshal(balance)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode token balance %x: %v", account[:4], err)
+			}
+			batch.Put(key, data)
+		}
+	}
+
+	return func() {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		for tokenAddr, tokenBalances := range ts.pending {
+			if _, exists := ts.balances[tokenAddr]; !exists {
+				ts.balances[tokenAddr] = make(map[[32]byte]uint64)
+			}
+			for account, balance := range tokenBalances {
+				ts.balances[tokenAddr][account] = balance
+			}
+		}
+		ts.pending = make(map[[32]byte]map[[32]byte]uint64)
+	}, nil
+}
+
+// discard drops staged balance changes
+func (ts *TokenState) discard() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.pending = make(map[[32]byte]map[[32]byte]uint64)
+}
+
+// reset drops the committed caches so balances and allowances are reloaded from disk
+func (ts *TokenState) reset() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.balances = make(map[[32]byte]map[[32]byte]uint64)
+	ts.allowances = make(map[[32]byte]map[[32]byte]map[[32]byte]uint64)
+	ts.pending = make(map[[32]byte]map[[32]byte]uint64)
+}
@@ -0,0 +1,117 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// undoEntry is the value a key held before a block changed it
+type undoEntry struct {
+	Key     []byte `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Existed bool   `json:"existed"`
+}
+
+func undoKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("undo-%d", height))
+}
+
+// undoRecorder captures the pre-image of every key written by a commit batch
+type undoRecorder struct {
+	db      *leveldb.DB
+	seen    map[string]bool
+	entries []undoEntry
+	err     error
+}
+
+func (r *undoRecorder) record(key []byte) {
+	if r.err != nil || r.seen[string(key)] {
+		return
+	}
+	r.seen[string(key)] = true
+
+	value, err := r.db.Get(key, nil)
+	switch err {
+	case nil:
+		r.entries = append(r.entries, undoEntry{Key: append([]byte(nil), key...), Value: value, Existed: true})
+	case leveldb.ErrNotFound:
+		r.entries = append(r.entries, undoEntry{Key: append([]byte(nil), key...)})
+	default:
+		r.err = err
+	}
+}
+
+// Put implements leveldb.BatchReplay
+func (r *undoRecorder) Put(key, value []byte) { r.record(key) }
+
+// Delete implements leveldb.BatchReplay
+func (r *undoRecorder) Delete(key []byte) { r.record(key) }
+
+// stageUndo adds the undo record of the block at height to batch and prunes the
+// record that left the rollback window. It must run after all state writes are staged.
+func (m *Manager) stageUndo(batch *leveldb.Batch, height uint64) error {
+	recorder := &undoRecorder{db: m.db, seen: make(map[string]bool)}
+	if err := batch.Replay(recorder); err != nil {
+		return err
+	}
+	if recorder.err != nil {
+		return fmt.Errorf("failed to read undo pre-images: %v", recorder.err)
+	}
+
+	data, err := json.Marshal(recorder.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode undo record: %v", err)
+	}
+	batch.Put(undoKey(height), data)
+	if height > params.StateUndoDepth {
+		batch.Delete(undoKey(height - params.StateUndoDepth))
+	}
+	return nil
+}
+
+// Rollback reverts the committed state changes of the block at height
+// Blocks must be rolled back from the tip down, one height at a time, and only
+// within the last StateUndoDepth blocks. Contract storage and token allowances
+// are written outside the commit batch and are not reverted.
+func (m *Manager) Rollback(height uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := m.db.Get(undoKey(height), nil)
+	if err == leveldb.ErrNotFound {
+		// The block changed no state
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []undoEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("corrupt undo record for height %d: %v", height, err)
+	}
+
+	batch := new(leveldb.Batch)
+	for _, e := range entries {
+		if e.Existed {
+			batch.Put(e.Key, e.Value)
+		} else {
+			batch.Delete(e.Key)
+		}
+	}
+	batch.Delete(undoKey(height))
+	if err := m.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	// Committed caches may hold reverted values; reload everything from disk
+	m.dirty = make(map[[32]byte]*Account)
+	m.cache = newAccountCache(m.cache.capacity)
+	m.multisigState.reset()
+	m.tokenState.reset()
+	m.stakingState.reset()
+	return nil
+}
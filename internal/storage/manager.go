@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/LICODX/PoSSR-RNRCORE/internal/finality"
 	"github.com/LICODX/PoSSR-RNRCORE/internal/params"
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -31,24 +32,39 @@ func GenerateBlockBodyKey(height uint64) []byte {
 // SaveBlock saves a block to the database (Hardened against OOM)
 // Addressing debat/9.txt: "LevelDB OOM Risk"
 func (s *Store) SaveBlock(block types.Block) error {
+	batch := new(leveldb.Batch)
+	s.StageBlock(batch, block)
+
+	// Commit batch (LevelDB handles batch memory better than Go Heap)
+	return s.db.Write(batch, nil)
+}
+
+// StageBlock adds the writes saving block to batch
+func (s *Store) StageBlock(batch *leveldb.Batch, block types.Block) {
 	// 1. Save Header (Small constant size)
 	headerKey := []byte(fmt.Sprintf("block-header-%d", block.Header.Height))
 	headerData, _ := json.Marshal(block.Header)
-	if err := s.db.Put(headerKey, headerData, nil); err != nil {
-		return err
-	}
+	batch.Put(headerKey, headerData)
 
 	// 2. Save Shards Individually (Prevent 1GB allocation)
 	// Instead of marshaling the whole [10]ShardData array, we save each shard.
-	batch := new(leveldb.Batch)
 	for i, shard := range block.Shards {
 		shardKey := []byte(fmt.Sprintf("block-%d-shard-%d", block.Header.Height, i))
 		shardData, _ := json.Marshal(shard)
 		batch.Put(shardKey, shardData)
 	}
 
-	// Commit batch (LevelDB handles batch memory better than Go Heap)
-	return s.db.Write(batch, nil)
+	// 2a. Save evidence with the body (pruned like the shards)
+	if len(block.Evidence) > 0 {
+		evidenceData, _ := json.Marshal(block.Evidence)
+		batch.Put([]byte(fmt.Sprintf("block-%d-evidence", block.Header.Height)), evidenceData)
+	}
+
+	// 3. Save BFT commit certificate (kept forever, like the header)
+	if block.Commit != nil {
+		commitData, _ := json.Marshal(block.Commit)
+		batch.Put(commitKey(block.Header.Height), commitData)
+	}
 }
 
 // PruneOldBlocks dipanggil setiap kali blok baru ditambahkan
@@ -67,6 +83,7 @@ func (s *Store) PruneOldBlocks(currentHeight uint64) error {
 		key := []byte(fmt.Sprintf("block-%d-shard-%d", targetHeight, i))
 		batch.Delete(key)
 	}
+	batch.Delete([]byte(fmt.Sprintf("block-%d-evidence", targetHeight)))
 
 	// Commit delete batch
 	err := s.db.Write(batch, nil)
@@ -82,6 +99,19 @@ func (s *Store) PruneOldBlocks(currentHeight uint64) error {
 	return nil
 }
 
+// DeleteBlock removes the block at height (header, body, evidence and commit)
+// Used when a block is rolled back, e.g. after a fraud proof.
+func (s *Store) DeleteBlock(height uint64) error {
+	batch := new(leveldb.Batch)
+	batch.Delete([]byte(fmt.Sprintf("block-header-%d", height)))
+	for i := 0; i < 10; i++ {
+		batch.Delete([]byte(fmt.Sprintf("block-%d-shard-%d", height, i)))
+	}
+	batch.Delete([]byte(fmt.Sprintf("block-%d-evidence", height)))
+	batch.Delete(commitKey(height))
+	return s.db.Write(batch, nil)
+}
+
 // GetDB returns the underlying LevelDB instance
 func (s *Store) GetDB() *leveldb.DB {
 	return s.db
@@ -92,11 +122,41 @@ func (s *Store) SaveTip(tipData []byte) error {
 	return s.db.Put([]byte("tip"), tipData, nil)
 }
 
+// StageTip adds the write saving the chain tip to batch
+func (s *Store) StageTip(batch *leveldb.Batch, tipData []byte) {
+	batch.Put([]byte("tip"), tipData)
+}
+
 // GetTip loads the current chain tip
 func (s *Store) GetTip() ([]byte, error) {
 	return s.db.Get([]byte("tip"), nil)
 }
 
+// GetBlock retrieves the block at height with its body (shards and evidence)
+// Bodies older than the pruning window are gone, so this fails for them.
+func (s *Store) GetBlock(height uint64) (*types.Block, error) {
+	header, err := s.GetBlockHeaderByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	block := &types.Block{Header: *header}
+	for i := range block.Shards {
+		data, err := s.db.Get([]byte(fmt.Sprintf("block-%d-shard-%d", height, i)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("block body not found for height %d: %v", height, err)
+		}
+		if err := json.Unmarshal(data, &block.Shards[i]); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shard %d: %v", i, err)
+		}
+	}
+	if data, err := s.db.Get([]byte(fmt.Sprintf("block-%d-evidence", height)), nil); err == nil {
+		if err := json.Unmarshal(data, &block.Evidence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
+		}
+	}
+	return block, nil
+}
+
 // HasBlock checks if a block exists at the given height
 func (s *Store) HasBlock(height uint64) bool {
 	key := []byte(fmt.Sprintf("block-header-%d", height))
@@ -119,3 +179,103 @@ func (s *Store) GetBlockHeaderByHeight(height uint64) (*types.BlockHeader, error
 
 	return &header, nil
 }
+
+func commitKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("block-commit-%d", height))
+}
+
+// GetCommit retrieves the BFT commit certificate of the block at height
+func (s *Store) GetCommit(height uint64) (*types.Commit, error) {
+	data, err := s.db.Get(commitKey(height), nil)
+	if err != nil {
+		return nil, fmt.Errorf("commit not found for height %d: %v", height, err)
+	}
+
+	var commit types.Commit
+	if err := json.Unmarshal(data, &commit); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commit: %v", err)
+	}
+
+	return &commit, nil
+}
+
+// HasCommit checks if a BFT commit certificate is stored for the block at height
+func (s *Store) HasCommit(height uint64) bool {
+	_, err := s.db.Get(commitKey(height), nil)
+	return err == nil
+}
+
+// fraudulentPrefix + full header hash marks a block proven invalid by a fraud proof (kept forever)
+const fraudulentPrefix = "fraudulent-"
+
+// SaveFraudulent records that the block with the given header hash was proven invalid
+func (s *Store) SaveFraudulent(hash [32]byte) error {
+	return s.db.Put(append([]byte(fraudulentPrefix), hash[:]...), []byte{1}, nil)
+}
+
+// LoadFraudulent returns the header hashes of all blocks proven invalid
+func (s *Store) LoadFraudulent() ([][32]byte, error) {
+	var hashes [][32]byte
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(fraudulentPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var hash [32]byte
+		if copy(hash[:], iter.Key()[len(fraudulentPrefix):]) != len(hash) {
+			return nil, fmt.Errorf("corrupt fraud record %x", iter.Key())
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, iter.Error()
+}
+
+// finalityLatestKey holds the latest finalization record
+var finalityLatestKey = []byte("finality-latest")
+
+// finalityCheckpointPrefix + zero-padded height holds a checkpoint record (kept forever, sorted by height)
+const finalityCheckpointPrefix = "finality-checkpoint-"
+
+func finalityCheckpointKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", finalityCheckpointPrefix, height))
+}
+
+// SaveFinality persists a finalization record, also as a checkpoint if checkpoint is set
+func (s *Store) SaveFinality(rec finality.Record, checkpoint bool) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(finalityLatestKey, data)
+	if checkpoint {
+		batch.Put(finalityCheckpointKey(rec.Height), data)
+	}
+	return s.db.Write(batch, nil)
+}
+
+// LoadFinality returns the latest finalization record (nil if none) and all checkpoints
+func (s *Store) LoadFinality() (*finality.Record, []finality.Record, error) {
+	var latest *finality.Record
+	data, err := s.db.Get(finalityLatestKey, nil)
+	if err != nil && err != leveldb.ErrNotFound {
+		return nil, nil, err
+	}
+	if err == nil {
+		latest = new(finality.Record)
+		if err := json.Unmarshal(data, latest); err != nil {
+			return nil, nil, fmt.Errorf("corrupt finality record: %v", err)
+		}
+	}
+
+	var checkpoints []finality.Record
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(finalityCheckpointPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var rec finality.Record
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			return nil, nil, fmt.Errorf("corrupt checkpoint record %s: %v", iter.Key(), err)
+		}
+		checkpoints = append(checkpoints, rec)
+	}
+	return latest, checkpoints, iter.Error()
+}
@@ -78,6 +78,24 @@ func (p *Pool) Remove(txID [32]byte) {
 	// TODO: Remove from priority queue
 }
 
+// EvictExpired removes transactions that can no longer be included at height
+// Returns the number of evicted transactions.
+func (p *Pool) EvictExpired(height uint64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evicted := 0
+	for i := len(p.queue) - 1; i >= 0; i-- {
+		tx := p.queue[i].Tx
+		if tx.IsExpiredAt(height) {
+			heap.Remove(&p.queue, i)
+			delete(p.transactions, tx.ID)
+			evicted++
+		}
+	}
+	return evicted
+}
+
 // Size returns number of pending transactions
 func (p *Pool) Size() int {
 	p.mu.RLock()
@@ -1,10 +1,12 @@
 package validator
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"sync"
 
 	"github.com/LICODX/PoSSR-RNRCORE/internal/consensus/bft"
+	"github.com/LICODX/PoSSR-RNRCORE/internal/state"
 )
 
 // Manager manages validator lifecycle (registration, activation, removal)
@@ -17,23 +19,110 @@ type Manager struct {
 	// Pending validators (will be activated next epoch)
 	pendingSet map[[32]byte]*bft.Validator
 
+	// Earlier active sets, by the first height they validated (oldest first)
+	history []heightSet
+
 	// Configuration
 	minStake      uint64 // Minimum stake to become validator
 	maxValidators int    // Maximum number of validators
 	epochLength   uint64 // Blocks per epoch
 }
 
+// heightSet is a validator set and the first height it validated
+type heightSet struct {
+	from uint64
+	set  *bft.ValidatorSet
+}
+
+// maxSetHistory bounds the number of earlier validator sets kept for SetAt (older heights are unknown)
+const maxSetHistory = 100
+
 // NewManager creates a new validator manager
 func NewManager(minStake uint64, maxValidators int, epochLength uint64, initialValidators []*bft.Validator) *Manager {
+	activeSet := bft.NewValidatorSet(initialValidators)
 	return &Manager{
-		activeSet:     bft.NewValidatorSet(initialValidators),
+		activeSet:     activeSet,
 		pendingSet:    make(map[[32]byte]*bft.Validator),
+		history:       []heightSet{{from: 0, set: activeSet.Copy()}},
 		minStake:      minStake,
 		maxValidators: maxValidators,
 		epochLength:   epochLength,
 	}
 }
 
+// MarkActiveFrom records the current active set as the one validating blocks from height on
+func (vm *Manager) MarkActiveFrom(height uint64) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for len(vm.history) > 0 && vm.history[len(vm.history)-1].from >= height {
+		vm.history = vm.history[:len(vm.history)-1]
+	}
+	vm.history = append(vm.history, heightSet{from: height, set: vm.activeSet.Copy()})
+	if len(vm.history) > maxSetHistory {
+		vm.history = vm.history[1:]
+	}
+}
+
+// SetAt returns the validator set that validated the block at height
+// Returns nil if height is older than every set still recorded, as the set is unknown.
+func (vm *Manager) SetAt(height uint64) *bft.ValidatorSet {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+
+	for i := len(vm.history) - 1; i >= 0; i-- {
+		if vm.history[i].from <= height {
+			return vm.history[i].set.Copy()
+		}
+	}
+	return nil
+}
+
+// ApplyChainEpoch makes a validator set elected on-chain the active set for blocks from height from on
+// Returns false (changing nothing) for an epoch without validators.
+func (vm *Manager) ApplyChainEpoch(epoch *state.ValidatorEpoch, from uint64) bool {
+	if epoch == nil || len(epoch.Validators) == 0 {
+		return false
+	}
+
+	vals := make([]*bft.Validator, len(epoch.Validators))
+	for i, v := range epoch.Validators {
+		vals[i] = &bft.Validator{
+			Address:     v.ConsensusKey,
+			PubKey:      append(ed25519.PublicKey(nil), v.ConsensusKey[:]...),
+			VotingPower: v.Power,
+		}
+	}
+	vm.ApplyEpoch(epoch.Height, vals)
+	vm.MarkActiveFrom(from)
+	return true
+}
+
+// LoadHistory replays the validator set changes recorded on-chain, so a restarted
+// node knows the set of every height since (see state.StakingState.EpochHistory)
+func (vm *Manager) LoadHistory(staking *state.StakingState) error {
+	history, err := staking.EpochHistory()
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		// Chains from before the history was recorded: the stored epoch took
+		// effect after its boundary block
+		epoch, err := staking.ActiveEpoch()
+		if err != nil {
+			return err
+		}
+		if epoch != nil {
+			history = []state.EpochChange{{From: epoch.Height + 1, Epoch: epoch}}
+		}
+	}
+
+	for _, change := range history {
+		vm.ApplyChainEpoch(change.Epoch, change.From)
+	}
+	return nil
+}
+
 // RegisterValidator registers a new validator (pending activation)
 func (vm *Manager) RegisterValidator(validator *bft.Validator) error {
 	vm.mu.Lock()
@@ -178,3 +267,38 @@ func (vm *Manager) GetValidator(address [32]byte) (*bft.Validator, bool) {
 
 	return nil, false
 }
+
+// ApplyEpoch makes validators (computed on-chain at the epoch boundary height) the active set
+// Validators missing from the list are removed, stakes are updated and new ones are
+// registered and activated through the usual epoch rotation.
+func (vm *Manager) ApplyEpoch(height uint64, validators []*bft.Validator) {
+	incoming := make(map[[32]byte]bool, len(validators))
+	for _, val := range validators {
+		incoming[val.Address] = true
+	}
+
+	for _, val := range vm.GetActiveSet().Validators {
+		if !incoming[val.Address] {
+			vm.RemoveValidator(val.Address)
+		}
+	}
+	for _, val := range vm.GetPendingValidators() {
+		if !incoming[val.Address] {
+			vm.RemoveValidator(val.Address)
+		}
+	}
+
+	for _, val := range validators {
+		if _, exists := vm.GetValidator(val.Address); exists {
+			if err := vm.UpdateStake(val.Address, val.VotingPower); err != nil {
+				fmt.Printf("[Validator] Cannot update %x: %v\n", val.Address[:4], err)
+			}
+			continue
+		}
+		if err := vm.RegisterValidator(val); err != nil {
+			fmt.Printf("[Validator] Cannot register %x: %v\n", val.Address[:4], err)
+		}
+	}
+
+	vm.RotateEpoch(height, nil)
+}
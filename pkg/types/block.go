@@ -13,6 +13,19 @@ type Transaction struct {
 	Nonce     uint64
 	Signature [64]byte
 	Payload   []byte // Data for Smart Contracts (Optional)
+
+	// Validity window (0 = unbounded). A transaction may only be included in
+	// blocks with ValidAfterHeight < Height <= ExpiresAtHeight.
+	ValidAfterHeight uint64 `json:",omitempty"`
+	ExpiresAtHeight  uint64 `json:",omitempty"`
+
+	// Key that produced Signature when the Sender account has rotated its key
+	// (zero = Sender itself). Not signed: a wrong key fails verification.
+	PubKey [32]byte
+
+	// Multisig: partial signatures checked against the Sender's registered policy
+	// (empty for regular single-key transactions)
+	Signatures []MultisigSignature `json:",omitempty"`
 }
 
 // Block Header (Ringan, disimpan selamanya)
@@ -29,7 +42,40 @@ type BlockHeader struct {
 	ShardRoots     [10][32]byte // Merkle Roots of each Shard (New for Distributed Validation)
 	VRFSeed        [32]byte     // Seed untuk blok berikutnya
 	MinerPubKey    [32]byte     // Public key of the miner (VRF identity)
-	MinerSignature [64]byte     // Validator's signature of PoW hash (VRF Proof)
+	MinerSignature [64]byte     // Validator's signature of PoW hash (VRF Proof, version 1)
+	VRFProof       [80]byte     // ECVRF proof of PoW hash (version 2+)
+	EvidenceRoot   [32]byte     // Merkle root of the block's evidence (zero if none)
+	DataRoots      [10][32]byte // Merkle roots of the erasure-coded shard bodies (version 3+)
+	DataChunks     [10]uint16   // Data chunks per shard before extension (version 3+)
+}
+
+// Header versions (how VRFSeed is derived from the PoW hash)
+const (
+	HeaderVersionLegacyVRF = 1 // VRFSeed = SHA256(Ed25519 signature), in MinerSignature
+	HeaderVersionECVRF     = 2 // VRFSeed = first half of the RFC 9381 ECVRF output, proof in VRFProof
+
+	// Version 2 seeds, plus commitments to erasure-coded shard data for availability sampling
+	HeaderVersionDataAvailability = 3
+)
+
+// Heights from which headers must use each version (hard forks)
+// Networks that ran older headers set these to their upgrade heights before loading
+// the chain; new networks use the latest version from the first block on.
+var (
+	ECVRFActivationHeight            uint64 = 1
+	DataAvailabilityActivationHeight uint64 = 1
+)
+
+// HeaderVersionAt returns the only header version accepted at height
+func HeaderVersionAt(height uint64) uint32 {
+	switch {
+	case height >= DataAvailabilityActivationHeight:
+		return HeaderVersionDataAvailability
+	case height >= ECVRFActivationHeight:
+		return HeaderVersionECVRF
+	default:
+		return HeaderVersionLegacyVRF
+	}
 }
 
 // ShardData mewakili kontribusi 1 node
@@ -38,10 +84,20 @@ type ShardData struct {
 	AlgoUsed  string // e.g., "QuickSort"
 	TxData    []Transaction
 	ShardRoot [32]byte
+	Signature [64]byte // NodeID's shard proof signature (sorting race winner)
 }
 
 // Full Block (Berat 1 GB, akan di-prune)
 type Block struct {
 	Header BlockHeader
 	Shards [10]ShardData // Data 10 x 100 MB
+
+	// Evidence of validator misbehaviour, executed when the block is applied
+	Evidence []DoubleSignEvidence `json:",omitempty"`
+
+	// Commit certificate of the previous block as seen by the proposer (BFT liveness tracking)
+	LastCommit *Commit `json:",omitempty"`
+
+	// BFT commit certificate (nil for PoW blocks). Not part of the block hash.
+	Commit *Commit `json:",omitempty"`
 }
@@ -0,0 +1,37 @@
+package types
+
+// CommitSig is one validator's precommit signature in a commit certificate
+// Timestamp is part of the signed vote and is needed to re-verify it.
+type CommitSig struct {
+	ValidatorAddress [32]byte `json:"validatorAddress"`
+	Timestamp        int64    `json:"timestamp"`
+	Signature        [64]byte `json:"signature"`
+}
+
+// Commit is the BFT commit certificate of a block: the 2/3+ precommits that finalized it
+type Commit struct {
+	Height     uint64      `json:"height"`
+	Round      int32       `json:"round"`
+	BlockHash  [32]byte    `json:"blockHash"`  // BFT block ID (bft.BlockID of the header and part set)
+	Signatures []CommitSig `json:"signatures"` // Ordered by validator address
+
+	// Part set the block was proposed as (bft.PartSetHeader), bound into BlockHash
+	PartsTotal uint32   `json:"partsTotal"`
+	PartsRoot  [32]byte `json:"partsRoot"`
+}
+
+// precommitVoteType is bft.VoteTypePrecommit
+const precommitVoteType = 0x02
+
+// Vote returns the precommit sig stands for, as signed by its validator
+func (c *Commit) Vote(sig CommitSig) EvidenceVote {
+	return EvidenceVote{
+		Type:             precommitVoteType,
+		Height:           c.Height,
+		Round:            c.Round,
+		BlockHash:        c.BlockHash,
+		Timestamp:        sig.Timestamp,
+		ValidatorAddress: sig.ValidatorAddress,
+		Signature:        sig.Signature,
+	}
+}
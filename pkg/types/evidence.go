@@ -0,0 +1,107 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// EvidenceVote is a signed BFT vote as carried by evidence (mirrors bft.Vote)
+type EvidenceVote struct {
+	Type             uint8    `json:"type"` // bft.VoteType: 1 = prevote, 2 = precommit
+	Height           uint64   `json:"height"`
+	Round            int32    `json:"round"`
+	BlockHash        [32]byte `json:"blockHash"`
+	Timestamp        int64    `json:"timestamp"`
+	ValidatorAddress [32]byte `json:"validatorAddress"` // Ed25519 consensus key
+	Signature        [64]byte `json:"signature"`
+}
+
+// SignBytes returns the bytes the validator signed (must match bft.Vote.SignBytes)
+func (v *EvidenceVote) SignBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(v.Type)
+	binary.Write(&buf, binary.BigEndian, v.Height)
+	binary.Write(&buf, binary.BigEndian, v.Round)
+	buf.Write(v.BlockHash[:])
+	binary.Write(&buf, binary.BigEndian, v.Timestamp)
+	return buf.Bytes()
+}
+
+// VerifySignature checks the vote's signature against its validator's consensus key
+func (v *EvidenceVote) VerifySignature() bool {
+	return ed25519.Verify(ed25519.PublicKey(v.ValidatorAddress[:]), v.SignBytes(), v.Signature[:])
+}
+
+// DoubleSignEvidence proves that a validator signed two conflicting votes for the same height, round and step
+type DoubleSignEvidence struct {
+	Vote1 EvidenceVote `json:"vote1"`
+	Vote2 EvidenceVote `json:"vote2"`
+}
+
+// NewDoubleSignEvidence builds evidence from two conflicting votes in canonical order
+// (lower block hash first), so the same offense always has the same hash.
+func NewDoubleSignEvidence(a, b EvidenceVote) DoubleSignEvidence {
+	if bytes.Compare(a.BlockHash[:], b.BlockHash[:]) > 0 {
+		a, b = b, a
+	}
+	return DoubleSignEvidence{Vote1: a, Vote2: b}
+}
+
+// Validator returns the consensus key of the offending validator
+func (ev *DoubleSignEvidence) Validator() [32]byte {
+	return ev.Vote1.ValidatorAddress
+}
+
+// Height returns the height of the offense
+func (ev *DoubleSignEvidence) Height() uint64 {
+	return ev.Vote1.Height
+}
+
+// Hash returns the identifier of the evidence
+func (ev *DoubleSignEvidence) Hash() [32]byte {
+	var buf bytes.Buffer
+	buf.WriteString("double-sign")
+	buf.Write(ev.Vote1.ValidatorAddress[:])
+	buf.Write(ev.Vote1.SignBytes())
+	buf.Write(ev.Vote2.SignBytes())
+	return sha256.Sum256(buf.Bytes())
+}
+
+// Verify checks that the votes conflict, are in canonical order and are both signed by the validator
+func (ev *DoubleSignEvidence) Verify() error {
+	v1, v2 := &ev.Vote1, &ev.Vote2
+	if v1.ValidatorAddress != v2.ValidatorAddress {
+		return fmt.Errorf("votes from different validators")
+	}
+	if v1.Type != v2.Type || v1.Height != v2.Height || v1.Round != v2.Round {
+		return fmt.Errorf("votes are not for the same height/round/step")
+	}
+	if bytes.Compare(v1.BlockHash[:], v2.BlockHash[:]) >= 0 {
+		return fmt.Errorf("votes do not conflict or are not in canonical order")
+	}
+
+	if !v1.VerifySignature() {
+		return fmt.Errorf("invalid signature on first vote")
+	}
+	if !v2.VerifySignature() {
+		return fmt.Errorf("invalid signature on second vote")
+	}
+	return nil
+}
+
+// CalculateEvidenceRoot returns the Merkle root of the evidence hashes (Header.EvidenceRoot)
+func CalculateEvidenceRoot(evidence []DoubleSignEvidence) [32]byte {
+	if len(evidence) == 0 {
+		return [32]byte{}
+	}
+	hashes := make([][32]byte, len(evidence))
+	for i := range evidence {
+		hashes[i] = evidence[i].Hash()
+	}
+	return utils.CalculateMerkleRoot(hashes)
+}
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/utils"
+)
+
+// Fraud proof kinds
+const (
+	FraudBadSignature uint8 = 1 // A transaction is not signed by its sender
+	FraudUnsorted     uint8 = 2 // Two adjacent transactions are out of sorting race order
+	// 3 is unused: an invalid state transition cannot be proven without a state witness
+	FraudBadEncoding uint8 = 4 // The shard's committed data chunks do not decode to its transactions
+)
+
+// FraudTx is a shard transaction with its Merkle path to the shard root
+type FraudTx struct {
+	Tx    Transaction `json:"tx"`
+	Index int         `json:"index"` // Position in the sorted shard
+	Path  [][32]byte  `json:"path"`  // Sibling hashes, bottom-up
+}
+
+// FraudChunk is an erasure-coded shard chunk with its Merkle path to the shard's data root
+type FraudChunk struct {
+	Index int        `json:"index"`
+	Chunk []byte     `json:"chunk"`
+	Path  [][32]byte `json:"path"`
+}
+
+// FraudProof shows that one shard of a block is invalid
+// It carries only the offending transactions, so nodes that do not download
+// the shard (ShardNodes) can check the claim against the block header alone.
+type FraudProof struct {
+	Kind    uint8       `json:"kind"`
+	Header  BlockHeader `json:"header"` // Header of the fraudulent block
+	ShardID uint8       `json:"shardId"`
+	Txs     []FraudTx   `json:"txs"` // One transaction, or two adjacent ones for FraudUnsorted
+
+	// Enough chunks to decode the shard (FraudBadEncoding only)
+	Chunks []FraudChunk `json:"chunks,omitempty"`
+}
+
+// BlockHash returns the hash of the block the proof is about
+// It covers the whole header: the PoW hash leaves out the shard roots, so a
+// proof against a doctored copy of an honest header must not match the block.
+func (p *FraudProof) BlockHash() [32]byte {
+	return HashBlockHeader(p.Header)
+}
+
+// Hash returns the identifier of the proof
+func (p *FraudProof) Hash() [32]byte {
+	var buf bytes.Buffer
+	buf.WriteString("shard-fraud")
+	buf.WriteByte(p.Kind)
+	blockHash := p.BlockHash()
+	buf.Write(blockHash[:])
+	buf.WriteByte(p.ShardID)
+	for _, ftx := range p.Txs {
+		binary.Write(&buf, binary.BigEndian, uint64(ftx.Index))
+		buf.Write(ftx.Tx.ID[:])
+	}
+	for _, chunk := range p.Chunks {
+		binary.Write(&buf, binary.BigEndian, uint64(chunk.Index))
+		leaf := sha256.Sum256(chunk.Chunk)
+		buf.Write(leaf[:])
+	}
+	return sha256.Sum256(buf.Bytes())
+}
+
+// VerifyInclusion checks that the proof's transactions are in the header's shard
+// It does not check the offence itself, which needs the consensus rules. The chunks
+// of a FraudBadEncoding proof are checked against the data root when it is decoded.
+func (p *FraudProof) VerifyInclusion() error {
+	if p.ShardID >= 10 {
+		return fmt.Errorf("invalid shard ID: %d", p.ShardID)
+	}
+
+	var want int
+	switch p.Kind {
+	case FraudBadSignature:
+		want = 1
+	case FraudUnsorted:
+		want = 2
+	case FraudBadEncoding:
+		want = 0
+	default:
+		return fmt.Errorf("unknown fraud proof kind %d", p.Kind)
+	}
+	switch {
+	case len(p.Txs) != want:
+		return fmt.Errorf("fraud proof kind %d needs %d transactions, got %d", p.Kind, want, len(p.Txs))
+	case p.Kind != FraudBadEncoding && len(p.Chunks) > 0:
+		return fmt.Errorf("fraud proof kind %d carries data chunks", p.Kind)
+	case want == 2 && p.Txs[1].Index != p.Txs[0].Index+1:
+		return fmt.Errorf("transactions at %d and %d are not adjacent", p.Txs[0].Index, p.Txs[1].Index)
+	}
+
+	// The shard roots must be the ones the header's merkle root commits to; which
+	// block the header belongs to is up to the caller (see BlockHash)
+	roots := make([][32]byte, len(p.Header.ShardRoots))
+	for i, root := range p.Header.ShardRoots {
+		roots[i] = root
+	}
+	if utils.CalculateMerkleRoot(roots) != p.Header.MerkleRoot {
+		return fmt.Errorf("shard roots do not match the header's merkle root")
+	}
+
+	root := p.Header.ShardRoots[p.ShardID]
+	for _, ftx := range p.Txs {
+		// Signature proofs are about the contents, which only the ID commits to
+		if p.Kind != FraudUnsorted && HashTransaction(ftx.Tx) != ftx.Tx.ID {
+			return fmt.Errorf("transaction %x does not match its ID", ftx.Tx.ID[:4])
+		}
+		if !verifyShardPath(ftx, root) {
+			return fmt.Errorf("transaction %x is not at index %d of shard %d", ftx.Tx.ID[:4], ftx.Index, p.ShardID)
+		}
+	}
+	return nil
+}
+
+// verifyShardPath checks ftx's Merkle path, rejecting indices past the end of the shard
+// CalculateMerkleRoot pads odd levels by duplicating the last node, so a path can also
+// "prove" a padding copy at an index that holds no transaction. A padding copy is
+// always a right child equal to its sibling.
+func verifyShardPath(ftx FraudTx, root [32]byte) bool {
+	if ftx.Index < 0 {
+		return false
+	}
+
+	current, index := ftx.Tx.ID, ftx.Index
+	for _, sibling := range ftx.Path {
+		if index%2 == 1 && sibling == current {
+			return false
+		}
+		if index%2 == 0 {
+			current = sha256.Sum256(append(current[:], sibling[:]...))
+		} else {
+			current = sha256.Sum256(append(sibling[:], current[:]...))
+		}
+		index /= 2
+	}
+	return index == 0 && current == root
+}
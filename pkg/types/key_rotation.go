@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Account Key Rotation Types
+
+const (
+	TxTypeKeyRotate = 21 // Rebind the Sender account to a new public key
+)
+
+// KeyRotatePayload for rotating an account key (JSON encoded in Transaction.Payload)
+type KeyRotatePayload struct {
+	NewPubKey [32]byte `json:"newPubKey"`
+}
+
+// SigningKey returns the public key that must have produced tx.Signature
+// Accounts that never rotated sign with their identifier (Sender) as key.
+func (tx *Transaction) SigningKey() [32]byte {
+	if tx.PubKey != ([32]byte{}) {
+		return tx.PubKey
+	}
+	return tx.Sender
+}
+
+// DecodeKeyRotate parses and checks the payload of a TxTypeKeyRotate transaction
+func DecodeKeyRotate(tx Transaction) (*KeyRotatePayload, error) {
+	if tx.Type != TxTypeKeyRotate {
+		return nil, fmt.Errorf("not a key rotation transaction (type %d)", tx.Type)
+	}
+	if tx.Amount != 0 || tx.Receiver != ([32]byte{}) {
+		return nil, fmt.Errorf("key rotation must not transfer funds")
+	}
+
+	var payload KeyRotatePayload
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid key rotation payload: %v", err)
+	}
+	if payload.NewPubKey == ([32]byte{}) {
+		return nil, fmt.Errorf("key rotation to empty key")
+	}
+	if payload.NewPubKey == tx.SigningKey() {
+		return nil, fmt.Errorf("key rotation to the current key")
+	}
+	return &payload, nil
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Batch Multi-Transfer Types
+
+const (
+	TxTypeMultiTransfer = 6 // Pay many receivers (RNR and/or tokens) in one transaction
+
+	MaxTransferOutputs = 256 // Upper bound on outputs per batch
+)
+
+// TransferOutput is a single payment of a multi-transfer
+// A zero Token address means native RNR.
+type TransferOutput struct {
+	Receiver [32]byte `json:"receiver"`
+	Amount   uint64   `json:"amount"`
+	Token    [32]byte `json:"token,omitempty"`
+}
+
+// MultiTransferPayload for batch transfers (JSON encoded in Transaction.Payload)
+type MultiTransferPayload struct {
+	Outputs []TransferOutput `json:"outputs"`
+}
+
+// IsNative returns true if the output pays native RNR
+func (o TransferOutput) IsNative() bool {
+	return o.Token == [32]byte{}
+}
+
+// DecodeMultiTransfer parses and checks the payload of a TxTypeMultiTransfer transaction
+// tx.Amount must equal the sum of the native RNR outputs.
+func DecodeMultiTransfer(tx Transaction) (*MultiTransferPayload, error) {
+	if tx.Type != TxTypeMultiTransfer {
+		return nil, fmt.Errorf("not a multi-transfer transaction (type %d)", tx.Type)
+	}
+
+	if tx.Receiver != [32]byte{} {
+		return nil, fmt.Errorf("multi-transfer must not set Receiver")
+	}
+
+	var payload MultiTransferPayload
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid multi-transfer payload: %v", err)
+	}
+
+	n := len(payload.Outputs)
+	if n == 0 || n > MaxTransferOutputs {
+		return nil, fmt.Errorf("invalid number of outputs: %d (max %d)", n, MaxTransferOutputs)
+	}
+
+	var native uint64
+	for i, out := range payload.Outputs {
+		if out.Amount == 0 {
+			return nil, fmt.Errorf("output %d has zero amount", i)
+		}
+		if out.Receiver == tx.Sender {
+			return nil, fmt.Errorf("output %d pays the sender", i)
+		}
+		if out.IsNative() {
+			if native+out.Amount < native {
+				return nil, fmt.Errorf("native output total overflows")
+			}
+			native += out.Amount
+		}
+	}
+	if native != tx.Amount {
+		return nil, fmt.Errorf("amount %d does not match native outputs %d", tx.Amount, native)
+	}
+
+	return &payload, nil
+}
+
+// Outputs returns the payments made by tx
+// Multi-transfers list their payload outputs; other transactions pay Amount to Receiver.
+func (tx *Transaction) Outputs() ([]TransferOutput, error) {
+	if tx.Type == TxTypeMultiTransfer {
+		payload, err := DecodeMultiTransfer(*tx)
+		if err != nil {
+			return nil, err
+		}
+		return payload.Outputs, nil
+	}
+	return []TransferOutput{{Receiver: tx.Receiver, Amount: tx.Amount}}, nil
+}
@@ -0,0 +1,129 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// Multisig Account Types (M-of-N ed25519)
+
+const (
+	TxTypeMultisigRegister = 20 // Register an M-of-N policy under its derived address
+
+	MaxMultisigKeys = 16 // Upper bound on N to keep validation cheap
+)
+
+// MultisigPolicy defines the keys allowed to sign for a multisig account
+// PubKeys are kept in canonical (ascending) order; signature KeyIndex refers to this order.
+type MultisigPolicy struct {
+	Threshold uint8      `json:"threshold"` // M: signatures required
+	PubKeys   [][32]byte `json:"pubKeys"`   // N: allowed signers
+}
+
+// MultisigRegisterPayload for registering a multisig account (JSON encoded in Transaction.Payload)
+type MultisigRegisterPayload struct {
+	Threshold uint8      `json:"threshold"`
+	PubKeys   [][32]byte `json:"pubKeys"`
+}
+
+// MultisigSignature is one partial signature of a multisig transaction
+type MultisigSignature struct {
+	KeyIndex  uint8    `json:"keyIndex"` // Index into MultisigPolicy.PubKeys
+	Signature [64]byte `json:"signature"`
+}
+
+// NewMultisigPolicy builds a policy with keys sorted into canonical order
+func NewMultisigPolicy(threshold uint8, pubKeys [][32]byte) (*MultisigPolicy, error) {
+	keys := make([][32]byte, len(pubKeys))
+	copy(keys, pubKeys)
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+
+	policy := &MultisigPolicy{Threshold: threshold, PubKeys: keys}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate checks the policy is well-formed (1 <= M <= N <= MaxMultisigKeys, sorted, unique)
+func (p *MultisigPolicy) Validate() error {
+	n := len(p.PubKeys)
+	if n == 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("invalid number of multisig keys: %d (max %d)", n, MaxMultisigKeys)
+	}
+	if p.Threshold == 0 || int(p.Threshold) > n {
+		return fmt.Errorf("invalid multisig threshold: %d of %d", p.Threshold, n)
+	}
+	for i := 1; i < n; i++ {
+		if bytes.Compare(p.PubKeys[i-1][:], p.PubKeys[i][:]) >= 0 {
+			return fmt.Errorf("multisig keys must be unique and sorted")
+		}
+	}
+	return nil
+}
+
+// Address derives the account identifier of the policy
+// Address = SHA256("rnr-multisig" + M + PubKey_1 + ... + PubKey_N)
+func (p *MultisigPolicy) Address() [32]byte {
+	var buf bytes.Buffer
+	buf.WriteString("rnr-multisig")
+	buf.WriteByte(p.Threshold)
+	for _, key := range p.PubKeys {
+		buf.Write(key[:])
+	}
+	return sha256.Sum256(buf.Bytes())
+}
+
+// IndexOf returns the index of pubKey in the policy, or -1 if it is not a signer
+func (p *MultisigPolicy) IndexOf(pubKey [32]byte) int {
+	for i, key := range p.PubKeys {
+		if key == pubKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// CountValidSignatures verifies every partial signature over message
+// Returns an error on unknown key index, duplicate signer or invalid signature.
+func (p *MultisigPolicy) CountValidSignatures(message []byte, sigs []MultisigSignature) (int, error) {
+	seen := make(map[uint8]bool, len(sigs))
+	for _, sig := range sigs {
+		if int(sig.KeyIndex) >= len(p.PubKeys) {
+			return 0, fmt.Errorf("multisig key index %d out of range", sig.KeyIndex)
+		}
+		if seen[sig.KeyIndex] {
+			return 0, fmt.Errorf("duplicate multisig signature for key %d", sig.KeyIndex)
+		}
+		seen[sig.KeyIndex] = true
+
+		key := p.PubKeys[sig.KeyIndex]
+		if !ed25519.Verify(ed25519.PublicKey(key[:]), message, sig.Signature[:]) {
+			return 0, fmt.Errorf("invalid multisig signature for key %d", sig.KeyIndex)
+		}
+	}
+	return len(sigs), nil
+}
+
+// VerifyTransaction checks that tx carries at least Threshold valid signatures
+// Co-signers sign the same bytes as single-key transactions, type included.
+func (p *MultisigPolicy) VerifyTransaction(tx Transaction) error {
+	count, err := p.CountValidSignatures(SerializeTransaction(tx), tx.Signatures)
+	if err != nil {
+		return err
+	}
+	if count < int(p.Threshold) {
+		return fmt.Errorf("not enough multisig signatures: have %d, need %d", count, p.Threshold)
+	}
+	return nil
+}
+
+// IsMultisig returns true if the transaction is authorized by partial signatures
+func (tx *Transaction) IsMultisig() bool {
+	return len(tx.Signatures) > 0
+}
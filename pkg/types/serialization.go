@@ -6,9 +6,31 @@ import (
 	"encoding/binary"
 )
 
+// txWindowTag prefixes transactions that carry a validity window.
+// It keeps the window signed without changing the bytes of existing transactions,
+// and prevents the window from being stripped by re-encoding it as payload.
+var txWindowTag = []byte("rnr-tx-window-v1")
+
+// commitIDTag separates BFT block IDs from other header hashes
+var commitIDTag = []byte("rnr-block-id-v1")
+
+// txTypeTag prefixes transactions of every type other than a plain RNR transfer.
+// Signing the type keeps one transaction from being replayed as another with the
+// same fields (e.g. a Bond as an Unbond), while plain transfers keep their hash.
+var txTypeTag = []byte("rnr-tx-type-v1")
+
 // SerializeTransaction creates a canonical byte representation for signing
 func SerializeTransaction(tx Transaction) []byte {
 	var buf bytes.Buffer
+	if tx.Type != TxTypeRNRTransfer {
+		buf.Write(txTypeTag)
+		binary.Write(&buf, binary.LittleEndian, int64(tx.Type))
+	}
+	if tx.HasValidityWindow() {
+		buf.Write(txWindowTag)
+		binary.Write(&buf, binary.LittleEndian, tx.ValidAfterHeight)
+		binary.Write(&buf, binary.LittleEndian, tx.ExpiresAtHeight)
+	}
 	buf.Write(tx.Sender[:])
 	buf.Write(tx.Receiver[:])
 	binary.Write(&buf, binary.LittleEndian, tx.Amount)
@@ -39,6 +61,19 @@ func SerializeBlockHeader(h BlockHeader) []byte {
 	buf.Write(h.VRFSeed[:])
 	buf.Write(h.MinerPubKey[:])
 	buf.Write(h.MinerSignature[:])
+	if h.Version >= HeaderVersionECVRF {
+		buf.Write(h.VRFProof[:])
+	}
+	if h.Version >= HeaderVersionDataAvailability {
+		for i, root := range h.DataRoots {
+			buf.Write(root[:])
+			binary.Write(&buf, binary.LittleEndian, h.DataChunks[i])
+		}
+	}
+	// Only headers carrying evidence commit to it, keeping older header hashes unchanged
+	if h.EvidenceRoot != ([32]byte{}) {
+		buf.Write(h.EvidenceRoot[:])
+	}
 	return buf.Bytes()
 }
 
@@ -62,3 +97,17 @@ func HashBlockHeaderForPoW(h BlockHeader) [32]byte {
 	// SKIP VRFSeed - determined after PoW
 	return sha256.Sum256(buf.Bytes())
 }
+
+// HashBlockHeaderForCommit calculates the block ID BFT validators vote on and commit certificates sign
+// The PoW hash leaves out everything set after mining, so the ID also covers the full
+// header: shard, data and evidence roots, VRF seed and proof and the race winners.
+func HashBlockHeaderForCommit(h BlockHeader) [32]byte {
+	pow := HashBlockHeaderForPoW(h)
+	full := HashBlockHeader(h)
+
+	var buf bytes.Buffer
+	buf.Write(commitIDTag)
+	buf.Write(pow[:])
+	buf.Write(full[:])
+	return sha256.Sum256(buf.Bytes())
+}
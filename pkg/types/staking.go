@@ -0,0 +1,166 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Staking Transaction Types
+
+const (
+	TxTypeStakeBond     = 30 // Lock Amount of the Sender's RNR as validator stake
+	TxTypeStakeUnbond   = 31 // Start unbonding Amount of the Sender's stake
+	TxTypeEditValidator = 32 // Change the Sender validator's description
+	TxTypeUnjail        = 33 // Return the Sender's jailed validator to the candidate set
+
+	TxTypeDelegate        = 34 // Delegate Amount of the Sender's RNR to a validator
+	TxTypeUndelegate      = 35 // Start unbonding Amount of the Sender's delegation
+	TxTypeWithdrawRewards = 36 // Move the Sender's accrued delegation rewards to its balance
+
+	MaxMonikerLength  = 64
+	MaxCommissionRate = 10000 // Commission rates are in basis points (10000 = 100%)
+)
+
+// StakeBondPayload for bonding stake (JSON encoded in Transaction.Payload)
+// ConsensusKey is required on the first bond and must be left empty or unchanged afterwards;
+// CommissionRate only applies to the first bond (use edit-validator to change it).
+type StakeBondPayload struct {
+	ConsensusKey   [32]byte `json:"consensusKey"`
+	Moniker        string   `json:"moniker,omitempty"`
+	CommissionRate uint32   `json:"commissionRate,omitempty"`
+}
+
+// EditValidatorPayload for editing a validator (JSON encoded in Transaction.Payload)
+// Moniker replaces the current one; CommissionRate is left unchanged when omitted.
+type EditValidatorPayload struct {
+	Moniker        string  `json:"moniker"`
+	CommissionRate *uint32 `json:"commissionRate,omitempty"`
+}
+
+// DelegationPayload names the validator (operator account) of a delegation transaction
+type DelegationPayload struct {
+	Validator [32]byte `json:"validator"`
+}
+
+// IsStakingTx returns true for the staking transaction types
+func IsStakingTx(txType int) bool {
+	return txType >= TxTypeStakeBond && txType <= TxTypeWithdrawRewards
+}
+
+// IsDelegationTx returns true for transactions acting on the Sender's delegation to a validator
+func IsDelegationTx(txType int) bool {
+	return txType >= TxTypeDelegate && txType <= TxTypeWithdrawRewards
+}
+
+// PaidFromStake returns true for transactions whose Amount comes out of stake instead of the balance
+func PaidFromStake(txType int) bool {
+	return txType == TxTypeStakeUnbond || txType == TxTypeUndelegate
+}
+
+// DecodeStakeBond parses and checks the payload of a TxTypeStakeBond transaction
+func DecodeStakeBond(tx Transaction) (*StakeBondPayload, error) {
+	if tx.Type != TxTypeStakeBond {
+		return nil, fmt.Errorf("not a bond transaction (type %d)", tx.Type)
+	}
+
+	var payload StakeBondPayload
+	if len(tx.Payload) > 0 {
+		if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid bond payload: %v", err)
+		}
+	}
+	if len(payload.Moniker) > MaxMonikerLength {
+		return nil, fmt.Errorf("moniker too long: %d > %d", len(payload.Moniker), MaxMonikerLength)
+	}
+	if payload.CommissionRate > MaxCommissionRate {
+		return nil, fmt.Errorf("commission rate %d exceeds %d", payload.CommissionRate, MaxCommissionRate)
+	}
+	return &payload, nil
+}
+
+// DecodeEditValidator parses and checks the payload of a TxTypeEditValidator transaction
+func DecodeEditValidator(tx Transaction) (*EditValidatorPayload, error) {
+	if tx.Type != TxTypeEditValidator {
+		return nil, fmt.Errorf("not an edit-validator transaction (type %d)", tx.Type)
+	}
+
+	var payload EditValidatorPayload
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid edit-validator payload: %v", err)
+	}
+	if len(payload.Moniker) > MaxMonikerLength {
+		return nil, fmt.Errorf("moniker too long: %d > %d", len(payload.Moniker), MaxMonikerLength)
+	}
+	if payload.CommissionRate != nil && *payload.CommissionRate > MaxCommissionRate {
+		return nil, fmt.Errorf("commission rate %d exceeds %d", *payload.CommissionRate, MaxCommissionRate)
+	}
+	return &payload, nil
+}
+
+// DecodeDelegation parses and checks the payload of a delegate, undelegate or withdraw-rewards transaction
+func DecodeDelegation(tx Transaction) (*DelegationPayload, error) {
+	if !IsDelegationTx(tx.Type) {
+		return nil, fmt.Errorf("not a delegation transaction (type %d)", tx.Type)
+	}
+
+	var payload DelegationPayload
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid delegation payload: %v", err)
+	}
+	if payload.Validator == ([32]byte{}) {
+		return nil, fmt.Errorf("delegation names no validator")
+	}
+	return &payload, nil
+}
+
+// CheckStakingTx checks the shape of a staking transaction (no state required)
+// Bond, unbond, delegate and undelegate move Amount of stake; edit, unjail and
+// withdraw-rewards move nothing. Staking transactions never have a Receiver: the
+// Sender is the validator operator or delegator, the validator goes in the payload.
+func CheckStakingTx(tx Transaction) error {
+	if tx.Receiver != ([32]byte{}) {
+		return fmt.Errorf("staking transaction must not have a receiver")
+	}
+
+	switch tx.Type {
+	case TxTypeStakeBond:
+		if tx.Amount == 0 {
+			return fmt.Errorf("zero bond amount")
+		}
+		_, err := DecodeStakeBond(tx)
+		return err
+	case TxTypeStakeUnbond:
+		if tx.Amount == 0 {
+			return fmt.Errorf("zero unbond amount")
+		}
+		if len(tx.Payload) > 0 {
+			return fmt.Errorf("unbond takes no payload")
+		}
+	case TxTypeEditValidator:
+		if tx.Amount != 0 {
+			return fmt.Errorf("edit-validator must not transfer funds")
+		}
+		_, err := DecodeEditValidator(tx)
+		return err
+	case TxTypeUnjail:
+		if tx.Amount != 0 || len(tx.Payload) > 0 {
+			return fmt.Errorf("unjail takes no amount or payload")
+		}
+	case TxTypeDelegate, TxTypeUndelegate:
+		// Both share a payload: only the signed type (see SerializeTransaction) tells them apart
+		if tx.Amount == 0 {
+			return fmt.Errorf("zero delegation amount")
+		}
+		_, err := DecodeDelegation(tx)
+		return err
+	case TxTypeWithdrawRewards:
+		if tx.Amount != 0 {
+			return fmt.Errorf("withdraw-rewards must not transfer funds")
+		}
+		_, err := DecodeDelegation(tx)
+		return err
+	default:
+		return fmt.Errorf("not a staking transaction (type %d)", tx.Type)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package types
+
+import "fmt"
+
+// HasValidityWindow returns true if either bound of the validity window is set
+func (tx *Transaction) HasValidityWindow() bool {
+	return tx.ValidAfterHeight != 0 || tx.ExpiresAtHeight != 0
+}
+
+// IsExpiredAt returns true if the transaction can no longer be included at height
+func (tx *Transaction) IsExpiredAt(height uint64) bool {
+	return tx.ExpiresAtHeight != 0 && height > tx.ExpiresAtHeight
+}
+
+// CheckValidityWindow verifies the transaction may be included in a block at height
+func (tx *Transaction) CheckValidityWindow(height uint64) error {
+	if tx.ExpiresAtHeight != 0 && tx.ExpiresAtHeight <= tx.ValidAfterHeight {
+		return fmt.Errorf("empty validity window: valid after %d, expires at %d",
+			tx.ValidAfterHeight, tx.ExpiresAtHeight)
+	}
+	if height <= tx.ValidAfterHeight {
+		return fmt.Errorf("transaction not yet valid: height %d, valid after %d", height, tx.ValidAfterHeight)
+	}
+	if tx.IsExpiredAt(height) {
+		return fmt.Errorf("transaction expired: height %d, expires at %d", height, tx.ExpiresAtHeight)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// ECVRF-EDWARDS25519-SHA512-TAI (RFC 9381, section 5.5)
+const (
+	VRFProofSize  = 80 // Gamma (32) | c (16) | s (32)
+	VRFOutputSize = 64 // beta, SHA-512 output
+
+	vrfSuite = 0x03
+	vrfCLen  = 16
+)
+
+// VRFProve returns the RFC 9381 ECVRF proof of alpha under the Ed25519 key priv
+func VRFProve(priv ed25519.PrivateKey, alpha []byte) ([VRFProofSize]byte, error) {
+	var pi [VRFProofSize]byte
+	if len(priv) != ed25519.PrivateKeySize {
+		return pi, fmt.Errorf("invalid private key size: %d", len(priv))
+	}
+
+	// 1. Secret scalar x and public key Y as in RFC 8032
+	hashedSK := sha512.Sum512(priv.Seed())
+	x, err := edwards25519.NewScalar().SetBytesWithClamping(hashedSK[:32])
+	if err != nil {
+		return pi, err
+	}
+	Y := new(edwards25519.Point).ScalarBaseMult(x)
+	pk := Y.Bytes()
+
+	// 2-5. H = encode_to_curve(PK, alpha), Gamma = x*H
+	H, err := vrfEncodeToCurve(pk, alpha)
+	if err != nil {
+		return pi, err
+	}
+	hString := H.Bytes()
+	gamma := new(edwards25519.Point).ScalarMult(x, H)
+
+	// 6. Nonce k = SHA512(hashedSK[32:] | h_string) mod q
+	nonce := sha512.New()
+	nonce.Write(hashedSK[32:])
+	nonce.Write(hString)
+	k, err := edwards25519.NewScalar().SetUniformBytes(nonce.Sum(nil))
+	if err != nil {
+		return pi, err
+	}
+
+	// 7-8. c = challenge(Y, H, Gamma, k*B, k*H), s = k + c*x mod q
+	kB := new(edwards25519.Point).ScalarBaseMult(k)
+	kH := new(edwards25519.Point).ScalarMult(k, H)
+	cBytes := vrfChallenge(Y, H, gamma, kB, kH)
+	c, err := vrfChallengeScalar(cBytes)
+	if err != nil {
+		return pi, err
+	}
+	s := edwards25519.NewScalar().MultiplyAdd(c, x, k)
+
+	// 9. pi = Gamma | c | s
+	copy(pi[:32], gamma.Bytes())
+	copy(pi[32:48], cBytes)
+	copy(pi[48:], s.Bytes())
+	return pi, nil
+}
+
+// VRFVerify checks pi is a valid proof of alpha under pubKey and returns its output beta
+func VRFVerify(pubKey []byte, alpha []byte, pi [VRFProofSize]byte) ([VRFOutputSize]byte, error) {
+	var beta [VRFOutputSize]byte
+
+	// 1-2. Decode and validate the public key (no low-order points)
+	Y, err := vrfDecodePoint(pubKey)
+	if err != nil {
+		return beta, fmt.Errorf("invalid VRF public key: %w", err)
+	}
+	if new(edwards25519.Point).MultByCofactor(Y).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return beta, fmt.Errorf("invalid VRF public key: low order point")
+	}
+
+	// 3-4. Decode the proof
+	gamma, c, s, err := vrfDecodeProof(pi)
+	if err != nil {
+		return beta, err
+	}
+
+	// 5-7. U = s*B - c*Y, V = s*H - c*Gamma
+	H, err := vrfEncodeToCurve(pubKey, alpha)
+	if err != nil {
+		return beta, err
+	}
+	negC := edwards25519.NewScalar().Negate(c)
+	U := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negC, Y, s)
+	V := new(edwards25519.Point).VarTimeMultiScalarMult([]*edwards25519.Scalar{s, negC}, []*edwards25519.Point{H, gamma})
+
+	// 8-9. The challenge must match
+	if !bytes.Equal(vrfChallenge(Y, H, gamma, U, V), pi[32:48]) {
+		return beta, fmt.Errorf("invalid VRF proof")
+	}
+	return vrfGammaToHash(gamma), nil
+}
+
+// VRFProofToHash returns the output beta of a proof without verifying it
+func VRFProofToHash(pi [VRFProofSize]byte) ([VRFOutputSize]byte, error) {
+	gamma, _, _, err := vrfDecodeProof(pi)
+	if err != nil {
+		return [VRFOutputSize]byte{}, err
+	}
+	return vrfGammaToHash(gamma), nil
+}
+
+// vrfGammaToHash computes beta = SHA512(suite | 0x03 | cofactor*Gamma | 0x00)
+func vrfGammaToHash(gamma *edwards25519.Point) [VRFOutputSize]byte {
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x03})
+	h.Write(new(edwards25519.Point).MultByCofactor(gamma).Bytes())
+	h.Write([]byte{0x00})
+
+	var beta [VRFOutputSize]byte
+	copy(beta[:], h.Sum(nil))
+	return beta
+}
+
+// vrfEncodeToCurve hashes alpha to a point with try-and-increment (section 5.4.1.1)
+func vrfEncodeToCurve(salt []byte, alpha []byte) (*edwards25519.Point, error) {
+	identity := edwards25519.NewIdentityPoint()
+	for ctr := 0; ctr < 256; ctr++ {
+		h := sha512.New()
+		h.Write([]byte{vrfSuite, 0x01})
+		h.Write(salt)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+
+		point, err := vrfDecodePoint(h.Sum(nil)[:32])
+		if err != nil {
+			continue
+		}
+		point.MultByCofactor(point)
+		if point.Equal(identity) == 0 {
+			return point, nil
+		}
+	}
+	return nil, fmt.Errorf("encode to curve failed")
+}
+
+// vrfChallenge computes the truncated challenge hash over the five points (section 5.4.3)
+func vrfChallenge(points ...*edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x02})
+	for _, p := range points {
+		h.Write(p.Bytes())
+	}
+	h.Write([]byte{0x00})
+	return h.Sum(nil)[:vrfCLen]
+}
+
+// vrfChallengeScalar reads a little-endian cLen-byte challenge as a scalar
+func vrfChallengeScalar(c []byte) (*edwards25519.Scalar, error) {
+	var buf [32]byte
+	copy(buf[:], c)
+	return edwards25519.NewScalar().SetCanonicalBytes(buf[:])
+}
+
+// vrfDecodeProof splits pi into Gamma, c and s (section 5.4.4)
+func vrfDecodeProof(pi [VRFProofSize]byte) (*edwards25519.Point, *edwards25519.Scalar, *edwards25519.Scalar, error) {
+	gamma, err := vrfDecodePoint(pi[:32])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid VRF proof: %w", err)
+	}
+	c, err := vrfChallengeScalar(pi[32:48])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid VRF proof: %w", err)
+	}
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(pi[48:])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid VRF proof: s not reduced")
+	}
+	return gamma, c, s, nil
+}
+
+// vrfDecodePoint decodes a point as in RFC 8032, rejecting non-canonical encodings
+func vrfDecodePoint(b []byte) (*edwards25519.Point, error) {
+	p, err := new(edwards25519.Point).SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.Bytes(), b) {
+		return nil, fmt.Errorf("non-canonical point encoding")
+	}
+	return p, nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 9381, appendix B.3 (ECVRF-EDWARDS25519-SHA512-TAI)
+func TestECVRFVectors(t *testing.T) {
+	vectors := []struct {
+		sk, pk, alpha, pi, beta string
+	}{
+		{
+			sk:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			pk:    "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			alpha: "",
+			pi:    "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f723f26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0daab1268a1b0db10836d9826a528ca76567805",
+			beta:  "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876ff66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fdd8ae",
+		},
+		{
+			sk:    "c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+			pk:    "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+			alpha: "af82",
+			pi:    "9bc0f79119cc5604bf02d23b4caede71393cedfbb191434dd016d30177ccbf8096bb474e53895c362d8628ee9f9ea3c0e52c7a5c691b6c18c9979866568add7a2d41b00b05081ed0f58ee5e31b3a970e",
+			beta:  "645427e5d00c62a23fb703732fa5d892940935942101e456ecca7bb217c61c452118fec1219202a0edcf038bb6373241578be7217ba85a2687f7a0310b2df19f",
+		},
+	}
+
+	for _, v := range vectors {
+		sk, _ := hex.DecodeString(v.sk)
+		alpha, _ := hex.DecodeString(v.alpha)
+		priv := ed25519.NewKeyFromSeed(sk)
+		pub := priv.Public().(ed25519.PublicKey)
+		if hex.EncodeToString(pub) != v.pk {
+			t.Fatalf("public key mismatch: %x", pub)
+		}
+
+		pi, err := VRFProve(priv, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(pi[:]) != v.pi {
+			t.Errorf("proof mismatch for alpha %q: %x", v.alpha, pi)
+		}
+
+		beta, err := VRFVerify(pub, alpha, pi)
+		if err != nil {
+			t.Fatalf("valid proof rejected: %v", err)
+		}
+		if hex.EncodeToString(beta[:]) != v.beta {
+			t.Errorf("output mismatch for alpha %q: %x", v.alpha, beta)
+		}
+		if hashed, _ := VRFProofToHash(pi); hashed != beta {
+			t.Error("proof to hash differs from the verified output")
+		}
+
+		// Another input or a tampered proof must fail
+		if _, err := VRFVerify(pub, append(alpha, 0x00), pi); err == nil {
+			t.Error("proof verified for another input")
+		}
+		pi[40] ^= 0x01
+		if _, err := VRFVerify(pub, alpha, pi); err == nil {
+			t.Error("tampered proof verified")
+		}
+	}
+}
@@ -35,3 +35,54 @@ func CalculateMerkleRoot(hashes [][32]byte) [32]byte {
 
 	return currentLevel[0]
 }
+
+// CalculateMerkleProof returns the sibling hashes (bottom-up) proving hashes[index]
+// against CalculateMerkleRoot(hashes)
+func CalculateMerkleProof(hashes [][32]byte, index int) [][32]byte {
+	if index < 0 || index >= len(hashes) {
+		return nil
+	}
+
+	currentLevel := make([][32]byte, len(hashes))
+	copy(currentLevel, hashes)
+
+	var proof [][32]byte
+	for len(currentLevel) > 1 {
+		if len(currentLevel)%2 != 0 {
+			currentLevel = append(currentLevel, currentLevel[len(currentLevel)-1])
+		}
+
+		proof = append(proof, currentLevel[index^1])
+
+		var nextLevel [][32]byte
+		for i := 0; i < len(currentLevel); i += 2 {
+			combined := append(currentLevel[i][:], currentLevel[i+1][:]...)
+			nextLevel = append(nextLevel, sha256.Sum256(combined))
+		}
+		currentLevel = nextLevel
+		index /= 2
+	}
+
+	return proof
+}
+
+// VerifyMerkleProof checks that leaf sits at index under root
+func VerifyMerkleProof(leaf [32]byte, index int, proof [][32]byte, root [32]byte) bool {
+	if index < 0 {
+		return false
+	}
+
+	current := leaf
+	for _, sibling := range proof {
+		var combined []byte
+		if index%2 == 0 {
+			combined = append(current[:], sibling[:]...)
+		} else {
+			combined = append(sibling[:], current[:]...)
+		}
+		current = sha256.Sum256(combined)
+		index /= 2
+	}
+
+	return index == 0 && current == root
+}
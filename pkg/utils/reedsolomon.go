@@ -0,0 +1,135 @@
+package utils
+
+import "fmt"
+
+// Reed-Solomon erasure code over GF(2^8) (x^8 + x^4 + x^3 + x^2 + 1)
+// Chunk i of a code word holds, byte by byte, the value at x = i of the polynomial
+// of degree < k through the k data chunks. Data chunks are kept as they are
+// (systematic code) and any k of the n chunks recover all others.
+
+// RSMaxChunks is the largest code word: every chunk needs its own field element
+const RSMaxChunks = 256
+
+var (
+	gfExp [2 * 255]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// lagrangeCoefficients returns c with f(x) = sum c[j]*f(xs[j]) for every f of degree < len(xs)
+func lagrangeCoefficients(xs []byte, x byte) []byte {
+	c := make([]byte, len(xs))
+	for j, xj := range xs {
+		num, den := byte(1), byte(1)
+		for m, xm := range xs {
+			if m == j {
+				continue
+			}
+			num = gfMul(num, x^xm)
+			den = gfMul(den, xj^xm)
+		}
+		c[j] = gfDiv(num, den)
+	}
+	return c
+}
+
+// interpolate writes into out the chunk at x from the chunks at xs
+func interpolate(chunks [][]byte, xs []byte, x byte, out []byte) {
+	for j, coeff := range lagrangeCoefficients(xs, x) {
+		if coeff == 0 {
+			continue
+		}
+		// Multiplying by a constant is a table lookup per byte
+		var mul [256]byte
+		for b := 1; b < 256; b++ {
+			mul[b] = gfMul(coeff, byte(b))
+		}
+		for i, b := range chunks[j] {
+			out[i] ^= mul[b]
+		}
+	}
+}
+
+// RSEncode extends k equal-size data chunks with parity chunks and returns all k+parity
+func RSEncode(data [][]byte, parity int) ([][]byte, error) {
+	k := len(data)
+	if k == 0 || parity < 0 || k+parity > RSMaxChunks {
+		return nil, fmt.Errorf("invalid code: %d data + %d parity chunks", k, parity)
+	}
+	size := len(data[0])
+	xs := make([]byte, k)
+	for i, chunk := range data {
+		if len(chunk) != size {
+			return nil, fmt.Errorf("chunk %d has %d bytes, expected %d", i, len(chunk), size)
+		}
+		xs[i] = byte(i)
+	}
+
+	chunks := make([][]byte, k+parity)
+	copy(chunks, data)
+	for p := k; p < k+parity; p++ {
+		chunks[p] = make([]byte, size)
+		interpolate(data, xs, byte(p), chunks[p])
+	}
+	return chunks, nil
+}
+
+// RSReconstruct fills in the nil chunks of a code word with k data chunks
+// At least k chunks must be present, all of the same size.
+func RSReconstruct(chunks [][]byte, k int) error {
+	if k == 0 || len(chunks) < k || len(chunks) > RSMaxChunks {
+		return fmt.Errorf("invalid code: %d data of %d chunks", k, len(chunks))
+	}
+
+	present := make([][]byte, 0, k)
+	xs := make([]byte, 0, k)
+	size := -1
+	for i, chunk := range chunks {
+		if chunk == nil || len(present) == k {
+			continue
+		}
+		if size >= 0 && len(chunk) != size {
+			return fmt.Errorf("chunk %d has %d bytes, expected %d", i, len(chunk), size)
+		}
+		size = len(chunk)
+		present = append(present, chunk)
+		xs = append(xs, byte(i))
+	}
+	if len(present) < k {
+		return fmt.Errorf("need %d chunks to reconstruct, have %d", k, len(present))
+	}
+
+	for i := range chunks {
+		if chunks[i] == nil {
+			chunks[i] = make([]byte, size)
+			interpolate(present, xs, byte(i), chunks[i])
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ type KeyStore struct {
 // EncryptedKey represents encrypted wallet data
 type EncryptedKey struct {
 	Address    string `json:"address"`
+	Account    []byte `json:"account,omitempty"` // Set once the key signs for a rotated account
 	Ciphertext []byte `json:"ciphertext"`
 	Salt       []byte `json:"salt"`
 	Nonce      []byte `json:"nonce"`
@@ -65,6 +66,7 @@ func (ks *KeyStore) Save(w *Wallet, password string) error {
 	// Create encrypted key structure
 	ek := EncryptedKey{
 		Address:    w.Address,
+		Account:    accountOf(w),
 		Ciphertext: ciphertext,
 		Salt:       salt,
 		Nonce:      nonce,
@@ -115,7 +117,26 @@ func (ks *KeyStore) Load(password string) (*Wallet, error) {
 	}
 
 	// Reconstruct wallet
-	return ImportPrivateKey(string(privateKey))
+	w, err := ImportPrivateKey(string(privateKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(ek.Account) == 32 {
+		var account [32]byte
+		copy(account[:], ek.Account)
+		if err := w.AdoptAccount(account); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// accountOf returns the rotated account of w, or nil if it signs for its own key
+func accountOf(w *Wallet) []byte {
+	if w.Account == ([32]byte{}) {
+		return nil
+	}
+	return w.Account[:]
 }
 
 // DeriveAddress derives public address from private key
@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// Multisig wallet support: policy registration and partial signature collection.
+//
+// Typical flow:
+//  1. Any co-signer calls CreateMultisigRegistration and broadcasts it.
+//  2. A proposer builds the spend with NewMultisigTransaction.
+//  3. Each co-signer calls SignMultisig and the proposer merges the results
+//     with AddMultisigSignature until IsMultisigComplete returns true.
+
+// MultisigAddress returns the Bech32 address of a multisig policy
+func MultisigAddress(policy *types.MultisigPolicy) (string, error) {
+	address := policy.Address()
+	return PubKeyToAddress(ed25519.PublicKey(address[:]))
+}
+
+// CreateMultisigRegistration creates and signs a transaction registering policy on-chain
+// fund is the initial RNR sent from this wallet to the new multisig account (may be 0).
+func (w *Wallet) CreateMultisigRegistration(policy *types.MultisigPolicy, fund uint64, nonce uint64) (*types.Transaction, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(types.MultisigRegisterPayload{
+		Threshold: policy.Threshold,
+		PubKeys:   policy.PubKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &types.Transaction{
+		Type:     types.TxTypeMultisigRegister,
+		Sender:   w.AccountID(),
+		Receiver: policy.Address(),
+		Amount:   fund,
+		Nonce:    nonce,
+		Payload:  payload,
+	}
+	tx.ID = types.HashTransaction(*tx)
+
+	if err := w.SignTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// NewMultisigTransaction creates an unsigned transfer from a multisig account
+func NewMultisigTransaction(policy *types.MultisigPolicy, to [32]byte, amount uint64, nonce uint64) *types.Transaction {
+	tx := &types.Transaction{
+		Type:     types.TxTypeRNRTransfer,
+		Sender:   policy.Address(),
+		Receiver: to,
+		Amount:   amount,
+		Nonce:    nonce,
+	}
+	tx.ID = types.HashTransaction(*tx)
+	return tx
+}
+
+// SignMultisig produces this wallet's partial signature for a multisig transaction
+func (w *Wallet) SignMultisig(tx *types.Transaction, policy *types.MultisigPolicy) (types.MultisigSignature, error) {
+	var pubKey [32]byte
+	copy(pubKey[:], w.PublicKey)
+
+	index := policy.IndexOf(pubKey)
+	if index < 0 {
+		return types.MultisigSignature{}, fmt.Errorf("wallet %s is not a signer of this policy", w.Address)
+	}
+
+	sig := types.MultisigSignature{KeyIndex: uint8(index)}
+	copy(sig.Signature[:], ed25519.Sign(w.PrivateKey, types.SerializeTransaction(*tx)))
+	return sig, nil
+}
+
+// AddMultisigSignature verifies a partial signature and merges it into tx
+// Signatures are kept ordered by key index; re-adding the same signer is a no-op.
+func AddMultisigSignature(tx *types.Transaction, policy *types.MultisigPolicy, sig types.MultisigSignature) error {
+	if _, err := policy.CountValidSignatures(types.SerializeTransaction(*tx), []types.MultisigSignature{sig}); err != nil {
+		return err
+	}
+
+	for _, existing := range tx.Signatures {
+		if existing.KeyIndex == sig.KeyIndex {
+			return nil
+		}
+	}
+
+	tx.Signatures = append(tx.Signatures, sig)
+	sort.Slice(tx.Signatures, func(i, j int) bool {
+		return tx.Signatures[i].KeyIndex < tx.Signatures[j].KeyIndex
+	})
+	return nil
+}
+
+// IsMultisigComplete returns true once tx carries enough valid signatures for policy
+func IsMultisigComplete(tx *types.Transaction, policy *types.MultisigPolicy) bool {
+	return policy.VerifyTransaction(*tx) == nil
+}
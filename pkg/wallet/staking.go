@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
+)
+
+// CreateStakeBond creates and signs a transaction bonding amount as validator stake
+// consensusKey is the key the validator signs votes with; it is required on the
+// first bond and may be nil when topping up. commissionRate (basis points) only
+// applies to the first bond.
+func (w *Wallet) CreateStakeBond(consensusKey ed25519.PublicKey, amount uint64, moniker string, commissionRate uint32, nonce uint64) (*types.Transaction, error) {
+	payload := types.StakeBondPayload{Moniker: moniker, CommissionRate: commissionRate}
+	if consensusKey != nil {
+		if len(consensusKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid consensus key length: %d", len(consensusKey))
+		}
+		copy(payload.ConsensusKey[:], consensusKey)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return w.createStakingTx(types.TxTypeStakeBond, amount, data, nonce)
+}
+
+// CreateStakeUnbond creates and signs a transaction unbonding amount of stake
+func (w *Wallet) CreateStakeUnbond(amount uint64, nonce uint64) (*types.Transaction, error) {
+	return w.createStakingTx(types.TxTypeStakeUnbond, amount, nil, nonce)
+}
+
+// CreateEditValidator creates and signs a transaction changing the validator's moniker
+// and, unless commissionRate is nil, its commission rate
+func (w *Wallet) CreateEditValidator(moniker string, commissionRate *uint32, nonce uint64) (*types.Transaction, error) {
+	data, err := json.Marshal(types.EditValidatorPayload{Moniker: moniker, CommissionRate: commissionRate})
+	if err != nil {
+		return nil, err
+	}
+	return w.createStakingTx(types.TxTypeEditValidator, 0, data, nonce)
+}
+
+// CreateUnjail creates and signs a transaction returning a jailed validator to the candidate set
+func (w *Wallet) CreateUnjail(nonce uint64) (*types.Transaction, error) {
+	return w.createStakingTx(types.TxTypeUnjail, 0, nil, nonce)
+}
+
+// CreateDelegate creates and signs a transaction delegating amount to the validator operated by validator
+func (w *Wallet) CreateDelegate(validator [32]byte, amount uint64, nonce uint64) (*types.Transaction, error) {
+	return w.createDelegationTx(types.TxTypeDelegate, validator, amount, nonce)
+}
+
+// CreateUndelegate creates and signs a transaction unbonding amount of the delegation to validator
+func (w *Wallet) CreateUndelegate(validator [32]byte, amount uint64, nonce uint64) (*types.Transaction, error) {
+	return w.createDelegationTx(types.TxTypeUndelegate, validator, amount, nonce)
+}
+
+// CreateWithdrawRewards creates and signs a transaction withdrawing rewards accrued by the delegation to validator
+func (w *Wallet) CreateWithdrawRewards(validator [32]byte, nonce uint64) (*types.Transaction, error) {
+	return w.createDelegationTx(types.TxTypeWithdrawRewards, validator, 0, nonce)
+}
+
+// createDelegationTx builds and signs a delegation transaction to validator
+func (w *Wallet) createDelegationTx(txType int, validator [32]byte, amount uint64, nonce uint64) (*types.Transaction, error) {
+	data, err := json.Marshal(types.DelegationPayload{Validator: validator})
+	if err != nil {
+		return nil, err
+	}
+	return w.createStakingTx(txType, amount, data, nonce)
+}
+
+// createStakingTx builds and signs a staking transaction for this wallet's account
+func (w *Wallet) createStakingTx(txType int, amount uint64, payload []byte, nonce uint64) (*types.Transaction, error) {
+	tx := &types.Transaction{
+		Type:    txType,
+		Sender:  w.AccountID(),
+		Amount:  amount,
+		Nonce:   nonce,
+		Payload: payload,
+	}
+	if err := types.CheckStakingTx(*tx); err != nil {
+		return nil, err
+	}
+	tx.ID = types.HashTransaction(*tx)
+
+	if err := w.SignTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
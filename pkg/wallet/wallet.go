@@ -3,6 +3,7 @@ package wallet
 import (
 	"crypto/ed25519"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 
 	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
@@ -15,6 +16,10 @@ type Wallet struct {
 	Address    string // Bech32 format: rnr1...
 	Mnemonic   string // BIP39 mnemonic (12 words)
 	Path       string // BIP32 derivation path
+
+	// Account identifier this key signs for after a key rotation
+	// (zero = the account identified by PublicKey itself)
+	Account [32]byte
 }
 
 // CreateWallet generates a new wallet with BIP39 mnemonic
@@ -89,6 +94,13 @@ func ImportPrivateKey(hexKey string) (*Wallet, error) {
 
 // SignTransaction signs a transaction
 func (w *Wallet) SignTransaction(tx *types.Transaction) error {
+	// Declare the signing key when signing for a rotated account
+	var pubKey [32]byte
+	copy(pubKey[:], w.PublicKey)
+	if tx.Sender != pubKey {
+		tx.PubKey = pubKey
+	}
+
 	// Serialize transaction
 	message := types.SerializeTransaction(*tx)
 
@@ -107,8 +119,8 @@ func (w *Wallet) CreateTransaction(to string, amount uint64, nonce uint64) (*typ
 		return nil, err
 	}
 
-	var sender, receiver [32]byte
-	copy(sender[:], w.PublicKey)
+	var receiver [32]byte
+	sender := w.AccountID()
 	copy(receiver[:], toBytes)
 
 	tx := &types.Transaction{
@@ -129,6 +141,91 @@ func (w *Wallet) CreateTransaction(to string, amount uint64, nonce uint64) (*typ
 	return tx, nil
 }
 
+// CreateMultiTransfer creates and signs a batch transfer paying every output
+// Amount is set to the native RNR total; the fee is deducted by the chain.
+func (w *Wallet) CreateMultiTransfer(outputs []types.TransferOutput, nonce uint64) (*types.Transaction, error) {
+	payload, err := json.Marshal(types.MultiTransferPayload{Outputs: outputs})
+	if err != nil {
+		return nil, err
+	}
+
+	sender := w.AccountID()
+
+	var total uint64
+	for _, out := range outputs {
+		if out.IsNative() {
+			total += out.Amount
+		}
+	}
+
+	tx := &types.Transaction{
+		Type:    types.TxTypeMultiTransfer,
+		Sender:  sender,
+		Amount:  total,
+		Nonce:   nonce,
+		Payload: payload,
+	}
+	if _, err := types.DecodeMultiTransfer(*tx); err != nil {
+		return nil, err
+	}
+
+	tx.ID = types.HashTransaction(*tx)
+	if err := w.SignTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// AccountID returns the account identifier this wallet signs for
+func (w *Wallet) AccountID() [32]byte {
+	if w.Account != ([32]byte{}) {
+		return w.Account
+	}
+	var id [32]byte
+	copy(id[:], w.PublicKey)
+	return id
+}
+
+// AdoptAccount makes this wallet sign for an account whose key was rotated to it
+// The address follows the account, so it stays the same across rotations.
+func (w *Wallet) AdoptAccount(account [32]byte) error {
+	address, err := PubKeyToAddress(ed25519.PublicKey(account[:]))
+	if err != nil {
+		return err
+	}
+	w.Account = account
+	w.Address = address
+	return nil
+}
+
+// CreateKeyRotation creates and signs a transaction rebinding this wallet's account to newPubKey
+// After it is included, the new key's wallet must call AdoptAccount(w.AccountID()).
+func (w *Wallet) CreateKeyRotation(newPubKey ed25519.PublicKey, nonce uint64) (*types.Transaction, error) {
+	if len(newPubKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: %d", len(newPubKey))
+	}
+
+	var newKey [32]byte
+	copy(newKey[:], newPubKey)
+	payload, err := json.Marshal(types.KeyRotatePayload{NewPubKey: newKey})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &types.Transaction{
+		Type:    types.TxTypeKeyRotate,
+		Sender:  w.AccountID(),
+		Nonce:   nonce,
+		Payload: payload,
+	}
+	tx.ID = types.HashTransaction(*tx)
+
+	if err := w.SignTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
 // ExportPrivateKey exports private key as hex
 func (w *Wallet) ExportPrivateKey() string {
 	return hex.EncodeToString(w.PrivateKey)
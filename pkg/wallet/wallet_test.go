@@ -1,9 +1,12 @@
 package wallet
 
 import (
+	"crypto/ed25519"
 	"encoding/hex"
 	"strings"
 	"testing"
+
+	"github.com/LICODX/PoSSR-RNRCORE/pkg/types"
 )
 
 func TestGenerateMnemonic(t *testing.T) {
@@ -193,3 +196,150 @@ func TestCreateWalletFromMnemonic(t *testing.T) {
 	t.Logf("  Mnemonic: %s", mnemonic)
 	t.Logf("  Address: %s", wallet1.Address)
 }
+
+func TestMultisigPartialSignatures(t *testing.T) {
+	// 2-of-3 policy
+	var signers []*Wallet
+	var keys [][32]byte
+	for i := 0; i < 3; i++ {
+		w, err := CreateWallet()
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		var pk [32]byte
+		copy(pk[:], w.PublicKey)
+		signers = append(signers, w)
+		keys = append(keys, pk)
+	}
+
+	policy, err := types.NewMultisigPolicy(2, keys)
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+
+	tx := NewMultisigTransaction(policy, [32]byte{0x42}, 10, 1)
+	if IsMultisigComplete(tx, policy) {
+		t.Fatal("Unsigned transaction should not be complete")
+	}
+
+	// First co-signer (added twice: idempotent)
+	sig1, err := signers[0].SignMultisig(tx, policy)
+	if err != nil {
+		t.Fatalf("SignMultisig failed: %v", err)
+	}
+	AddMultisigSignature(tx, policy, sig1)
+	AddMultisigSignature(tx, policy, sig1)
+	if len(tx.Signatures) != 1 || IsMultisigComplete(tx, policy) {
+		t.Fatal("1-of-2 signatures should not be complete")
+	}
+
+	// Forged signature is rejected
+	forged := sig1
+	forged.KeyIndex = uint8(policy.IndexOf(keys[2]))
+	if err := AddMultisigSignature(tx, policy, forged); err == nil {
+		t.Error("Signature for the wrong key should be rejected")
+	}
+
+	sig2, _ := signers[1].SignMultisig(tx, policy)
+	if err := AddMultisigSignature(tx, policy, sig2); err != nil {
+		t.Fatalf("AddMultisigSignature failed: %v", err)
+	}
+	if !IsMultisigComplete(tx, policy) {
+		t.Error("2-of-3 signatures should be complete")
+	}
+
+	// Outsider cannot sign
+	outsider, _ := CreateWallet()
+	if _, err := outsider.SignMultisig(tx, policy); err == nil {
+		t.Error("Non-signer should not be able to sign")
+	}
+
+	// Partial signatures do not carry over to another transaction type
+	retyped := *tx
+	retyped.Type = types.TxTypeStakeUnbond
+	if err := policy.VerifyTransaction(retyped); err == nil {
+		t.Error("multisig signatures still valid after changing the type")
+	}
+
+	// Nor can a funded registration be replayed as a transfer to the policy address
+	reg, err := signers[0].CreateMultisigRegistration(policy, 100, 1)
+	if err != nil {
+		t.Fatalf("CreateMultisigRegistration failed: %v", err)
+	}
+	transfer := *reg
+	transfer.Type = types.TxTypeRNRTransfer
+	if ed25519.Verify(signers[0].PublicKey, types.SerializeTransaction(transfer), reg.Signature[:]) {
+		t.Error("registration signature valid as a plain transfer")
+	}
+}
+
+func TestValidityWindowIsSigned(t *testing.T) {
+	w, _ := CreateWallet()
+	tx := &types.Transaction{Amount: 10, Nonce: 1, ExpiresAtHeight: 100}
+	copy(tx.Sender[:], w.PublicKey)
+	w.SignTransaction(tx)
+
+	if err := tx.CheckValidityWindow(101); err == nil {
+		t.Error("transaction should be expired after ExpiresAtHeight")
+	}
+
+	// Stripping the expiry must invalidate the signature
+	stripped := *tx
+	stripped.ExpiresAtHeight = 0
+	if ed25519.Verify(w.PublicKey, types.SerializeTransaction(stripped), tx.Signature[:]) {
+		t.Error("signature still valid after removing validity window")
+	}
+
+	// Transactions without a window keep their original encoding
+	plain := types.Transaction{Sender: tx.Sender, Amount: 10, Nonce: 1}
+	if len(types.SerializeTransaction(plain)) != 32+32+8+8 {
+		t.Error("serialization of window-less transactions changed")
+	}
+}
+
+func TestTransactionTypeIsSigned(t *testing.T) {
+	w, _ := CreateWallet()
+	unbond, err := w.CreateStakeUnbond(50, 1)
+	if err != nil {
+		t.Fatalf("CreateStakeUnbond failed: %v", err)
+	}
+	if !ed25519.Verify(w.PublicKey, types.SerializeTransaction(*unbond), unbond.Signature[:]) {
+		t.Fatal("signature of the unbond does not verify")
+	}
+
+	// Re-typing a signed transaction must invalidate its signature
+	for _, txType := range []int{types.TxTypeRNRTransfer, types.TxTypeStakeBond, types.TxTypeUndelegate} {
+		retyped := *unbond
+		retyped.Type = txType
+		if ed25519.Verify(w.PublicKey, types.SerializeTransaction(retyped), unbond.Signature[:]) {
+			t.Errorf("signature still valid after changing type %d to %d", unbond.Type, txType)
+		}
+	}
+}
+
+func TestDelegationDirectionIsSigned(t *testing.T) {
+	w, _ := CreateWallet()
+	delegate, err := w.CreateDelegate([32]byte{7}, 100, 1)
+	if err != nil {
+		t.Fatalf("CreateDelegate failed: %v", err)
+	}
+	undelegate, err := w.CreateUndelegate([32]byte{7}, 100, 1)
+	if err != nil {
+		t.Fatalf("CreateUndelegate failed: %v", err)
+	}
+	if delegate.ID == undelegate.ID {
+		t.Fatal("delegate and undelegate of the same amount share an ID")
+	}
+
+	// A signed delegate cannot be replayed as an undelegate, or the reverse
+	flipped := *delegate
+	flipped.Type = types.TxTypeUndelegate
+	if ed25519.Verify(w.PublicKey, types.SerializeTransaction(flipped), delegate.Signature[:]) {
+		t.Error("delegate signature valid as an undelegate")
+	}
+	flipped = *undelegate
+	flipped.Type = types.TxTypeDelegate
+	if ed25519.Verify(w.PublicKey, types.SerializeTransaction(flipped), undelegate.Signature[:]) {
+		t.Error("undelegate signature valid as a delegate")
+	}
+}
@@ -202,11 +202,15 @@ func setupNetwork() []*Node {
 			cfg = config.ShardConfig{Role: "ShardNode", ShardIDs: []int{shardID}}
 		}
 
+		chain, err := blockchain.NewBlockchain(db, cfg)
+		if err != nil {
+			panic(err)
+		}
 		nodes[i] = &Node{
 			ID:         i,
 			Config:     cfg,
 			State:      state.NewManager(db.GetDB()),
-			Blockchain: blockchain.NewBlockchain(db, cfg),
+			Blockchain: chain,
 		}
 
 		if i < 5 {
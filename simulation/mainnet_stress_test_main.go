@@ -173,7 +173,10 @@ func setupNetwork(ctx context.Context) []*NodeInstance {
 
 		// State & Blockchain
 		stateMgr := state.NewManager(db.GetDB())
-		bc := blockchain.NewBlockchain(db, cfg)
+		bc, err := blockchain.NewBlockchain(db, cfg)
+		if err != nil {
+			panic(err)
+		}
 
 		// P2P
 		p2pNode, err := p2p.NewGossipSubNode(ctx, BasePort+i, cfg)
@@ -63,7 +63,10 @@ func main() {
 
 		// Init Mock DB & Blockchain
 		db, _ := storage.NewLevelDB(fmt.Sprintf("./data/heavy_sim_%d", i))
-		bc := blockchain.NewBlockchain(db, cfg)
+		bc, err := blockchain.NewBlockchain(db, cfg)
+		if err != nil {
+			panic(err)
+		}
 		blockchains[i] = bc
 
 		// Init P2P Node